@@ -7,18 +7,25 @@ import (
 )
 
 type Campaign struct {
-	ID         string         `json:"id"`
-	UserID     string         `json:"user_id"`
-	CreateTime time.Time      `json:"create_time"`
-	UpdateTime time.Time      `json:"update_time"`
-	FormID     string         `json:"form_id" doc:"ID of form to be submitted"`
-	StartTime  *time.Time     `json:"start_time" doc:"Optional prevents submission before this time"`
-	EndTime    *time.Time     `json:"end_time" doc:"Optional prevents submission after this time"`
-	Queue      string         `json:"queue" doc:"Queue where notification is sent. If not specified, default processing applied configured in action."`
-	Action     CampaignAction `json:"action" doc:"What to do with submitted documents"`
+	ID         string           `json:"id"`
+	Rev        int              `json:"rev,omitempty" doc:"Revision count campaign updates 1,2,3,..."`
+	UserID     string           `json:"user_id" doc:"User who created the campaign, who is always an owner"`
+	Members    []CampaignMember `json:"members,omitempty" doc:"Other users who can see or process the docs in the campaign, see CampaignRole"`
+	CreateTime time.Time        `json:"create_time"`
+	UpdateTime time.Time        `json:"update_time"`
+	FormID     string           `json:"form_id" doc:"ID of form to be submitted"`
+	StartTime  *time.Time       `json:"start_time" doc:"Optional prevents submission before this time"`
+	EndTime    *time.Time       `json:"end_time" doc:"Optional prevents submission after this time"`
+	Queue      string           `json:"queue" doc:"Queue where notification is sent. If not specified, default processing applied configured in action."`
+	Queues     []CampaignQueue  `json:"queues,omitempty" doc:"Optional processing queues, submitted docs are placed in the first queue and can be moved to other queues"`
+	Action     CampaignAction   `json:"action" doc:"What to do with submitted documents"`
+	Edits      *CampaignEdits   `json:"edits,omitempty" doc:"Optional allows users to edit docs after they were submitted. If not specified, submitted docs cannot be edited."`
 }
 
 func (c Campaign) Validate() error {
+	if c.Rev < 0 {
+		return errors.Errorf("negative rev:%d", c.Rev)
+	}
 	if c.UserID == "" {
 		return errors.Errorf("missing user_id")
 	}
@@ -28,9 +35,110 @@ func (c Campaign) Validate() error {
 	if c.StartTime != nil && c.EndTime != nil && c.StartTime.After(*c.EndTime) {
 		return errors.Errorf("start_time:\"%s\" is after end_time:\"%s\"", *c.StartTime, *c.EndTime)
 	}
+	if err := c.validateMembers(); err != nil {
+		return err
+	}
+	queueNames := []string{}
+	for i, q := range c.Queues {
+		if err := q.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid queues[%d]", i)
+		}
+		queueNames = append(queueNames, q.Name)
+	}
+	if !uniqNames(queueNames) {
+		return errors.Errorf("duplicate queue names")
+	}
+	if c.Edits != nil {
+		if err := c.Edits.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid edits")
+		}
+	}
+	return nil
+}
+
+// Open returns an error when docs cannot be submitted at the time because the
+// campaign did not start yet or already ended
+func (c Campaign) Open(now time.Time) error {
+	if c.StartTime != nil && c.StartTime.After(now) {
+		return errors.Errorf("campaign(%s) only starts at %s", c.ID, c.StartTime.Format(time.RFC3339))
+	}
+	if c.EndTime != nil && c.EndTime.Before(now) {
+		return errors.Errorf("campaign(%s) ended at %s", c.ID, c.EndTime.Format(time.RFC3339))
+	}
+	return nil
+} //Campaign.Open()
+
+// SubmitAllowed returns an error explaining why the doc cannot be submitted in
+// the campaign now, or nil when it can be submitted
+func (c Campaign) SubmitAllowed(doc Doc, now time.Time) error {
+	if doc.CampaignID != c.ID {
+		return errors.Errorf("doc(%s) is not in campaign(%s)", doc.ID, c.ID)
+	}
+	if doc.FormID != c.FormID {
+		return errors.Errorf("doc.form_id(%s) is not the form(%s) of campaign(%s)", doc.FormID, c.FormID, c.ID)
+	}
+	return c.Open(now)
+} //Campaign.SubmitAllowed()
+
+// EditAllowed returns an error explaining why the user who submitted the doc
+// may not edit it now, or nil when it can be edited. A returned doc can always
+// be edited, but not while it is reviewed or after it was accepted or rejected.
+func (c Campaign) EditAllowed(doc Doc, now time.Time) error {
+	if doc.CampaignID != c.ID {
+		return errors.Errorf("doc(%s) was not submitted in campaign(%s)", doc.ID, c.ID)
+	}
+	switch doc.State {
+	case DocStateReturned:
+		return nil //the reviewer asked to edit it
+	case DocStateSubmitted, DocStateResubmitted:
+	default:
+		return errors.Errorf("doc(%s) is %s and can no longer be edited", doc.ID, doc.State)
+	}
+	if c.Edits == nil {
+		return errors.Errorf("campaign(%s) does not allow edits", c.ID)
+	}
+	until := c.Edits.Until
+	if until == nil {
+		until = c.EndTime
+	}
+	if until != nil && now.After(*until) {
+		return errors.Errorf("campaign(%s) only allowed edits until %s", c.ID, until.Format(time.RFC3339))
+	}
+	if c.Edits.If != "" {
+		allowed, err := c.Edits.If.Eval(func(name string) interface{} { return doc.Data[name] })
+		if err != nil {
+			return errors.Wrapf(err, "failed to evaluate campaign(%s) edits.if", c.ID)
+		}
+		if !allowed {
+			return errors.Errorf("doc(%s) can no longer be edited", doc.ID)
+		}
+	}
 	return nil
+} //Campaign.EditAllowed()
+
+// CampaignEdits controls when submitted docs can be edited by the user who
+// submitted them, e.g. {"until":"2023-06-30T00:00:00Z","if":"!paid"}
+type CampaignEdits struct {
+	Until *time.Time `json:"until,omitempty" doc:"Optional prevents edits after this time. Defaults to the campaign end_time."`
+	If    Condition  `json:"if,omitempty" doc:"Optional condition on the doc data that must be true to edit the doc, e.g. \"!paid\" to allow edits only before payment"`
 }
 
+func (e CampaignEdits) Validate() error {
+	if err := e.If.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid if:\"%s\"", e.If)
+	}
+	return nil
+}
+
+// NotificationQueue is the redis list where notifications about docs in the
+// campaign are pushed, see formsinterface.CampaignNotification
+func (c Campaign) NotificationQueue() string {
+	if c.Queue != "" {
+		return c.Queue
+	}
+	return c.ID
+} //Campaign.NotificationQueue()
+
 type CampaignAction struct {
 	Http *CampaignActionHttp `json:"http" doc:"Specify to call an HTTP end-point"`
 	//Email ... send email message with summary in body and JSON attachments, or consider using a template and markdown to construct the message..."
@@ -0,0 +1,310 @@
+package forms
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// Condition is an Expression used to show an item or section only when it is
+// true (show_if) or to require a value only when it is true (required_if),
+// e.g.:
+//
+//	accommodation == "tent"
+//	age >= 18 && (role in ["officer", "parent"] || !member)
+//	"vegetarian" in diet
+//
+// A field name on its own is true when the field has a value.
+type Condition string
+
+// Validate checks the syntax of the condition. An empty condition is valid.
+func (c Condition) Validate() error {
+	return Expression(c).Validate()
+}
+
+// Refs returns the sorted names of fields referenced in the condition
+func (c Condition) Refs() ([]string, error) {
+	return Expression(c).Refs()
+}
+
+// Eval returns the result of the condition using value(name) to get field values
+func (c Condition) Eval(value func(name string) interface{}) (bool, error) {
+	v, err := Expression(c).Eval(value)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// JSON returns the parsed condition as a JSON tree that is evaluated in the
+// browser to apply the condition while the form is being filled in.
+func (c Condition) JSON() (string, error) {
+	return Expression(c).JSON()
+}
+
+// conditionScope resolves field values while evaluating show_if and
+// required_if conditions and computed fields on doc data. Hidden fields have
+// no value and computed fields have the value of their expression. Each sub
+// section instance has its own scope with the form scope as parent, so that
+// conditions in a sub section can refer to fields in the instance and in the
+// rest of the form.
+type conditionScope struct {
+	parent         *conditionScope
+	items          map[string]conditionScopeItem
+	data           map[string]interface{}
+	visible        map[string]bool
+	visiting       map[string]bool
+	computed       map[string]interface{}
+	computeErrors  map[string]error
+	computeVisited map[string]bool
+}
+
+type conditionScopeItem struct {
+	section Section
+	item    Item
+}
+
+func newConditionScope(parent *conditionScope, sections []Section, data map[string]interface{}) *conditionScope {
+	s := &conditionScope{
+		parent:         parent,
+		items:          map[string]conditionScopeItem{},
+		data:           data,
+		visible:        map[string]bool{},
+		visiting:       map[string]bool{},
+		computed:       map[string]interface{}{},
+		computeErrors:  map[string]error{},
+		computeVisited: map[string]bool{},
+	}
+	for _, section := range sections {
+		for _, item := range section.Items {
+			if n := item.name(); n != "" {
+				s.items[n] = conditionScopeItem{section: section, item: item}
+			}
+		}
+	}
+	return s
+}
+
+// value returns the value of a field, or nil when it is hidden
+func (s *conditionScope) value(name string) interface{} {
+	if si, ok := s.items[name]; ok {
+		if !s.isVisible(name) {
+			return nil
+		}
+		if si.item.Field != nil && si.item.Field.Computed != nil {
+			v, _ := s.compute(name)
+			return v
+		}
+		return s.data[name]
+	}
+	if s.parent != nil {
+		return s.parent.value(name)
+	}
+	return nil
+}
+
+func (s *conditionScope) isVisible(name string) bool {
+	if visible, ok := s.visible[name]; ok {
+		return visible
+	}
+	if s.visiting[name] {
+		//cycles are rejected in Form.Validate(), but never loop
+		return false
+	}
+	s.visiting[name] = true
+	si := s.items[name]
+	visible := s.sectionVisible(si.section) && s.eval(si.item.ShowIf, true)
+	delete(s.visiting, name)
+	s.visible[name] = visible
+	return visible
+}
+
+// compute returns the value of a computed field from its expression,
+// ignoring any value that was submitted for it
+func (s *conditionScope) compute(name string) (interface{}, error) {
+	if s.computeVisited[name] {
+		//cycles are rejected in Form.Validate(), but never loop
+		return s.computed[name], s.computeErrors[name]
+	}
+	s.computeVisited[name] = true
+	c := s.items[name].item.Field.Computed
+	v, err := c.Expression.Eval(func(ref string) interface{} {
+		if table, ok := c.Lookup[ref]; ok {
+			return table
+		}
+		return s.value(ref)
+	})
+	if err != nil {
+		s.computeErrors[name] = err
+		return nil, err
+	}
+	s.computed[name] = v
+	return v, nil
+}
+
+func (s *conditionScope) sectionVisible(section Section) bool {
+	return s.eval(section.ShowIf, true)
+}
+
+func (s *conditionScope) itemVisible(section Section, item Item) bool {
+	if n := item.name(); n != "" {
+		if _, ok := s.items[n]; ok {
+			return s.isVisible(n)
+		}
+	}
+	return s.sectionVisible(section) && s.eval(item.ShowIf, true)
+}
+
+func (s *conditionScope) itemRequired(item Item) bool {
+	return s.eval(item.RequiredIf, false)
+}
+
+// eval returns the result of condition c, or def when it is empty or invalid
+func (s *conditionScope) eval(c Condition, def bool) bool {
+	if c == "" {
+		return def
+	}
+	result, err := c.Eval(s.value)
+	if err != nil {
+		return def
+	}
+	return result
+}
+
+// validateConditions checks that conditions and computed fields in the form
+// only refer to fields that exist and that show_if conditions and computed
+// fields do not depend on each other in a cycle, e.g. a shown if b and b
+// shown if a. Conditions in a sub section can refer to fields in the same sub
+// section and in the rest of the form.
+func (f Form) validateConditions() error {
+	deps := map[string][]string{}
+	if err := validateSectionConditions(nil, "", f.Sections, deps); err != nil {
+		return err
+	}
+	//check for cycles with depth-first search
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		switch state[name] {
+		case visiting:
+			return errors.Errorf("cycle: %s", strings.Join(path, " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range deps[name] {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		state[name] = done
+		return nil
+	}
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+} //Form.validateConditions()
+
+// conditionNames are the names of fields, tables and subs that conditions can
+// refer to in a scope
+type conditionNames struct {
+	parent *conditionNames
+	prefix string
+	names  map[string]bool
+}
+
+// resolve returns the full name of a referenced field, e.g. "sub.field"
+func (n *conditionNames) resolve(ref string) (string, error) {
+	if n == nil {
+		return "", errors.Errorf("unknown field \"%s\"", ref)
+	}
+	if n.names[ref] {
+		return n.prefix + ref, nil
+	}
+	return n.parent.resolve(ref)
+}
+
+// resolveAll resolves the references in e, except the names in local
+func (n *conditionNames) resolveAll(e Expression, local map[string]map[string]float64) ([]string, error) {
+	refs, err := e.Refs()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, ref := range refs {
+		if _, ok := local[ref]; ok {
+			continue
+		}
+		name, err := n.resolve(ref)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func validateSectionConditions(parent *conditionNames, prefix string, sections []Section, deps map[string][]string) error {
+	names := &conditionNames{parent: parent, prefix: prefix, names: map[string]bool{}}
+	for _, section := range sections {
+		for _, item := range section.Items {
+			if n := item.name(); n != "" {
+				names.names[n] = true
+			}
+		}
+	}
+	for _, section := range sections {
+		sectionDeps, err := names.resolveAll(Expression(section.ShowIf), nil)
+		if err != nil {
+			return errors.Wrapf(err, "section(%s).show_if", section.Name)
+		}
+		for i, item := range section.Items {
+			itemName := item.name()
+			if itemName == "" {
+				itemName = strconv.Itoa(i)
+			}
+			itemDeps, err := names.resolveAll(Expression(item.ShowIf), nil)
+			if err != nil {
+				return errors.Wrapf(err, "section(%s).item(%s).show_if", section.Name, itemName)
+			}
+			if _, err := names.resolveAll(Expression(item.RequiredIf), nil); err != nil {
+				return errors.Wrapf(err, "section(%s).item(%s).required_if", section.Name, itemName)
+			}
+			if item.Field != nil && item.Field.Computed != nil {
+				computedDeps, err := names.resolveAll(item.Field.Computed.Expression, item.Field.Computed.Lookup)
+				if err != nil {
+					return errors.Wrapf(err, "section(%s).item(%s).computed", section.Name, itemName)
+				}
+				itemDeps = append(itemDeps, computedDeps...)
+			}
+			if n := item.name(); n != "" {
+				deps[prefix+n] = append(append([]string{}, sectionDeps...), itemDeps...)
+			}
+			if item.Sub != nil && item.Sub.Section != nil {
+				if err := validateSectionConditions(names, prefix+item.Sub.Name+".", []Section{*item.Sub.Section}, deps); err != nil {
+					return errors.Wrapf(err, "sub(%s)", item.Sub.Name)
+				}
+				//values in the sub depend on what its items refer to
+				for _, n := range item.Sub.Section.ItemNames() {
+					deps[prefix+item.Sub.Name] = append(deps[prefix+item.Sub.Name], prefix+item.Sub.Name+"."+n)
+				}
+			}
+		}
+	}
+	return nil
+} //validateSectionConditions()
@@ -0,0 +1,147 @@
+package forms
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// conditionTestForm returns a form with one section with the items
+func conditionTestForm(items string) string {
+	return `{"title":"Test","sections":[{"name":"main","title":"Main","items":[` + items + `]}]}`
+}
+
+func TestValidateConditions(t *testing.T) {
+	tests := []struct {
+		name  string
+		items string
+		err   string
+	}{
+		{
+			name: "no cycle",
+			items: `{"field":{"title":"A","name":"a","short":{}}},
+				{"field":{"title":"B","name":"b","short":{}},"show_if":"a == \"x\""}`,
+		},
+		{
+			name: "show_if cycle",
+			items: `{"field":{"title":"A","name":"a","short":{}},"show_if":"b"},
+				{"field":{"title":"B","name":"b","short":{}},"show_if":"a"}`,
+			err: "cycle: a -> b -> a",
+		},
+		{
+			name:  "show_if on itself",
+			items: `{"field":{"title":"A","name":"a","short":{}},"show_if":"a == \"x\""}`,
+			err:   "cycle: a -> a",
+		},
+		{
+			name: "computed cycle",
+			items: `{"field":{"title":"A","name":"a","computed":{"expression":"b + 1"}}},
+				{"field":{"title":"B","name":"b","computed":{"expression":"c * 2"}}},
+				{"field":{"title":"C","name":"c","short":{}},"show_if":"a > 1"}`,
+			err: "cycle: a -> b -> c -> a",
+		},
+		{
+			name: "required_if may refer to itself",
+			items: `{"field":{"title":"A","name":"a","short":{}}},
+				{"field":{"title":"B","name":"b","short":{}},"required_if":"a && !b"}`,
+		},
+		{
+			name: "sub refers to the form",
+			items: `{"field":{"title":"A","name":"a","short":{}}},
+				{"sub":{"title":"S","name":"s","min":0,"max":2,"section":{"name":"s1","title":"S1","items":[
+					{"field":{"title":"B","name":"b","short":{}},"show_if":"a"}
+				]}}}`,
+		},
+		{
+			name: "cycle through a sub",
+			items: `{"field":{"title":"A","name":"a","short":{}},"show_if":"count(s.b) > 0"},
+				{"sub":{"title":"S","name":"s","min":0,"max":2,"section":{"name":"s1","title":"S1","items":[
+					{"field":{"title":"B","name":"b","short":{}},"show_if":"a"}
+				]}}}`,
+			err: "cycle: a -> s -> s.b -> a",
+		},
+		{
+			name:  "unknown field",
+			items: `{"field":{"title":"A","name":"a","short":{}},"show_if":"x"}`,
+			err:   "unknown field \"x\"",
+		},
+		{
+			name:  "syntax error",
+			items: `{"field":{"title":"A","name":"a","short":{}},"show_if":"(a"}`,
+			err:   "missing \")\" for \"(\" at offset 0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var f Form
+			if err := json.Unmarshal([]byte(conditionTestForm(test.items)), &f); err != nil {
+				t.Fatalf("failed to parse form: %+v", err)
+			}
+			err := f.Validate()
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %+v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(fmt.Sprintf("%+v", err), test.err) {
+				t.Fatalf("got error %v, expected \"%s\"", err, test.err)
+			}
+		})
+	}
+} //TestValidateConditions()
+
+func TestConditionShowIf(t *testing.T) {
+	f := testForm(t, conditionTestForm(`
+		{"field":{"title":"Age","name":"age","integer":{}}},
+		{"field":{"title":"Role","name":"role","short":{}},"show_if":"age >= 18"},
+		{"field":{"title":"Pass","name":"pass","short":{}},"show_if":"role in [\"officer\", \"parent\"]"}`))
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "shown",
+			data:     map[string]interface{}{"age": "20", "role": "parent", "pass": "p1"},
+			expected: map[string]interface{}{"age": int64(20), "role": "parent", "pass": "p1"},
+		},
+		{
+			name:     "hidden values are dropped",
+			data:     map[string]interface{}{"age": "20", "role": "kid", "pass": "p1"},
+			expected: map[string]interface{}{"age": int64(20), "role": "kid"},
+		},
+		{
+			name:     "hidden by a hidden field",
+			data:     map[string]interface{}{"age": "12", "role": "parent", "pass": "p1"},
+			expected: map[string]interface{}{"age": int64(12)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			coerced, err := f.CoerceData(test.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if len(coerced) != len(test.expected) {
+				t.Fatalf("got %v, expected %v", coerced, test.expected)
+			}
+			for n, v := range test.expected {
+				if coerced[n] != v {
+					t.Errorf("%s: got %v, expected %v", n, coerced[n], v)
+				}
+			}
+			if err := f.ValidateData(coerced); err != nil {
+				t.Errorf("coerced data is not valid: %+v", err)
+			}
+			//without coercion the hidden values are rejected
+			if len(test.data) != len(test.expected) {
+				fieldErrors, ok := f.ValidateData(map[string]interface{}{"age": coerced["age"], "role": test.data["role"], "pass": test.data["pass"]}).(FieldErrors)
+				if !ok || len(fieldErrors) != len(test.data)-len(test.expected) {
+					t.Errorf("hidden values not rejected: %v", fieldErrors)
+				}
+			}
+		})
+	}
+} //TestConditionShowIf()
@@ -21,6 +21,7 @@ var (
 	formsTTL    = time.Second * 1
 	redisClient *redis.Client
 	msClient    ms.Client
+	principal   formsinterface.Principal //campaign member that the consumer acts as
 )
 
 var log = logger.New().WithLevel(logger.LevelDebug)
@@ -49,6 +50,14 @@ func main() {
 	if key == "" {
 		panic("REDIS_CONSUMER_KEY is not defined")
 	}
+	principal.UserID = os.Getenv("FORMS_USER_ID")
+	if principal.UserID == "" {
+		panic("FORMS_USER_ID is not defined, it must be a trusted service in the forms config and a member of the campaign")
+	}
+	principal.Secret = os.Getenv("FORMS_SECRET")
+	if err := formsinterface.ValidateSecret(principal.Secret); err != nil {
+		panic(fmt.Sprintf("invalid FORMS_SECRET, it must be the secret of FORMS_USER_ID in the forms config: %+v", err))
+	}
 	for {
 		ctx := context.Background()
 		result, err := redisClient.BRPop(ctx, 10*time.Second, key).Result()
@@ -100,7 +109,8 @@ func loadCampaignDocument(ctx context.Context, campaignID, docID string) (forms.
 		},
 		formsTTL,
 		formsinterface.GetCampaignRequest{
-			ID: campaignID,
+			Principal: principal,
+			ID:        campaignID,
 		},
 		formsinterface.GetCampaignResponse{})
 	if err != nil {
@@ -116,7 +126,8 @@ func loadCampaignDocument(ctx context.Context, campaignID, docID string) (forms.
 		},
 		time.Millisecond*time.Duration(formsTTL),
 		formsinterface.GetDocRequest{
-			ID: docID,
+			Principal: principal,
+			ID:        docID,
 		},
 		formsinterface.GetDocResponse{})
 	if err != nil {
@@ -0,0 +1,974 @@
+package forms
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-msvc/errors"
+)
+
+// FieldErrors is returned from Form.ValidateData() with the reason why each
+// invalid value was rejected. Keys are field paths in the doc data, e.g.
+// "name" for a field, "table_1[0].f1" for a table column or "sub_1[1].f2" for
+// a field inside a sub section instance.
+type FieldErrors map[string]string
+
+func (fe FieldErrors) Error() string {
+	paths := make([]string, 0, len(fe))
+	for path := range fe {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	msgs := make([]string, 0, len(paths))
+	for _, path := range paths {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", path, fe[path]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateData checks submitted doc data against the constraints declared in
+// the form. Data keys are the names of fields, tables and subs in any of the
+// form sections. Items hidden by show_if must not have values and items with
+// a true required_if must have values. It returns FieldErrors when any value
+// is invalid.
+func (f Form) ValidateData(data map[string]interface{}) error {
+	fieldErrors := FieldErrors{}
+	knownNames := map[string]bool{}
+	scope := newConditionScope(nil, f.Sections, data)
+	for _, s := range f.Sections {
+		s.validateData("", data, scope, fieldErrors)
+		for _, n := range s.ItemNames() {
+			knownNames[n] = true
+		}
+	}
+	for n := range data {
+		if !knownNames[n] {
+			fieldErrors[n] = "unknown field"
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	return nil
+} //Form.ValidateData()
+
+// CoerceData converts submitted values, e.g. the strings posted from an HTML
+// form, into the types stored in doc data: string for short, text, time,
+// duration and choice, int64 for integer, float64 for number, time.Time for
+// date, []string for selection and a list of objects for table and sub.
+// Computed fields are calculated from the coerced values, ignoring any value
+// submitted for them.
+// Values that were not entered or that are hidden by show_if conditions are
+// omitted and names not in the form are copied as is for ValidateData() to
+// reject.
+func (f Form) CoerceData(data map[string]interface{}) (map[string]interface{}, error) {
+	fieldErrors := FieldErrors{}
+	coerced := map[string]interface{}{}
+	for n, v := range data {
+		coerced[n] = v
+	}
+	for _, s := range f.Sections {
+		s.coerceData("", data, coerced, fieldErrors)
+	}
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+	dropHiddenValues(nil, f.Sections, coerced)
+	if err := computeValues(nil, f.Sections, coerced); err != nil {
+		return nil, err
+	}
+	return coerced, nil
+} //Form.CoerceData()
+
+// ValidateSectionData checks the data entered so far in a multi-page form
+// when one section is submitted. It returns FieldErrors only for items in the
+// named section, ignoring sections that were not yet filled in.
+func (f Form) ValidateSectionData(name string, data map[string]interface{}) error {
+	var section *Section
+	for i := range f.Sections {
+		if f.Sections[i].Name == name {
+			section = &f.Sections[i]
+		}
+	}
+	if section == nil {
+		return errors.Errorf("unknown section \"%s\"", name)
+	}
+	names := map[string]bool{}
+	for _, n := range section.ItemNames() {
+		names[n] = true
+	}
+	coerced, err := f.CoerceData(data)
+	if fieldErrors, ok := err.(FieldErrors); ok && len(fieldErrors.in(names)) == 0 {
+		//values that cannot be coerced in other sections, e.g. kept when
+		//the user went back, are checked when those sections are submitted
+		valid := map[string]interface{}{}
+		for n, v := range data {
+			valid[n] = v
+		}
+		for path := range fieldErrors {
+			delete(valid, DataPathName(path))
+		}
+		coerced, err = f.CoerceData(valid)
+	}
+	if err == nil {
+		err = f.ValidateData(coerced)
+	}
+	fieldErrors, ok := err.(FieldErrors)
+	if !ok {
+		return err
+	}
+	if sectionErrors := fieldErrors.in(names); len(sectionErrors) > 0 {
+		return sectionErrors
+	}
+	return nil
+} //Form.ValidateSectionData()
+
+// SectionVisible is true when the show_if condition of the named section is
+// true for the data entered so far
+func (f Form) SectionVisible(name string, data map[string]interface{}) bool {
+	coerced, err := f.CoerceData(data)
+	if err != nil {
+		coerced = data
+	}
+	scope := newConditionScope(nil, f.Sections, coerced)
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return scope.sectionVisible(s)
+		}
+	}
+	return false
+} //Form.SectionVisible()
+
+// in returns the errors of the named fields, tables and subs
+func (fe FieldErrors) in(names map[string]bool) FieldErrors {
+	errs := FieldErrors{}
+	for path, msg := range fe {
+		if names[DataPathName(path)] {
+			errs[path] = msg
+		}
+	}
+	return errs
+} //FieldErrors.in()
+
+// DataPathName returns the top-level name in a data path, e.g. "table" for
+// "table[0].field"
+func DataPathName(path string) string {
+	if i := strings.IndexAny(path, "[."); i >= 0 {
+		return path[:i]
+	}
+	return path
+} //DataPathName()
+
+// ItemNames returns the names of all fields, tables and subs in the section
+func (s Section) ItemNames() []string {
+	names := []string{}
+	for _, item := range s.Items {
+		if item.Field != nil {
+			names = append(names, item.Field.Name)
+		}
+		if item.Table != nil {
+			names = append(names, item.Table.Name)
+		}
+		if item.Sub != nil {
+			names = append(names, item.Sub.Name)
+		}
+	}
+	return names
+} //Section.ItemNames()
+
+func (s Section) coerceData(prefix string, data map[string]interface{}, coerced map[string]interface{}, fieldErrors FieldErrors) {
+	for _, n := range s.ItemNames() {
+		delete(coerced, n)
+	}
+	for _, item := range s.Items {
+		if item.Field != nil {
+			if value, err := item.Field.CoerceValue(data[item.Field.Name]); err != nil {
+				fieldErrors[prefix+item.Field.Name] = err.Error()
+			} else if value != nil {
+				coerced[item.Field.Name] = value
+			}
+		}
+		if item.Table != nil {
+			if rows := item.Table.coerceData(prefix+item.Table.Name, data[item.Table.Name], fieldErrors); rows != nil {
+				coerced[item.Table.Name] = rows
+			}
+		}
+		if item.Sub != nil {
+			if instances := item.Sub.coerceData(prefix+item.Sub.Name, data[item.Sub.Name], fieldErrors); instances != nil {
+				coerced[item.Sub.Name] = instances
+			}
+		}
+	}
+} //Section.coerceData()
+
+// dropHiddenValues deletes the values of items hidden by show_if conditions
+// from data, also inside each sub section instance
+func dropHiddenValues(parent *conditionScope, sections []Section, data map[string]interface{}) {
+	scope := newConditionScope(parent, sections, data)
+	hidden := []string{}
+	for _, s := range sections {
+		for _, item := range s.Items {
+			n := item.name()
+			if n == "" {
+				continue
+			}
+			if !scope.itemVisible(s, item) {
+				hidden = append(hidden, n)
+				continue
+			}
+			if item.Sub != nil && item.Sub.Section != nil {
+				instances, _ := dataRows(data[n])
+				for _, instance := range instances {
+					dropHiddenValues(scope, []Section{*item.Sub.Section}, instance)
+				}
+			}
+		}
+	}
+	//delete after evaluating all conditions on the same data
+	for _, n := range hidden {
+		delete(data, n)
+	}
+} //dropHiddenValues()
+
+// computeValues sets the values of visible computed fields in data, also
+// inside each sub section instance
+func computeValues(parent *conditionScope, sections []Section, data map[string]interface{}) error {
+	scope := newConditionScope(parent, sections, data)
+	computed := map[string]interface{}{}
+	fieldErrors := FieldErrors{}
+	//first compute values in sub instances that fields here may refer to
+	for _, s := range sections {
+		for _, item := range s.Items {
+			if item.Sub == nil || item.Sub.Section == nil || !scope.itemVisible(s, item) {
+				continue
+			}
+			instances, _ := dataRows(data[item.Sub.Name])
+			for i, instance := range instances {
+				if err := computeValues(scope, []Section{*item.Sub.Section}, instance); err != nil {
+					for path, msg := range err.(FieldErrors) {
+						fieldErrors[fmt.Sprintf("%s[%d].%s", item.Sub.Name, i, path)] = msg
+					}
+				}
+			}
+		}
+	}
+	for _, s := range sections {
+		for _, item := range s.Items {
+			if item.Field == nil || item.Field.Computed == nil || !scope.itemVisible(s, item) {
+				continue
+			}
+			v, err := scope.compute(item.Field.Name)
+			if err != nil {
+				fieldErrors[item.Field.Name] = err.Error()
+			} else if v != nil {
+				computed[item.Field.Name] = v
+			}
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+	//set after evaluating all expressions on the same data
+	for n, v := range computed {
+		data[n] = v
+	}
+	return nil
+} //computeValues()
+
+func (s Section) validateData(prefix string, data map[string]interface{}, scope *conditionScope, fieldErrors FieldErrors) {
+	for _, item := range s.Items {
+		if n := item.name(); n != "" {
+			if !scope.itemVisible(s, item) {
+				if dataHasValue(data[n]) {
+					fieldErrors[prefix+n] = "not expected when hidden by show_if"
+				}
+				continue
+			}
+			if scope.itemRequired(item) && !dataHasValue(data[n]) {
+				fieldErrors[prefix+n] = "required"
+				if item.Field != nil {
+					fieldErrors[prefix+n] = item.Field.requiredError().Error()
+				}
+				continue
+			}
+		}
+		if item.Field != nil && item.Field.Computed != nil {
+			expected, err := scope.compute(item.Field.Name)
+			if err != nil {
+				fieldErrors[prefix+item.Field.Name] = err.Error()
+			} else if !valuesEqual(exprValue(expected), exprValue(data[item.Field.Name])) {
+				fieldErrors[prefix+item.Field.Name] = fmt.Sprintf("is not the computed value %v", expected)
+			}
+			continue
+		}
+		if item.Field != nil {
+			value, present := data[item.Field.Name]
+			if err := item.Field.ValidateValue(value, present); err != nil {
+				fieldErrors[prefix+item.Field.Name] = err.Error()
+			}
+		}
+		if item.Table != nil {
+			item.Table.validateData(prefix+item.Table.Name, data[item.Table.Name], fieldErrors)
+		}
+		if item.Sub != nil {
+			item.Sub.validateData(prefix+item.Sub.Name, data[item.Sub.Name], scope, fieldErrors)
+		}
+	}
+} //Section.validateData()
+
+func (t Table) validateData(path string, value interface{}, fieldErrors FieldErrors) {
+	rows, err := dataRows(value)
+	if err != nil {
+		fieldErrors[path] = err.Error()
+		return
+	}
+	if len(rows) < t.Min {
+		fieldErrors[path] = fmt.Sprintf("has %d rows, expecting at least %d", len(rows), t.Min)
+	}
+	if len(rows) > t.Max {
+		fieldErrors[path] = fmt.Sprintf("has %d rows, expecting at most %d", len(rows), t.Max)
+	}
+	rowIndexByKey := map[string]int{}
+	for rowIndex, row := range rows {
+		rowPath := fmt.Sprintf("%s[%d]", path, rowIndex)
+		for _, f := range t.Fields {
+			value, present := row[f.Name]
+			if err := f.ValidateValue(value, present); err != nil {
+				fieldErrors[rowPath+"."+f.Name] = err.Error()
+			}
+		}
+		for n := range row {
+			if !t.hasField(n) {
+				fieldErrors[rowPath+"."+n] = "unknown field"
+			}
+		}
+		//uniq fields together form the key of each row
+		keyValues := []string{}
+		for _, u := range t.Uniq {
+			values, _ := dataValues(row[u])
+			keyValues = append(keyValues, strings.Join(values, ","))
+		}
+		key := strings.Join(keyValues, "|")
+		if otherRowIndex, ok := rowIndexByKey[key]; ok {
+			fieldErrors[rowPath] = fmt.Sprintf("duplicates %s[%d] in %s", path, otherRowIndex, strings.Join(t.Uniq, ","))
+		} else {
+			rowIndexByKey[key] = rowIndex
+		}
+	}
+} //Table.validateData()
+
+func (t Table) coerceData(path string, value interface{}, fieldErrors FieldErrors) []interface{} {
+	rows, err := dataRows(value)
+	if err != nil {
+		fieldErrors[path] = err.Error()
+		return nil
+	}
+	if rows == nil {
+		return nil
+	}
+	coercedRows := make([]interface{}, 0, len(rows))
+	for rowIndex, row := range rows {
+		coercedRow := map[string]interface{}{}
+		for n, v := range row {
+			coercedRow[n] = v
+		}
+		for _, f := range t.Fields {
+			delete(coercedRow, f.Name)
+			if value, err := f.CoerceValue(row[f.Name]); err != nil {
+				fieldErrors[fmt.Sprintf("%s[%d].%s", path, rowIndex, f.Name)] = err.Error()
+			} else if value != nil {
+				coercedRow[f.Name] = value
+			}
+		}
+		coercedRows = append(coercedRows, coercedRow)
+	}
+	return coercedRows
+} //Table.coerceData()
+
+func (t Table) hasField(name string) bool {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+} //Table.hasField()
+
+func (s Sub) coerceData(path string, value interface{}, fieldErrors FieldErrors) []interface{} {
+	instances, err := dataRows(value)
+	if err != nil {
+		fieldErrors[path] = err.Error()
+		return nil
+	}
+	if instances == nil || s.Section == nil {
+		return nil
+	}
+	coercedInstances := make([]interface{}, 0, len(instances))
+	for i, instance := range instances {
+		coercedInstance := map[string]interface{}{}
+		for n, v := range instance {
+			coercedInstance[n] = v
+		}
+		s.Section.coerceData(fmt.Sprintf("%s[%d].", path, i), instance, coercedInstance, fieldErrors)
+		coercedInstances = append(coercedInstances, coercedInstance)
+	}
+	return coercedInstances
+} //Sub.coerceData()
+
+func (s Sub) validateData(path string, value interface{}, scope *conditionScope, fieldErrors FieldErrors) {
+	instances, err := dataRows(value)
+	if err != nil {
+		fieldErrors[path] = err.Error()
+		return
+	}
+	if len(instances) < s.Min {
+		fieldErrors[path] = fmt.Sprintf("has %d entries, expecting at least %d", len(instances), s.Min)
+	}
+	if len(instances) > s.Max {
+		fieldErrors[path] = fmt.Sprintf("has %d entries, expecting at most %d", len(instances), s.Max)
+	}
+	if s.Section == nil {
+		return
+	}
+	names := map[string]bool{}
+	for _, n := range s.Section.ItemNames() {
+		names[n] = true
+	}
+	for i, instance := range instances {
+		instancePath := fmt.Sprintf("%s[%d]", path, i)
+		s.Section.validateData(instancePath+".", instance, newConditionScope(scope, []Section{*s.Section}, instance), fieldErrors)
+		for n := range instance {
+			if !names[n] {
+				fieldErrors[instancePath+"."+n] = "unknown field"
+			}
+		}
+	}
+} //Sub.validateData()
+
+// ValidateValue checks a single submitted value against the field constraints.
+// present is false when the data has no value for the field at all.
+// Errors are replaced with the custom messages when the field has them.
+func (f Field) ValidateValue(value interface{}, present bool) error {
+	if !dataHasValue(value) {
+		if f.Required {
+			return f.requiredError()
+		}
+		if !present {
+			return nil
+		}
+	}
+	if err := f.validateValue(value); err != nil {
+		if f.Messages != nil && f.Messages.Invalid != "" {
+			return errors.Errorf("%s", f.Messages.Invalid)
+		}
+		return err
+	}
+	return nil
+} //Field.ValidateValue()
+
+func (f Field) requiredError() error {
+	if f.Messages != nil && f.Messages.Required != "" {
+		return errors.Errorf("%s", f.Messages.Required)
+	}
+	return errors.Errorf("required")
+} //Field.requiredError()
+
+func (f Field) validateValue(value interface{}) error {
+	values, err := dataValues(value)
+	if err != nil {
+		return err
+	}
+	if f.Selection != nil {
+		return f.Selection.validateValues(values)
+	}
+	if len(values) > 1 {
+		return errors.Errorf("has %d values, expecting one", len(values))
+	}
+	s := ""
+	if len(values) == 1 {
+		s = values[0]
+	}
+	switch {
+	case f.Short != nil:
+		return f.Short.validateValue(s)
+	case f.Text != nil:
+		return f.Text.validateValue(s)
+	}
+	if s == "" {
+		//not entered
+		return nil
+	}
+	switch {
+	case f.Integer != nil:
+		return f.Integer.validateValue(s)
+	case f.Number != nil:
+		return f.Number.validateValue(s)
+	case f.Date != nil:
+		return f.Date.validateValue(s)
+	case f.Time != nil:
+		return f.Time.validateValue(s)
+	case f.Choice != nil:
+		return f.Choice.validateValue(s)
+	}
+	return nil
+} //Field.validateValue()
+
+// CoerceValue converts a submitted value into the type stored for this kind of
+// field (see Form.CoerceData). It returns nil when no value was entered.
+func (f Field) CoerceValue(value interface{}) (interface{}, error) {
+	if f.Computed != nil {
+		//calculated by Form.CoerceData()
+		return nil, nil
+	}
+	values, err := dataValues(value)
+	if err != nil {
+		return nil, err
+	}
+	if f.Selection != nil {
+		selected := []string{}
+		for _, v := range values {
+			if v != "" {
+				selected = append(selected, v)
+			}
+		}
+		return selected, nil
+	}
+	if len(values) > 1 {
+		return nil, errors.Errorf("has %d values, expecting one", len(values))
+	}
+	if len(values) == 0 || values[0] == "" {
+		return nil, nil
+	}
+	s := values[0]
+	switch {
+	case f.Integer != nil:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("\"%s\" is not an integer", s)
+		}
+		return i, nil
+	case f.Number != nil:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errors.Errorf("\"%s\" is not a number", s)
+		}
+		return n, nil
+	case f.Date != nil:
+		d, err := parseDate(s)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	return s, nil
+} //Field.CoerceValue()
+
+func (s Short) validateValue(v string) error {
+	if err := validateLength(v, s.MinLen, s.MaxLen); err != nil {
+		return err
+	}
+	if s.Regex != nil && v != "" {
+		re, err := regexp.Compile(*s.Regex)
+		if err != nil {
+			return errors.Errorf("invalid regex:\"%s\"", *s.Regex)
+		}
+		if !re.MatchString(v) {
+			return errors.Errorf("\"%s\" does not match the expected format", v)
+		}
+	}
+	return nil
+} //Short.validateValue()
+
+func (t Text) validateValue(v string) error {
+	return validateLength(v, t.MinLen, t.MaxLen)
+} //Text.validateValue()
+
+func validateLength(v string, minLen, maxLen *int) error {
+	l := utf8.RuneCountInString(v)
+	if minLen != nil && l < *minLen {
+		return errors.Errorf("length %d is shorter than %d", l, *minLen)
+	}
+	if maxLen != nil && l > *maxLen {
+		return errors.Errorf("length %d is longer than %d", l, *maxLen)
+	}
+	return nil
+} //validateLength()
+
+func (i Integer) validateValue(v string) error {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return errors.Errorf("\"%s\" is not an integer", v)
+	}
+	if i.Min != nil && n < int64(*i.Min) {
+		return errors.Errorf("%d is less than %d", n, *i.Min)
+	}
+	if i.Max != nil && n > int64(*i.Max) {
+		return errors.Errorf("%d is more than %d", n, *i.Max)
+	}
+	return nil
+} //Integer.validateValue()
+
+func (i Number) validateValue(v string) error {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return errors.Errorf("\"%s\" is not a number", v)
+	}
+	if i.Min != nil && n < *i.Min {
+		return errors.Errorf("%v is less than %v", n, *i.Min)
+	}
+	if i.Max != nil && n > *i.Max {
+		return errors.Errorf("%v is more than %v", n, *i.Max)
+	}
+	return nil
+} //Number.validateValue()
+
+func (d Date) validateValue(v string) error {
+	t, err := parseDate(v)
+	if err != nil {
+		return err
+	}
+	if d.Min != nil {
+		if min, err := time.Parse("2006-01-02", *d.Min); err == nil && t.Before(min) {
+			return errors.Errorf("\"%s\" is before %s", v, *d.Min)
+		}
+	}
+	if d.Max != nil {
+		if max, err := time.Parse("2006-01-02", *d.Max); err == nil && t.After(max) {
+			return errors.Errorf("\"%s\" is after %s", v, *d.Max)
+		}
+	}
+	return nil
+} //Date.validateValue()
+
+// parseDate accepts CCYY-MM-DD as entered in a form, or the RFC3339 timestamp
+// of a coerced date after it was encoded as JSON
+func parseDate(v string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.Errorf("\"%s\" is not CCYY-MM-DD", v)
+} //parseDate()
+
+func (d Time) validateValue(v string) error {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return errors.Errorf("\"%s\" is not HH:MM", v)
+	}
+	if d.Min != nil {
+		if min, err := time.Parse("15:04", *d.Min); err == nil && t.Before(min) {
+			return errors.Errorf("\"%s\" is before %s", v, *d.Min)
+		}
+	}
+	if d.Max != nil {
+		if max, err := time.Parse("15:04", *d.Max); err == nil && t.After(max) {
+			return errors.Errorf("\"%s\" is after %s", v, *d.Max)
+		}
+	}
+	return nil
+} //Time.validateValue()
+
+func (c Choice) validateValue(v string) error {
+	if !hasOption(c.Options, v) {
+		return errors.Errorf("\"%s\" is not one of the options", v)
+	}
+	return nil
+} //Choice.validateValue()
+
+func (s Selection) validateValues(values []string) error {
+	selected := map[string]bool{}
+	for _, v := range values {
+		if !hasOption(s.Options, v) {
+			return errors.Errorf("\"%s\" is not one of the options", v)
+		}
+		if selected[v] {
+			return errors.Errorf("\"%s\" selected more than once", v)
+		}
+		selected[v] = true
+	}
+	return nil
+} //Selection.validateValues()
+
+func hasOption(options []Option, value string) bool {
+	for _, o := range options {
+		if o.Value == value {
+			return true
+		}
+	}
+	return false
+} //hasOption()
+
+// dataValues returns the string representation of a value in doc data,
+// which may be a single value or a list of values
+func dataValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			s, err := dataScalar(e)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	s, err := dataScalar(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{s}, nil
+} //dataValues()
+
+func dataScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format("2006-01-02"), nil
+	}
+	return "", errors.Errorf("unexpected value type %T", value)
+} //dataScalar()
+
+// dataHasValue is true when a value was entered, i.e. not nil, "" or an empty list
+func dataHasValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return v != ""
+	case []string:
+		return len(v) > 0
+	case []interface{}:
+		return len(v) > 0
+	case []map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+} //dataHasValue()
+
+// dataRows returns the list of objects stored for a table or sub
+func dataRows(value interface{}) ([]map[string]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []map[string]interface{}:
+		return v, nil
+	case []interface{}:
+		rows := make([]map[string]interface{}, 0, len(v))
+		for i, e := range v {
+			row, ok := e.(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("[%d] is %T instead of an object", i, e)
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+	return nil, errors.Errorf("is %T instead of a list of objects", value)
+} //dataRows()
+
+// InputData converts stored doc data back to the values posted by the form
+// inputs, e.g. to edit a submitted doc. Dates that were stored as timestamps
+// are shown as CCYY-MM-DD and numbers as they would be entered.
+func (f Form) InputData(data map[string]interface{}) map[string]interface{} {
+	if coerced, err := f.CoerceData(data); err == nil {
+		data = coerced
+	}
+	return inputData(data).(map[string]interface{})
+} //Form.InputData()
+
+func inputData(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		input := map[string]interface{}{}
+		for n, e := range v {
+			if e != nil {
+				input[n] = inputData(e)
+			}
+		}
+		return input
+	case []map[string]interface{}:
+		rows := make([]interface{}, 0, len(v))
+		for _, row := range v {
+			rows = append(rows, inputData(row))
+		}
+		return rows
+	case []interface{}:
+		if rows, err := dataRows(v); err == nil && len(rows) > 0 {
+			return inputData(rows)
+		}
+		if values, err := dataValues(v); err == nil {
+			return values
+		}
+	case []string:
+		return v
+	default:
+		if s, err := dataScalar(v); err == nil {
+			return s
+		}
+	}
+	return value
+} //inputData()
+
+// Value returns the value at path in Form.Values to display in an input, e.g.
+// "name", "table_1[0].f1" or "sub_1[1].f2" (the same paths as FieldErrors).
+// It returns "" when there is no value.
+func (f Form) Value(path string) string {
+	values, _ := dataValues(dataAt(f.Values, path))
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+} //Form.Value()
+
+// Selected is true when option is the value, or one of the values, at path
+// in Form.Values, to check choice and selection options
+func (f Form) Selected(path string, option string) bool {
+	values, _ := dataValues(dataAt(f.Values, path))
+	for _, v := range values {
+		if v == option {
+			return true
+		}
+	}
+	return false
+} //Form.Selected()
+
+// Rows returns the indexes of the table rows or sub instances at path in
+// Form.Values, with at least min rows, to render inputs for each of them
+func (f Form) Rows(path string, min int) []int {
+	rows, _ := dataRows(dataAt(f.Values, path))
+	n := len(rows)
+	if n < min {
+		n = min
+	}
+	indexes := make([]int, n)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+} //Form.Rows()
+
+// FieldInput is used in templates to render the input for a field with
+// the value and error at its path, e.g. a column in a table row
+type FieldInput struct {
+	Form  Form
+	Name  string //name of the HTML input
+	Path  string //path in Form.Values and Form.Errors
+	Field Field
+}
+
+// Input returns a FieldInput for the field, which can be a Field or *Field
+// because templates cannot take the address of a value
+func (f Form) Input(name string, path string, field interface{}) FieldInput {
+	input := FieldInput{Form: f, Name: name, Path: path}
+	switch field := field.(type) {
+	case Field:
+		input.Field = field
+	case *Field:
+		input.Field = *field
+	}
+	return input
+} //Form.Input()
+
+// Comments returns the reviewer comments on the field, none for inputs of new
+// rows that have no path yet
+func (i FieldInput) Comments() []string {
+	if i.Path == "" {
+		return nil
+	}
+	return i.Form.Comments[i.Path]
+}
+
+func (i FieldInput) Value() string {
+	return i.Form.Value(i.Path)
+}
+
+func (i FieldInput) Selected(option string) bool {
+	return i.Form.Selected(i.Path, option)
+}
+
+// ErrorMessage is the reason why the submitted value was rejected, or ""
+func (i FieldInput) ErrorMessage() string {
+	return i.Form.Errors[i.Path]
+}
+
+// SectionItems is used in templates to render the items of a section or of
+// a sub section instance, with input names and paths of its items prefixed
+// by those of the instance, e.g. "<section>__sub_1[1]." and "sub_1[1]."
+type SectionItems struct {
+	Form    Form
+	Name    string //prefix of HTML input names
+	Path    string //prefix of paths in Form.Values and Form.Errors
+	Section Section
+}
+
+// Items returns SectionItems for the section, which can be a Section or
+// *Section as found in Sub
+func (f Form) Items(name string, path string, section interface{}) SectionItems {
+	items := SectionItems{Form: f, Name: name, Path: path}
+	switch section := section.(type) {
+	case Section:
+		items.Section = section
+	case *Section:
+		if section != nil {
+			items.Section = *section
+		}
+	}
+	return items
+} //Form.Items()
+
+// dataAt returns the value at path in doc data, or nil when not found
+func dataAt(data map[string]interface{}, path string) interface{} {
+	var value interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		name := part
+		indexes := []int{}
+		for strings.HasSuffix(name, "]") {
+			open := strings.LastIndex(name, "[")
+			if open < 0 {
+				return nil
+			}
+			i, err := strconv.Atoi(name[open+1 : len(name)-1])
+			if err != nil {
+				return nil
+			}
+			indexes = append([]int{i}, indexes...)
+			name = name[:open]
+		}
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = obj[name]
+		for _, i := range indexes {
+			rows, err := dataRows(value)
+			if err != nil || i < 0 || i >= len(rows) {
+				return nil
+			}
+			value = rows[i]
+		}
+	}
+	return value
+} //dataAt()
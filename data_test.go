@@ -0,0 +1,296 @@
+package forms
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testForm parses and validates a form from JSON
+func testForm(t *testing.T, jsonForm string) Form {
+	t.Helper()
+	var f Form
+	if err := json.Unmarshal([]byte(jsonForm), &f); err != nil {
+		t.Fatalf("failed to parse form: %+v", err)
+	}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("invalid form: %+v", err)
+	}
+	return f
+} //testForm()
+
+const testDataForm = `{
+	"title":"Camp",
+	"sections":[{
+		"name":"main",
+		"title":"Main",
+		"items":[
+			{"field":{"title":"Name","name":"name","required":true,"short":{"max_length":10}}},
+			{"field":{"title":"Age","name":"age","integer":{"min":1,"max":99}}},
+			{"field":{"title":"Tent","name":"tent","choice":{"options":[{"title":"Yes","value":"yes"},{"title":"No","value":"no"}]}}},
+			{"field":{"title":"Tent size","name":"tent_size","short":{}},"required_if":"tent == \"yes\""},
+			{"table":{"title":"Kids","name":"kids","min":0,"max":3,"uniq":["kid_name"],"fields":[
+				{"title":"Kid","name":"kid_name","required":true,"short":{}},
+				{"title":"Kid age","name":"kid_age","integer":{"max":17}}
+			]}},
+			{"sub":{"title":"Guests","name":"guests","min":0,"max":2,"section":{
+				"name":"guest",
+				"title":"Guest",
+				"items":[
+					{"field":{"title":"Guest name","name":"guest_name","required":true,"short":{}}}
+				]
+			}}}
+		]
+	}]
+}`
+
+func TestValidateData(t *testing.T) {
+	f := testForm(t, testDataForm)
+	tests := []struct {
+		name   string
+		data   map[string]interface{}
+		errors FieldErrors
+	}{
+		{
+			name: "valid",
+			data: map[string]interface{}{"name": "Anna", "age": int64(12)},
+		},
+		{
+			name:   "required",
+			data:   map[string]interface{}{"age": int64(12)},
+			errors: FieldErrors{"name": "required"},
+		},
+		{
+			name:   "required empty",
+			data:   map[string]interface{}{"name": ""},
+			errors: FieldErrors{"name": "required"},
+		},
+		{
+			name:   "unknown field",
+			data:   map[string]interface{}{"name": "Anna", "x": "1"},
+			errors: FieldErrors{"x": "unknown field"},
+		},
+		{
+			name: "required_if false",
+			data: map[string]interface{}{"name": "Anna", "tent": "no"},
+		},
+		{
+			name:   "required_if true",
+			data:   map[string]interface{}{"name": "Anna", "tent": "yes"},
+			errors: FieldErrors{"tent_size": "required"},
+		},
+		{
+			name: "required_if true with value",
+			data: map[string]interface{}{"name": "Anna", "tent": "yes", "tent_size": "2p"},
+		},
+		{
+			name: "table row errors",
+			data: map[string]interface{}{"name": "Anna", "kids": []interface{}{
+				map[string]interface{}{"kid_name": "Ben", "kid_age": int64(8)},
+				map[string]interface{}{"kid_age": int64(3), "x": "1"},
+			}},
+			errors: FieldErrors{"kids[1].kid_name": "required", "kids[1].x": "unknown field"},
+		},
+		{
+			name: "table duplicate rows",
+			data: map[string]interface{}{"name": "Anna", "kids": []interface{}{
+				map[string]interface{}{"kid_name": "Ben"},
+				map[string]interface{}{"kid_name": "Ben"},
+			}},
+			errors: FieldErrors{"kids[1]": ""},
+		},
+		{
+			name: "table too many rows",
+			data: map[string]interface{}{"name": "Anna", "kids": []interface{}{
+				map[string]interface{}{"kid_name": "A"},
+				map[string]interface{}{"kid_name": "B"},
+				map[string]interface{}{"kid_name": "C"},
+				map[string]interface{}{"kid_name": "D"},
+			}},
+			errors: FieldErrors{"kids": ""},
+		},
+		{
+			name: "sub instance errors",
+			data: map[string]interface{}{"name": "Anna", "guests": []interface{}{
+				map[string]interface{}{"guest_name": "Carl"},
+				map[string]interface{}{"y": "1"},
+			}},
+			errors: FieldErrors{"guests[1].guest_name": "required", "guests[1].y": "unknown field"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := f.ValidateData(test.data)
+			if test.errors == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %+v", err)
+				}
+				return
+			}
+			fieldErrors, ok := err.(FieldErrors)
+			if !ok {
+				t.Fatalf("expected FieldErrors, got (%T)%+v", err, err)
+			}
+			if len(fieldErrors) != len(test.errors) {
+				t.Fatalf("got errors %v, expected %v", fieldErrors, test.errors)
+			}
+			for path, msg := range test.errors {
+				got, ok := fieldErrors[path]
+				if !ok {
+					t.Errorf("missing error for %s in %v", path, fieldErrors)
+				} else if msg != "" && got != msg {
+					t.Errorf("%s: got \"%s\", expected \"%s\"", path, got, msg)
+				}
+			}
+		})
+	}
+} //TestValidateData()
+
+func TestValidateDataErrorValues(t *testing.T) {
+	f := testForm(t, testDataForm)
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		path string
+	}{
+		{"short too long", map[string]interface{}{"name": "Anna-Maria-Louise"}, "name"},
+		{"integer below min", map[string]interface{}{"name": "Anna", "age": int64(0)}, "age"},
+		{"integer above max", map[string]interface{}{"name": "Anna", "age": int64(100)}, "age"},
+		{"choice not an option", map[string]interface{}{"name": "Anna", "tent": "maybe"}, "tent"},
+		{"table column above max", map[string]interface{}{"name": "Anna", "kids": []interface{}{map[string]interface{}{"kid_name": "Ben", "kid_age": int64(18)}}}, "kids[0].kid_age"},
+		{"table not a list", map[string]interface{}{"name": "Anna", "kids": "Ben"}, "kids"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fieldErrors, ok := f.ValidateData(test.data).(FieldErrors)
+			if !ok {
+				t.Fatalf("expected FieldErrors")
+			}
+			if _, ok := fieldErrors[test.path]; !ok || len(fieldErrors) != 1 {
+				t.Fatalf("got errors %v, expected only %s", fieldErrors, test.path)
+			}
+		})
+	}
+} //TestValidateDataErrorValues()
+
+const testCoerceForm = `{
+	"title":"Order",
+	"sections":[{
+		"name":"main",
+		"title":"Main",
+		"items":[
+			{"field":{"title":"Name","name":"name","short":{}}},
+			{"field":{"title":"Count","name":"count","integer":{}}},
+			{"field":{"title":"Price","name":"price","number":{}}},
+			{"field":{"title":"Date","name":"date","date":{}}},
+			{"field":{"title":"Extras","name":"extras","selection":{"options":[{"title":"A","value":"a"},{"title":"B","value":"b"}]}}},
+			{"table":{"title":"Lines","name":"lines","min":0,"max":5,"uniq":["qty"],"fields":[
+				{"title":"Qty","name":"qty","integer":{}}
+			]}},
+			{"sub":{"title":"People","name":"people","min":0,"max":5,"section":{
+				"name":"person",
+				"title":"Person",
+				"items":[
+					{"field":{"title":"Age","name":"age","integer":{}}}
+				]
+			}}}
+		]
+	}]
+}`
+
+func TestCoerceData(t *testing.T) {
+	f := testForm(t, testCoerceForm)
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name:     "posted strings",
+			data:     map[string]interface{}{"name": []string{"Anna"}, "count": []string{"3"}, "price": "1.5", "date": "2023-02-01"},
+			expected: map[string]interface{}{"extras": []string{}, "name": "Anna", "count": int64(3), "price": 1.5, "date": time.Date(2023, 2, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "empty values are omitted",
+			data:     map[string]interface{}{"name": "", "count": []string{""}, "price": nil},
+			expected: map[string]interface{}{"extras": []string{}}, //no options selected
+		},
+		{
+			name:     "selection",
+			data:     map[string]interface{}{"extras": []string{"a", "", "b"}},
+			expected: map[string]interface{}{"extras": []string{"a", "b"}},
+		},
+		{
+			name:     "typed values from JSON",
+			data:     map[string]interface{}{"count": float64(3), "price": float64(2)},
+			expected: map[string]interface{}{"extras": []string{}, "count": int64(3), "price": float64(2)},
+		},
+		{
+			name: "table and sub",
+			data: map[string]interface{}{
+				"lines":  []interface{}{map[string]interface{}{"qty": "2"}},
+				"people": []interface{}{map[string]interface{}{"age": "7"}},
+			},
+			expected: map[string]interface{}{
+				"extras": []string{},
+				"lines":  []interface{}{map[string]interface{}{"qty": int64(2)}},
+				"people": []interface{}{map[string]interface{}{"age": int64(7)}},
+			},
+		},
+		{
+			name:     "unknown names are kept",
+			data:     map[string]interface{}{"x": "1"},
+			expected: map[string]interface{}{"extras": []string{}, "x": "1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			coerced, err := f.CoerceData(test.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if !reflect.DeepEqual(coerced, test.expected) {
+				t.Fatalf("got %#v, expected %#v", coerced, test.expected)
+			}
+		})
+	}
+} //TestCoerceData()
+
+func TestCoerceDataErrors(t *testing.T) {
+	f := testForm(t, testCoerceForm)
+	tests := []struct {
+		name  string
+		data  map[string]interface{}
+		paths []string
+	}{
+		{"not an integer", map[string]interface{}{"count": "3x"}, []string{"count"}},
+		{"fraction is not an integer", map[string]interface{}{"count": "1.5"}, []string{"count"}},
+		{"not a number", map[string]interface{}{"price": "abc"}, []string{"price"}},
+		{"not a date", map[string]interface{}{"date": "01/02/2023"}, []string{"date"}},
+		{"several values", map[string]interface{}{"name": []string{"a", "b"}}, []string{"name"}},
+		{"unexpected type", map[string]interface{}{"name": map[string]interface{}{}}, []string{"name"}},
+		{"table not a list", map[string]interface{}{"lines": "2"}, []string{"lines"}},
+		{"table row not an object", map[string]interface{}{"lines": []interface{}{"2"}}, []string{"lines"}},
+		{"table column", map[string]interface{}{"lines": []interface{}{map[string]interface{}{"qty": "1"}, map[string]interface{}{"qty": "x"}}}, []string{"lines[1].qty"}},
+		{"sub field", map[string]interface{}{"people": []interface{}{map[string]interface{}{"age": "old"}}}, []string{"people[0].age"}},
+		{"several errors", map[string]interface{}{"count": "x", "price": "y"}, []string{"count", "price"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			coerced, err := f.CoerceData(test.data)
+			fieldErrors, ok := err.(FieldErrors)
+			if !ok {
+				t.Fatalf("expected FieldErrors, got (%T)%+v and %+v", err, err, coerced)
+			}
+			if len(fieldErrors) != len(test.paths) {
+				t.Fatalf("got errors %v, expected %v", fieldErrors, test.paths)
+			}
+			for _, path := range test.paths {
+				if _, ok := fieldErrors[path]; !ok {
+					t.Errorf("missing error for %s in %v", path, fieldErrors)
+				}
+			}
+		})
+	}
+} //TestCoerceDataErrors()
@@ -0,0 +1,130 @@
+package forms
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-msvc/errors"
+)
+
+// Change is a difference between two revisions of doc data or a form at a
+// path, e.g. "name", "table_1[0].f1" or "sub_1[1].f2" in doc data (the same
+// paths as FieldErrors) or "sections[0].items[2].field.title" in a form.
+// Table rows and sub instances are compared by index, so a row added to a
+// table is reported as added at its path with the whole row as value.
+type Change struct {
+	Path string      `json:"path"`
+	Op   ChangeOp    `json:"op"`
+	From interface{} `json:"from,omitempty" doc:"Value in the older revision, omitted when added"`
+	To   interface{} `json:"to,omitempty" doc:"Value in the newer revision, omitted when removed"`
+}
+
+type ChangeOp string
+
+const (
+	ChangeAdded   ChangeOp = "added"
+	ChangeRemoved ChangeOp = "removed"
+	ChangeChanged ChangeOp = "changed"
+)
+
+// DiffData returns the changes from one revision of doc data to another,
+// sorted by path. Values that are empty in both, e.g. nil and "", are equal.
+func DiffData(from, to map[string]interface{}) ([]Change, error) {
+	var fromJSON, toJSON interface{}
+	if err := jsonValue(from, &fromJSON); err != nil {
+		return nil, errors.Wrapf(err, "invalid from data")
+	}
+	if err := jsonValue(to, &toJSON); err != nil {
+		return nil, errors.Wrapf(err, "invalid to data")
+	}
+	changes := []Change{}
+	diffValues("", fromJSON, toJSON, &changes)
+	return changes, nil
+} //DiffData()
+
+// DiffForms returns the changes from one revision of a form to another,
+// ignoring the revision number, timestamp and who made the revision
+func DiffForms(from, to Form) ([]Change, error) {
+	var fromJSON, toJSON map[string]interface{}
+	if err := jsonValue(from, &fromJSON); err != nil {
+		return nil, errors.Wrapf(err, "invalid from form")
+	}
+	if err := jsonValue(to, &toJSON); err != nil {
+		return nil, errors.Wrapf(err, "invalid to form")
+	}
+	for _, n := range []string{"rev", "timestamp", "updated_by"} {
+		delete(fromJSON, n)
+		delete(toJSON, n)
+	}
+	changes := []Change{}
+	diffValues("", fromJSON, toJSON, &changes)
+	return changes, nil
+} //DiffForms()
+
+// jsonValue converts v to generic JSON values in value, so that typed values
+// such as dates and integers compare the same as when loaded from the store
+func jsonValue(v interface{}, value interface{}) error {
+	jsonV, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonV, value)
+} //jsonValue()
+
+func diffValues(path string, from, to interface{}, changes *[]Change) {
+	fromEmpty, toEmpty := !dataHasValue(from), !dataHasValue(to)
+	switch {
+	case fromEmpty && toEmpty:
+		return
+	case fromEmpty:
+		*changes = append(*changes, Change{Path: path, Op: ChangeAdded, To: to})
+		return
+	case toEmpty:
+		*changes = append(*changes, Change{Path: path, Op: ChangeRemoved, From: from})
+		return
+	}
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		names := map[string]bool{}
+		for n := range fromMap {
+			names[n] = true
+		}
+		for n := range toMap {
+			names[n] = true
+		}
+		sortedNames := make([]string, 0, len(names))
+		for n := range names {
+			sortedNames = append(sortedNames, n)
+		}
+		sort.Strings(sortedNames)
+		for _, n := range sortedNames {
+			namePath := n
+			if path != "" {
+				namePath = path + "." + n
+			}
+			diffValues(namePath, fromMap[n], toMap[n], changes)
+		}
+		return
+	}
+	fromRows, fromErr := dataRows(from)
+	toRows, toErr := dataRows(to)
+	if fromErr == nil && toErr == nil {
+		for i := 0; i < len(fromRows) || i < len(toRows); i++ {
+			var fromRow, toRow interface{}
+			if i < len(fromRows) {
+				fromRow = fromRows[i]
+			}
+			if i < len(toRows) {
+				toRow = toRows[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), fromRow, toRow, changes)
+		}
+		return
+	}
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, Change{Path: path, Op: ChangeChanged, From: from, To: to})
+	}
+} //diffValues()
@@ -7,12 +7,21 @@ import (
 )
 
 type Doc struct {
-	ID        string                 `json:"id,omitempty"`
-	Rev       int                    `json:"rev,omitempty"`
-	Timestamp time.Time              `json:"timestamp" doc:"Time when the doc revision was created"`
-	FormID    string                 `json:"form_id"`
-	FormRev   int                    `json:"form_rev"`
-	Data      map[string]interface{} `json:"data,omitempty" doc:"Submitted form data. Keys defined as name fields in the form."`
+	ID         string                 `json:"id,omitempty"`
+	Rev        int                    `json:"rev,omitempty"`
+	Timestamp  time.Time              `json:"timestamp" doc:"Time when the doc revision was created"`
+	FormID     string                 `json:"form_id"`
+	FormRev    int                    `json:"form_rev"`
+	CampaignID string                 `json:"campaign_id,omitempty" doc:"Campaign where the doc was submitted"`
+	UserID     string                 `json:"user_id,omitempty" doc:"User who submitted the doc"`
+	UpdatedBy  string                 `json:"updated_by,omitempty" doc:"User who made this revision, e.g. the submitter or someone who processed the doc"`
+	State      DocState               `json:"state,omitempty" doc:"Set by the service"`
+	Data       map[string]interface{} `json:"data,omitempty" doc:"Submitted form data. Keys defined as name fields in the form. Values are typed by the field, see Form.CoerceData()."`
+	Expires    *time.Time             `json:"expires,omitempty" doc:"Set by the service on a draft, which is deleted if not saved or submitted before this time"`
+	Reminded   *time.Time             `json:"reminded,omitempty" doc:"Set by the service when the user was reminded to submit the draft before the campaign ends"`
+	History    []DocTransition        `json:"history,omitempty" doc:"Set by the service, append-only list of state changes, see DocTransition"`
+	Queue      string                 `json:"queue,omitempty" doc:"Set by the service, processing queue of the campaign where the doc is, see Campaign.Queues"`
+	Moves      []DocMove              `json:"moves,omitempty" doc:"Set by the service, append-only list of moves between queues, see DocMove"`
 }
 
 func (f *Doc) Validate() error {
@@ -28,4 +37,16 @@ func (f *Doc) Validate() error {
 	return nil
 } //Doc.Validate()
 
+type DocState string
+
+const (
+	DocStateDraft       DocState = "draft" //work-in-progress that is not validated until submitted
+	DocStateSubmitted   DocState = "submitted"
+	DocStateInReview    DocState = "in_review"
+	DocStateReturned    DocState = "returned" //sent back to the submitter with comments to edit it
+	DocStateResubmitted DocState = "resubmitted"
+	DocStateAccepted    DocState = "accepted"
+	DocStateRejected    DocState = "rejected"
+)
+
 //todo: maintain foreign key between doc and form - but only one moved to a database...
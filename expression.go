@@ -0,0 +1,997 @@
+package forms
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-msvc/errors"
+)
+
+// Expression calculates a value from the values of other fields in the form.
+// It is used for computed fields and, as a Condition, for show_if and
+// required_if. Examples:
+//
+//	course_price[course] + sum(extra_price[extras])
+//	sum(kids.nr_days * 50) - discount
+//	if(age < 12, 100, 150)
+//	age >= 18 && (role in ["officer", "parent"] || !member)
+//
+// Operands are field names, "quoted" strings, numbers, true, false and lists
+// in [...]. Operators, from lowest to highest precedence, are || (or), && (and),
+// ! (not), == != < <= > >= in, + -, * / %, unary - and then .name to get a
+// column from each row of a table or sub, and [key] to look up a value.
+// Functions are sum(), count(), min(), max(), abs(), round(x[,decimals]) and
+// if(condition,then,else).
+//
+// Fields that are not entered or hidden have no value, which is not equal to
+// anything and counts as 0 in arithmetic. Arithmetic on a list, e.g. a table
+// column, applies to each value. Dates compare as "CCYY-MM-DD" strings.
+//
+// Evaluation only reads values from the doc data and lookup tables, so it has
+// no side effects and always gives the same result for the same data.
+type Expression string
+
+// maxExpressionLength limits the cost of parsing and evaluating an expression
+const maxExpressionLength = 1000
+
+// Validate checks the syntax of the expression. An empty expression is valid.
+func (e Expression) Validate() error {
+	_, err := e.parse()
+	return err
+}
+
+// Refs returns the sorted names referenced in the expression
+func (e Expression) Refs() ([]string, error) {
+	x, err := e.parse()
+	if err != nil {
+		return nil, err
+	}
+	if x == nil {
+		return nil, nil
+	}
+	names := map[string]bool{}
+	x.refs(names)
+	refs := make([]string, 0, len(names))
+	for n := range names {
+		refs = append(refs, n)
+	}
+	sort.Strings(refs)
+	return refs, nil
+}
+
+// Eval returns the value of the expression using value(name) to get field
+// values. The result is nil, bool, float64, string or a list of those.
+func (e Expression) Eval(value func(name string) interface{}) (interface{}, error) {
+	x, err := e.parse()
+	if err != nil {
+		return nil, err
+	}
+	if x == nil {
+		return nil, errors.Errorf("empty expression")
+	}
+	return x.eval(func(name string) interface{} {
+		return exprValue(value(name))
+	})
+}
+
+// JSON returns the parsed expression as a JSON tree that is evaluated in the
+// browser to show results while the form is being filled in.
+func (e Expression) JSON() (string, error) {
+	x, err := e.parse()
+	if err != nil {
+		return "", err
+	}
+	if x == nil {
+		return "", nil
+	}
+	jsonTree, err := json.Marshal(x.tree())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to encode expression")
+	}
+	return string(jsonTree), nil
+}
+
+func (e Expression) parse() (expr, error) {
+	if strings.TrimSpace(string(e)) == "" {
+		return nil, nil
+	}
+	if len(e) > maxExpressionLength {
+		return nil, errors.Errorf("longer than %d characters", maxExpressionLength)
+	}
+	tokens, err := exprTokens(string(e))
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	x, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, errors.Errorf("unexpected \"%s\" at offset %d", p.tokens[p.pos].text, p.tokens[p.pos].offset)
+	}
+	return x, nil
+} //Expression.parse()
+
+type exprToken struct {
+	kind   exprTokenKind
+	text   string
+	offset int
+}
+
+type exprTokenKind int
+
+const (
+	tokenName exprTokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+var exprOperators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%", ".", "(", ")", "[", "]", ","}
+
+// exprTokens splits the expression text into tokens
+func exprTokens(s string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i += size
+		case c == '"' || c == '\'':
+			//quoted string without escapes
+			end := strings.IndexRune(s[i+1:], c)
+			if end < 0 {
+				return nil, errors.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, exprToken{kind: tokenString, text: s[i+1 : i+1+end], offset: i})
+			i += end + 2
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: s[start:i], offset: start})
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(s) {
+				r, n := utf8.DecodeRuneInString(s[i:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += n
+			}
+			tokens = append(tokens, exprToken{kind: tokenName, text: s[start:i], offset: start})
+		default:
+			op := ""
+			for _, o := range exprOperators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, errors.Errorf("unexpected \"%c\" at offset %d", c, i)
+			}
+			tokens = append(tokens, exprToken{kind: tokenOperator, text: op, offset: i})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+} //exprTokens()
+
+// exprParser is a recursive descent parser for:
+//
+//	or      = and { ("||"|"or") and }
+//	and     = not { ("&&"|"and") not }
+//	not     = ("!"|"not") not | compare
+//	compare = sum [ ("=="|"!="|"<"|"<="|">"|">="|"in") sum ]
+//	sum     = product { ("+"|"-") product }
+//	product = unary { ("*"|"/"|"%") unary }
+//	unary   = "-" unary | postfix
+//	postfix = operand { "." name | "[" or "]" }
+//	operand = name | name "(" [or {"," or}] ")" | string | number | "true" | "false"
+//	        | "[" [or {"," or}] "]" | "(" or ")"
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) next() (exprToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return exprToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+// accept consumes the next token if it is one of the operators or keywords
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t, ok := p.next()
+	if !ok || t.kind == tokenString || t.kind == tokenNumber {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return x, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = opExpr{op: "or", args: []expr{x, right}}
+	}
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return x, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		x = opExpr{op: "and", args: []expr{x, right}}
+	}
+}
+
+func (p *exprParser) parseNot() (expr, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return opExpr{op: "not", args: []expr{x}}, nil
+	}
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (expr, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">", "in")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return opExpr{op: op, args: []expr{left, right}}, nil
+}
+
+func (p *exprParser) parseSum() (expr, error) {
+	x, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return x, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		x = opExpr{op: op, args: []expr{x, right}}
+	}
+}
+
+func (p *exprParser) parseProduct() (expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return x, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = opExpr{op: op, args: []expr{x, right}}
+	}
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if _, ok := p.accept("-"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return opExpr{op: "neg", args: []expr{x}}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (expr, error) {
+	x, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("."); ok {
+			t, ok := p.next()
+			if !ok || t.kind != tokenName {
+				return nil, errors.Errorf("missing name after \".\"")
+			}
+			p.pos++
+			x = memberExpr{x: x, name: t.text}
+			continue
+		}
+		if _, ok := p.accept("["); ok {
+			key, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept("]"); !ok {
+				return nil, errors.Errorf("missing \"]\"")
+			}
+			x = opExpr{op: "[]", args: []expr{x, key}}
+			continue
+		}
+		return x, nil
+	}
+}
+
+func (p *exprParser) parseOperand() (expr, error) {
+	t, ok := p.next()
+	if !ok {
+		return nil, errors.Errorf("unexpected end of expression")
+	}
+	p.pos++
+	switch t.kind {
+	case tokenString:
+		return literalExpr{value: t.text}, nil
+	case tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid number \"%s\" at offset %d", t.text, t.offset)
+		}
+		return literalExpr{value: n}, nil
+	case tokenName:
+		switch t.text {
+		case "true":
+			return literalExpr{value: true}, nil
+		case "false":
+			return literalExpr{value: false}, nil
+		case "and", "or", "not", "in":
+			return nil, errors.Errorf("unexpected \"%s\" at offset %d", t.text, t.offset)
+		}
+		if _, ok := p.accept("("); ok {
+			fn, ok := exprFuncs[t.text]
+			if !ok {
+				return nil, errors.Errorf("unknown function %s() at offset %d", t.text, t.offset)
+			}
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s() arguments", t.text)
+			}
+			if len(args) < fn.minArgs || (fn.maxArgs > 0 && len(args) > fn.maxArgs) {
+				return nil, errors.Errorf("%s() with %d arguments", t.text, len(args))
+			}
+			return callExpr{name: t.text, args: args}, nil
+		}
+		return refExpr{name: t.text}, nil
+	}
+	switch t.text {
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, errors.Errorf("missing \")\" for \"(\" at offset %d", t.offset)
+		}
+		return x, nil
+	case "[":
+		items, err := p.parseList("]")
+		if err != nil {
+			return nil, err
+		}
+		return listExpr{items: items}, nil
+	}
+	return nil, errors.Errorf("unexpected \"%s\" at offset %d", t.text, t.offset)
+} //exprParser.parseOperand()
+
+// parseList parses comma separated expressions up to the end token
+func (p *exprParser) parseList(end string) ([]expr, error) {
+	items := []expr{}
+	if _, ok := p.accept(end); ok {
+		return items, nil
+	}
+	for {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, x)
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+		if _, ok := p.accept(end); ok {
+			return items, nil
+		}
+		return nil, errors.Errorf("missing \"%s\"", end)
+	}
+} //exprParser.parseList()
+
+// expr is a node in a parsed expression
+type expr interface {
+	eval(value func(name string) interface{}) (interface{}, error)
+	refs(names map[string]bool)
+	tree() interface{}
+}
+
+type refExpr struct {
+	name string
+}
+
+func (x refExpr) eval(value func(name string) interface{}) (interface{}, error) {
+	return value(x.name), nil
+}
+
+func (x refExpr) refs(names map[string]bool) { names[x.name] = true }
+
+func (x refExpr) tree() interface{} { return map[string]interface{}{"ref": x.name} }
+
+type literalExpr struct {
+	value interface{} //string, float64 or bool
+}
+
+func (x literalExpr) eval(value func(name string) interface{}) (interface{}, error) {
+	return x.value, nil
+}
+
+func (x literalExpr) refs(names map[string]bool) {}
+
+func (x literalExpr) tree() interface{} { return map[string]interface{}{"value": x.value} }
+
+type listExpr struct {
+	items []expr
+}
+
+func (x listExpr) eval(value func(name string) interface{}) (interface{}, error) {
+	return evalAll(x.items, value)
+}
+
+func (x listExpr) refs(names map[string]bool) { refsAll(x.items, names) }
+
+func (x listExpr) tree() interface{} {
+	return map[string]interface{}{"list": treeAll(x.items)}
+}
+
+type memberExpr struct {
+	x    expr
+	name string
+}
+
+func (x memberExpr) eval(value func(name string) interface{}) (interface{}, error) {
+	v, err := x.x.eval(value)
+	if err != nil {
+		return nil, err
+	}
+	return member(v, x.name), nil
+}
+
+func (x memberExpr) refs(names map[string]bool) { x.x.refs(names) }
+
+func (x memberExpr) tree() interface{} {
+	return map[string]interface{}{"op": ".", "name": x.name, "args": []interface{}{x.x.tree()}}
+}
+
+// member gets the named value from an object, or from each object in a list
+func member(v interface{}, name string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v[name]
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			list = append(list, member(e, name))
+		}
+		return list
+	}
+	return nil
+} //member()
+
+type opExpr struct {
+	op   string
+	args []expr
+}
+
+func (x opExpr) eval(value func(name string) interface{}) (interface{}, error) {
+	//logical operators do not evaluate the right side when not needed
+	switch x.op {
+	case "and", "or":
+		l, err := x.args[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(l) == (x.op == "or") {
+			return x.op == "or", nil
+		}
+		r, err := x.args[1].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(r), nil
+	}
+	args, err := evalAll(x.args, value)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "not":
+		return !truthy(args[0]), nil
+	case "neg":
+		return arithmetic("-", 0.0, args[0])
+	case "==":
+		return valuesEqual(args[0], args[1]), nil
+	case "!=":
+		return !valuesEqual(args[0], args[1]), nil
+	case "in":
+		list, ok := args[1].([]interface{})
+		if !ok {
+			return false, nil
+		}
+		for _, e := range list {
+			if valuesEqual(args[0], e) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "<", "<=", ">", ">=":
+		c, ok := compareValues(args[0], args[1])
+		if !ok {
+			return false, nil
+		}
+		switch x.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "[]":
+		return index(args[0], args[1]), nil
+	}
+	return arithmetic(x.op, args[0], args[1])
+} //opExpr.eval()
+
+func (x opExpr) refs(names map[string]bool) { refsAll(x.args, names) }
+
+func (x opExpr) tree() interface{} {
+	return map[string]interface{}{"op": x.op, "args": treeAll(x.args)}
+}
+
+type callExpr struct {
+	name string
+	args []expr
+}
+
+func (x callExpr) eval(value func(name string) interface{}) (interface{}, error) {
+	if x.name == "if" {
+		//only evaluate the selected value
+		c, err := x.args[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(c) {
+			return x.args[1].eval(value)
+		}
+		return x.args[2].eval(value)
+	}
+	args, err := evalAll(x.args, value)
+	if err != nil {
+		return nil, err
+	}
+	result, err := exprFuncs[x.name].f(args)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s() failed", x.name)
+	}
+	return result, nil
+}
+
+func (x callExpr) refs(names map[string]bool) { refsAll(x.args, names) }
+
+func (x callExpr) tree() interface{} {
+	return map[string]interface{}{"op": "call", "fn": x.name, "args": treeAll(x.args)}
+}
+
+func evalAll(xs []expr, value func(name string) interface{}) ([]interface{}, error) {
+	values := make([]interface{}, 0, len(xs))
+	for _, x := range xs {
+		v, err := x.eval(value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func refsAll(xs []expr, names map[string]bool) {
+	for _, x := range xs {
+		x.refs(names)
+	}
+}
+
+func treeAll(xs []expr) []interface{} {
+	trees := make([]interface{}, 0, len(xs))
+	for _, x := range xs {
+		trees = append(trees, x.tree())
+	}
+	return trees
+}
+
+type exprFunc struct {
+	minArgs int
+	maxArgs int //0 for no limit
+	f       func(args []interface{}) (interface{}, error)
+}
+
+// exprFuncs are the functions that can be called in expressions
+var exprFuncs = map[string]exprFunc{
+	"sum": {minArgs: 1, f: func(args []interface{}) (interface{}, error) {
+		total := 0.0
+		for _, v := range flatten(args) {
+			n, err := number(v)
+			if err != nil {
+				return nil, err
+			}
+			total += n
+		}
+		return finite(total)
+	}},
+	"count": {minArgs: 1, f: func(args []interface{}) (interface{}, error) {
+		count := 0
+		for _, v := range flatten(args) {
+			if v != nil {
+				count++
+			}
+		}
+		return float64(count), nil
+	}},
+	"min": {minArgs: 1, f: func(args []interface{}) (interface{}, error) {
+		return extreme(flatten(args), -1)
+	}},
+	"max": {minArgs: 1, f: func(args []interface{}) (interface{}, error) {
+		return extreme(flatten(args), 1)
+	}},
+	"abs": {minArgs: 1, maxArgs: 1, f: func(args []interface{}) (interface{}, error) {
+		n, err := number(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return finite(math.Abs(n))
+	}},
+	"round": {minArgs: 1, maxArgs: 2, f: func(args []interface{}) (interface{}, error) {
+		n, err := number(args[0])
+		if err != nil {
+			return nil, err
+		}
+		decimals := 0.0
+		if len(args) > 1 {
+			if decimals, err = number(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		if decimals < 0 || decimals > 10 {
+			return nil, errors.Errorf("decimals:%v is not 0..10", decimals)
+		}
+		scale := math.Pow(10, math.Trunc(decimals))
+		return finite(math.Round(n*scale) / scale)
+	}},
+	"if": {minArgs: 3, maxArgs: 3}, //evaluated in callExpr.eval()
+}
+
+// flatten returns the values in args with lists replaced by their items,
+// skipping values that were not entered
+func flatten(args []interface{}) []interface{} {
+	values := []interface{}{}
+	for _, a := range args {
+		if list, ok := a.([]interface{}); ok {
+			values = append(values, flatten(list)...)
+		} else if a != nil {
+			values = append(values, a)
+		}
+	}
+	return values
+}
+
+// extreme returns the min (sign=-1) or max (sign=1) value, or nil for no values
+func extreme(values []interface{}, sign int) (interface{}, error) {
+	var result interface{}
+	for _, v := range values {
+		if result == nil {
+			result = v
+			continue
+		}
+		c, ok := compareValues(v, result)
+		if !ok {
+			return nil, errors.Errorf("cannot compare %v with %v", v, result)
+		}
+		if c == sign {
+			result = v
+		}
+	}
+	return result, nil
+}
+
+// index looks up a key in an object (e.g. a lookup table), an element in a
+// list by position, or each key in a list of keys
+func index(v interface{}, key interface{}) interface{} {
+	if keys, ok := key.([]interface{}); ok {
+		list := make([]interface{}, 0, len(keys))
+		for _, k := range keys {
+			list = append(list, index(v, k))
+		}
+		return list
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		k, ok := key.(string)
+		if n, isNumber := key.(float64); isNumber {
+			k, ok = strconv.FormatFloat(n, 'f', -1, 64), true
+		}
+		if !ok {
+			return nil
+		}
+		return v[k]
+	case []interface{}:
+		n, err := number(key)
+		if err != nil || n < 0 || int(n) >= len(v) || n != math.Trunc(n) {
+			return nil
+		}
+		return v[int(n)]
+	}
+	return nil
+} //index()
+
+// arithmetic applies op to numbers, or to each value when one or both are
+// lists of the same length
+func arithmetic(op string, a, b interface{}) (interface{}, error) {
+	aList, aIsList := a.([]interface{})
+	bList, bIsList := b.([]interface{})
+	if aIsList || bIsList {
+		if aIsList && bIsList && len(aList) != len(bList) {
+			return nil, errors.Errorf("%s on lists with %d and %d values", op, len(aList), len(bList))
+		}
+		n := len(aList)
+		if bIsList {
+			n = len(bList)
+		}
+		list := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			aValue, bValue := a, b
+			if aIsList {
+				aValue = aList[i]
+			}
+			if bIsList {
+				bValue = bList[i]
+			}
+			v, err := arithmetic(op, aValue, bValue)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	x, err := number(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := number(b)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "+":
+		return finite(x + y)
+	case "-":
+		return finite(x - y)
+	case "*":
+		return finite(x * y)
+	case "/":
+		if y == 0 {
+			return nil, errors.Errorf("division by zero")
+		}
+		return finite(x / y)
+	case "%":
+		if y == 0 {
+			return nil, errors.Errorf("division by zero")
+		}
+		return finite(math.Mod(x, y))
+	}
+	return nil, errors.Errorf("unknown operator %s", op)
+} //arithmetic()
+
+// finite returns n as the result of arithmetic, or an error when it overflowed
+// or is not a number, so that Inf and NaN are never stored in doc data
+func finite(n float64) (interface{}, error) {
+	if math.IsInf(n, 0) || math.IsNaN(n) {
+		return nil, errors.Errorf("result %v is not a finite number", n)
+	}
+	return n, nil
+} //finite()
+
+// number converts a value to a number for arithmetic, with nil as 0
+func number(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return v, nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, errors.Errorf("\"%s\" is not a number", v)
+		}
+		return n, nil
+	}
+	return 0, errors.Errorf("%T is not a number", v)
+} //number()
+
+// exprValue converts a value from doc data to the types used when evaluating
+// expressions: nil, bool, float64, string, []interface{} or map[string]interface{}
+func exprValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, bool, float64, string:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case time.Time:
+		return v.Format("2006-01-02")
+	case []string:
+		list := make([]interface{}, 0, len(v))
+		for _, s := range v {
+			list = append(list, s)
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			list = append(list, exprValue(e))
+		}
+		return list
+	case []map[string]interface{}:
+		list := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			list = append(list, exprValue(e))
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for n, e := range v {
+			obj[n] = exprValue(e)
+		}
+		return obj
+	case map[string]float64:
+		obj := make(map[string]interface{}, len(v))
+		for n, e := range v {
+			obj[n] = e
+		}
+		return obj
+	}
+	return fmt.Sprintf("%v", value)
+} //exprValue()
+
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	}
+	return true
+} //truthy()
+
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if aList, ok := a.([]interface{}); ok {
+		bList, ok := b.([]interface{})
+		if !ok || len(aList) != len(bList) {
+			return false
+		}
+		for i := range aList {
+			if !valuesEqual(aList[i], bList[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if aBool, ok := a.(bool); ok {
+		bBool, ok := b.(bool)
+		return ok && aBool == bBool
+	}
+	c, ok := compareValues(a, b)
+	return ok && c == 0
+} //valuesEqual()
+
+// compareValues compares numbers with numbers (also numeric strings such as
+// choice values) and strings with strings. ok is false for other values.
+func compareValues(a, b interface{}) (int, bool) {
+	aNumber, aIsNumber := a.(float64)
+	bNumber, bIsNumber := b.(float64)
+	aString, aIsString := a.(string)
+	bString, bIsString := b.(string)
+	if aIsNumber && bIsString {
+		n, err := strconv.ParseFloat(bString, 64)
+		if err != nil {
+			return 0, false
+		}
+		bNumber, bIsNumber = n, true
+	}
+	if aIsString && bIsNumber {
+		n, err := strconv.ParseFloat(aString, 64)
+		if err != nil {
+			return 0, false
+		}
+		aNumber, aIsNumber = n, true
+	}
+	switch {
+	case aIsNumber && bIsNumber:
+		switch {
+		case aNumber < bNumber:
+			return -1, true
+		case aNumber > bNumber:
+			return 1, true
+		}
+		return 0, true
+	case aIsString && bIsString:
+		return strings.Compare(aString, bString), true
+	}
+	return 0, false
+} //compareValues()
@@ -0,0 +1,152 @@
+package forms
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpressionEval(t *testing.T) {
+	values := map[string]interface{}{
+		"a":      int64(2),
+		"b":      3.0,
+		"name":   "Anna",
+		"member": true,
+		"diet":   []string{"vegetarian", "halal"},
+		"kids": []interface{}{
+			map[string]interface{}{"nr_days": int64(2)},
+			map[string]interface{}{"nr_days": int64(3)},
+		},
+		"price":  map[string]interface{}{"tent": 10.0, "room": 20.0},
+		"kind":   "room",
+		"prénom": "Zoë",
+	}
+	value := func(name string) interface{} { return values[name] }
+	tests := []struct {
+		expr     Expression
+		expected interface{}
+	}{
+		//precedence
+		{"1 + 2 * 3", 7.0},
+		//names and strings are not limited to ascii
+		{"prénom == \"Zoë\"", true},
+		{"(1 + 2) * 3", 9.0},
+		{"10 - 4 - 3", 3.0},
+		{"24 / 4 / 2", 3.0},
+		{"7 % 4 * 2", 6.0},
+		{"-a * b", -6.0},
+		{"--a", 2.0},
+		{"1 + 2 == 3", true},
+		{"!member || a > 1", true},
+		{"!(member || a > 1)", false},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"a > 1 and not member or name == \"Anna\"", true},
+		//operands
+		{"a + b", 5.0},
+		{"missing + 1", 1.0},
+		{"missing == 0", false},
+		{"name", "Anna"},
+		{"'single' == \"single\"", true},
+		{"\"vegetarian\" in diet", true},
+		{"kind in [\"tent\", \"room\"]", true},
+		{"price[kind]", 20.0},
+		{"price[\"x\"]", nil},
+		{"sum(kids.nr_days * 50)", 250.0},
+		{"count(kids.nr_days)", 2.0},
+		{"max(kids.nr_days, 1)", 3.0},
+		{"min(a, b)", 2.0},
+		{"abs(a - b)", 1.0},
+		{"round(10 / 3, 2)", 3.33},
+		{"if(a > 2, \"big\", \"small\")", "small"},
+		{"if(true, 1, 1 / 0)", 1.0},
+	}
+	for _, test := range tests {
+		t.Run(string(test.expr), func(t *testing.T) {
+			v, err := test.expr.Eval(value)
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if !reflect.DeepEqual(v, test.expected) {
+				t.Fatalf("got (%T)%v, expected (%T)%v", v, v, test.expected, test.expected)
+			}
+		})
+	}
+} //TestExpressionEval()
+
+func TestExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expr Expression
+		err  string
+	}{
+		//syntax
+		{"1 +", "unexpected end of expression"},
+		{"(1 + 2", "missing \")\""},
+		{"1 2", "unexpected \"2\" at offset 2"},
+		{"a $ b", "unexpected \"$\" at offset 2"},
+		{"a € b", "unexpected \"€\" at offset 2"},
+		{"\"open", "unterminated string"},
+		{"1.2.3", "invalid number"},
+		{"foo(1)", "unknown function foo()"},
+		{"abs(1, 2)", "abs() with 2 arguments"},
+		{"x.", "missing name after \".\""},
+		{"1 < 2 == true", "unexpected \"==\" at offset 6"}, //comparisons do not chain
+		{Expression(strings.Repeat("1+", 600) + "1"), "longer than 1000 characters"},
+		//evaluation
+		{"1 / 0", "division by zero"},
+		{"5 % 0", "division by zero"},
+		{"1 / (2 - 2)", "division by zero"},
+		{"\"a\" + 1", "\"a\" is not a number"},
+		{"[1, 2] + [1, 2, 3]", "+ on lists with 2 and 3 values"},
+		{"round(1, 11)", "decimals:11 is not 0..10"},
+		{Expression(strings.Repeat("9", 300) + " * " + strings.Repeat("9", 300)), "result +Inf is not a finite number"},
+		{Expression("-" + strings.Repeat("9", 300) + " * " + strings.Repeat("9", 300)), "result -Inf is not a finite number"},
+	}
+	for _, test := range tests {
+		t.Run(string(test.expr), func(t *testing.T) {
+			_, err := test.expr.Eval(func(string) interface{} { return nil })
+			if err == nil || !strings.Contains(fmt.Sprintf("%+v", err), test.err) {
+				t.Fatalf("got error %v, expected \"%s\"", err, test.err)
+			}
+		})
+	}
+} //TestExpressionErrors()
+
+func TestExpressionLength(t *testing.T) {
+	//"1+1+...+1" with exactly maxExpressionLength characters
+	e := Expression(strings.Repeat("1+", maxExpressionLength/2-1) + "11")
+	if len(e) != maxExpressionLength {
+		t.Fatalf("expression has %d characters", len(e))
+	}
+	if err := e.Validate(); err != nil {
+		t.Fatalf("expression of %d characters rejected: %+v", len(e), err)
+	}
+	if err := (e + " ").Validate(); err == nil {
+		t.Fatalf("expression of %d characters accepted", len(e)+1)
+	}
+} //TestExpressionLength()
+
+func TestExpressionRefs(t *testing.T) {
+	tests := []struct {
+		expr     Expression
+		expected []string
+	}{
+		{"", nil},
+		{"1 + 2", []string{}},
+		{"b + a * b", []string{"a", "b"}},
+		{"sum(kids.nr_days) + price[kind]", []string{"kids", "kind", "price"}},
+		{"if(x, \"y\", z)", []string{"x", "z"}},
+	}
+	for _, test := range tests {
+		t.Run(string(test.expr), func(t *testing.T) {
+			refs, err := test.expr.Refs()
+			if err != nil {
+				t.Fatalf("unexpected error: %+v", err)
+			}
+			if !reflect.DeepEqual(refs, test.expected) {
+				t.Fatalf("got %v, expected %v", refs, test.expected)
+			}
+		})
+	}
+} //TestExpressionRefs()
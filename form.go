@@ -1,6 +1,7 @@
 package forms
 
 import (
+	"encoding/json"
 	"html/template"
 	"regexp"
 	"sort"
@@ -14,12 +15,51 @@ type Form struct {
 	ID        string    `json:"id,omitempty" doc:"Unique ID assigned when the form is created"`
 	Rev       int       `json:"rev,omitempty" doc:"Revision count form updates 1,2,3,..."`
 	Timestamp time.Time `json:"timestamp" doc:"Time when the form revision was created"`
+	UserID    string    `json:"user_id,omitempty" doc:"User who owns the form"`
+	UpdatedBy string    `json:"updated_by,omitempty" doc:"User who made this revision, defaults to the owner"`
 	Header
-	Sections   []Section `json:"sections,omitempty" doc:"Each section displays as another tab/page to be filled and user can navigate to next/prev."`
-	Action     string    `json:"-" doc:"Used at run-time"`
-	CampaignID string    `json:"-" doc:"Used at run-time"`
+	Sections   []Section              `json:"sections,omitempty" doc:"Each section displays as another tab/page to be filled and user can navigate to next/prev."`
+	Action     string                 `json:"-" doc:"Used at run-time"`
+	CampaignID string                 `json:"-" doc:"Used at run-time"`
+	Errors     FieldErrors            `json:"-" doc:"Used at run-time to show why submitted values were rejected"`
+	Values     map[string]interface{} `json:"-" doc:"Used at run-time to show values already entered, see Form.Value()"`
+	Page       *FormPage              `json:"-" doc:"Used at run-time to show one section at a time, see FormPage"`
+	Comments   map[string][]string    `json:"-" doc:"Used at run-time to show reviewer comments next to the fields by path, or \"\" for comments on the whole doc, see DocComment"`
 }
 
+// FormPage describes the page of a multi-page form being displayed: each
+// section on its own page with a progress indicator, then a review page with
+// all the answers before the doc is submitted
+type FormPage struct {
+	Section  int        //index of the section on this page, ignored on the review page
+	Review   bool       //true on the review page
+	Back     bool       //true when there is a previous page to go back to
+	Steps    []FormStep //one step per section
+	Number   int        //number of this page, counting only visible sections and the review page
+	Count    int        //total nr of pages, counting only visible sections and the review page
+	Values   string     //JSON values entered on other pages, used by conditions and computed fields
+	DraftURL string     //link to resume the draft after it was saved
+	Editing  bool       //true when a submitted doc is edited, which cannot be saved as a draft
+}
+
+// FormStep is a section in the progress indicator of a multi-page form
+type FormStep struct {
+	Title   string
+	Visible bool //false when the section is hidden by its show_if condition
+	Current bool
+}
+
+// ShowSection is true when section[i] is displayed on the current page
+func (f Form) ShowSection(i int) bool {
+	if f.Page == nil {
+		return true
+	}
+	if f.Page.Review {
+		return i < len(f.Page.Steps) && f.Page.Steps[i].Visible
+	}
+	return i == f.Page.Section
+} //Form.ShowSection()
+
 func (f *Form) Validate() error {
 	if f.Rev < 0 {
 		return errors.Errorf("negative rev:%d", f.Rev)
@@ -28,12 +68,15 @@ func (f *Form) Validate() error {
 		return errors.Wrapf(err, "invalid header")
 	}
 	sectionNames := []string{}
+	fieldNames := []string{}
 	for i, s := range f.Sections {
 		sectionNames = append(sectionNames, s.Name)
 		if err := s.Validate(); err != nil {
 			return errors.Wrapf(err, "invalid section[%d]", i)
 		}
 		s.FirstSection = false
+		//doc data is stored by name, so names must also be uniq across sections
+		fieldNames = append(fieldNames, s.ItemNames()...)
 	}
 	if len(f.Sections) < 1 {
 		return errors.Errorf("missing sections")
@@ -41,6 +84,12 @@ func (f *Form) Validate() error {
 	if !uniqNames(sectionNames) {
 		return errors.Errorf("section names are not unique")
 	}
+	if !uniqNames(fieldNames) {
+		return errors.Errorf("field/table/sub names are not unique across sections")
+	}
+	if err := f.validateConditions(); err != nil {
+		return errors.Wrapf(err, "invalid condition or computed field")
+	}
 	f.Sections[0].FirstSection = true
 	return nil
 } //Form.Validate()
@@ -49,9 +98,10 @@ func (f *Form) Validate() error {
 
 type Section struct {
 	Header
-	FirstSection bool   `json:"first_section,omitempty"` //defined when validated - will override whatever you specified
-	Name         string `json:"name"`
-	Items        []Item `json:"items"`
+	FirstSection bool      `json:"first_section,omitempty"` //defined when validated - will override whatever you specified
+	Name         string    `json:"name"`
+	ShowIf       Condition `json:"show_if,omitempty" doc:"Optional condition to show the section only when it is true, e.g. role == \"parent\""`
+	Items        []Item    `json:"items"`
 }
 
 func (s *Section) Validate() error {
@@ -62,6 +112,9 @@ func (s *Section) Validate() error {
 	if s.Name, err = validateName(s.Name); err != nil {
 		return errors.Wrapf(err, "invalid name")
 	}
+	if err := s.ShowIf.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid show_if")
+	}
 	if len(s.Items) < 1 {
 		return errors.Errorf("missing items")
 	}
@@ -94,6 +147,9 @@ type Item struct {
 	// List   *List   `json:"list" doc:"A list with one field, which can be repeated"`
 	Table *Table `json:"table,omitempty" doc:"A list with multiple fields on each line displayed as columns"`
 	Sub   *Sub   `json:"sub,omitempty" doc:"A sub section is another header with fields enclosed in a block. It supports ability for user to add more instances of the secion, e.g. if each section describes a person with several fields, of which one of more fields' values must be unique from other instances to create a unique key."`
+
+	ShowIf     Condition `json:"show_if,omitempty" doc:"Optional condition to show the item only when it is true, e.g. accommodation == \"tent\". Values of hidden items are not stored."`
+	RequiredIf Condition `json:"required_if,omitempty" doc:"Optional condition that requires a value (or at least one row in a table/sub) when it is true"`
 }
 
 func (i *Item) Validate() error {
@@ -129,11 +185,33 @@ func (i *Item) Validate() error {
 		}
 	}
 	if count != 1 {
-		return errors.Errorf("has %d of header|image|field|table|sub, should be exactly 1")
+		return errors.Errorf("has %d of header|image|field|table|sub, should be exactly 1", count)
+	}
+	if err := i.ShowIf.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid show_if")
+	}
+	if err := i.RequiredIf.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid required_if")
+	}
+	if i.RequiredIf != "" && i.name() == "" {
+		return errors.Errorf("required_if on a header/image")
 	}
 	return nil
 } //Item.Validate()
 
+// name returns the name of the field, table or sub, or "" for other items
+func (i Item) name() string {
+	switch {
+	case i.Field != nil:
+		return i.Field.Name
+	case i.Table != nil:
+		return i.Table.Name
+	case i.Sub != nil:
+		return i.Sub.Name
+	}
+	return ""
+}
+
 type Header struct {
 	Title           string        `json:"title,omitempty" doc:"Title is printed bigger than description"`
 	Description     string        `json:"description,omitempty" doc:"Use markdown to style"`
@@ -201,6 +279,9 @@ func (t *Table) Validate() error {
 		if err := f.Validate(); err != nil {
 			return errors.Wrapf(err, "invalid field[%d]", i)
 		}
+		if f.Computed != nil {
+			return errors.Errorf("field[%d] computed is not supported in a table", i)
+		}
 	}
 	if len(t.Uniq) < 1 {
 		return errors.Errorf("missing uniq")
@@ -217,7 +298,7 @@ func (t *Table) Validate() error {
 			return errors.Errorf("uniq[%d]=\"%s\" is not a field name", i, u)
 		}
 		for ii, uu := range t.Uniq {
-			if i != i && u == uu {
+			if i != ii && u == uu {
 				return errors.Errorf("uniq[%d]=\"%s\" duplicates uniq[%d]", i, u, ii)
 			}
 		}
@@ -250,6 +331,9 @@ func (s *Sub) Validate() error {
 	if s.Min > s.Max {
 		return errors.Errorf("min:%d > max:%d", s.Min, s.Max)
 	}
+	if s.Section == nil {
+		return errors.Errorf("missing section")
+	}
 	if err := s.Section.Validate(); err != nil {
 		return errors.Wrapf(err, "invalid section")
 	}
@@ -258,16 +342,19 @@ func (s *Sub) Validate() error {
 
 type Field struct {
 	Header
-	Name      string     `json:"name" doc:"Value is stored as this name which is unique in this form"`
-	Short     *Short     `json:"short,omitempty" doc:"Enter a short answer in one line"`
-	Integer   *Integer   `json:"integer,omitempty" doc:"Integer value displayed as a slider or a up-down toggle or type it"`
-	Number    *Number    `json:"number,omitempty" doc:"Enter a number which could have fractions"`
-	Text      *Text      `json:"text,omitempty" doc:"Enter a multi-line response"`
-	Date      *Date      `json:"date,omitempty" doc:"Enter/select a date in your local time zone"`
-	Time      *Time      `json:"time,omitempty" doc:"Enter/select a time of day"`
-	Duration  *Duration  `json:"duration,omitempty" doc:"Enter/select a duration of time"`
-	Choice    *Choice    `json:"choice,omitempty" doc:"Select one from a list. Display as radio button or drop down"`
-	Selection *Selection `json:"selection,omitempty" doc:"Select multiple options. Displayed as check boxes"`
+	Name      string         `json:"name" doc:"Value is stored as this name which is unique in this form"`
+	Required  bool           `json:"required,omitempty" doc:"A value must be entered (for selection: at least one option)"`
+	Messages  *FieldMessages `json:"messages,omitempty" doc:"Optional messages to show instead of the default validation errors"`
+	Short     *Short         `json:"short,omitempty" doc:"Enter a short answer in one line"`
+	Integer   *Integer       `json:"integer,omitempty" doc:"Integer value displayed as a slider or a up-down toggle or type it"`
+	Number    *Number        `json:"number,omitempty" doc:"Enter a number which could have fractions"`
+	Text      *Text          `json:"text,omitempty" doc:"Enter a multi-line response"`
+	Date      *Date          `json:"date,omitempty" doc:"Enter/select a date in your local time zone"`
+	Time      *Time          `json:"time,omitempty" doc:"Enter/select a time of day"`
+	Duration  *Duration      `json:"duration,omitempty" doc:"Enter/select a duration of time"`
+	Choice    *Choice        `json:"choice,omitempty" doc:"Select one from a list. Display as radio button or drop down"`
+	Selection *Selection     `json:"selection,omitempty" doc:"Select multiple options. Displayed as check boxes"`
+	Computed  *Computed      `json:"computed,omitempty" doc:"Value calculated from other fields. Displayed read-only and calculated again when the form is submitted."`
 	// Grid coice (choices repeats for each row)
 	// Grid check (check repeats for each row)
 	// ...
@@ -336,12 +423,26 @@ func (f *Field) Validate() error {
 			return errors.Wrapf(err, "invalid selection")
 		}
 	}
+	if f.Computed != nil {
+		count++
+		if err := f.Computed.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid computed")
+		}
+	}
 	if count != 1 {
-		return errors.Errorf("has %d of short|integer|number|text|date|time|duration|choice|selection instead of 1", count)
+		return errors.Errorf("has %d of short|integer|number|text|date|time|duration|choice|selection|computed instead of 1", count)
+	}
+	if f.Required && f.Computed != nil {
+		return errors.Errorf("computed cannot be required")
 	}
 	return nil
 } //Field.Validate()
 
+type FieldMessages struct {
+	Required string `json:"required,omitempty" doc:"Shown when a required value was not entered instead of \"required\""`
+	Invalid  string `json:"invalid,omitempty" doc:"Shown when the value is not valid, e.g. too long or not one of the options, instead of the specific reason"`
+}
+
 // todo: add validation and display options to each of these
 type Short struct {
 	MinLen *int    `json:"min_length,omitempty"`
@@ -386,7 +487,7 @@ type Number struct {
 
 func (i Number) Validate() error {
 	if i.Min != nil && i.Max != nil && (*i.Min > *i.Max) {
-		return errors.Errorf("min:%d > max:%d", *i.Min, *i.Max)
+		return errors.Errorf("min:%v > max:%v", *i.Min, *i.Max)
 	}
 	return nil
 } //Number.Validate()
@@ -408,8 +509,8 @@ func (s Text) Validate() error {
 	if s.MinLen != nil && s.MaxLen != nil && (*s.MinLen > *s.MaxLen) {
 		return errors.Errorf("min_length:%d > max_lengh:%d", *s.MinLen, *s.MaxLen)
 	}
-	if s.NrRows != nil && *s.NrRows < 2 || *s.NrRows > 20 {
-		return errors.Errorf("nr_rows:%d is not 2..20")
+	if s.NrRows != nil && (*s.NrRows < 2 || *s.NrRows > 20) {
+		return errors.Errorf("nr_rows:%d is not 2..20", *s.NrRows)
 	}
 	return nil
 } //Text.Validate()
@@ -452,7 +553,7 @@ func (d Time) Validate() error {
 	var max time.Time
 	if d.Min != nil {
 		var err error
-		if min, err = time.Parse("15:04`", *d.Min); err != nil {
+		if min, err = time.Parse("15:04", *d.Min); err != nil {
 			return errors.Errorf("min:\"%s\" is not HH:MM", *d.Min)
 		}
 	}
@@ -514,6 +615,35 @@ func (s Selection) Validate() error {
 	return nil
 } //Selection.Validate()
 
+type Computed struct {
+	Expression Expression                    `json:"expression" doc:"Expression to calculate the value from other fields, e.g. course_price[course] + sum(kids.nr_days) * 50"`
+	Lookup     map[string]map[string]float64 `json:"lookup,omitempty" doc:"Named tables to look up values in the expression, e.g. {\"course_price\":{\"seekat\":150,\"voorskool\":100}} used as course_price[course]"`
+}
+
+func (c Computed) Validate() error {
+	if c.Expression == "" {
+		return errors.Errorf("missing expression")
+	}
+	if err := c.Expression.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid expression")
+	}
+	for name := range c.Lookup {
+		if _, err := validateName(name); err != nil {
+			return errors.Wrapf(err, "invalid lookup name")
+		}
+	}
+	return nil
+} //Computed.Validate()
+
+// LookupJSON returns the lookup tables to evaluate the expression in the browser
+func (c Computed) LookupJSON() (string, error) {
+	jsonLookup, err := json.Marshal(c.Lookup)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to encode lookup")
+	}
+	return string(jsonLookup), nil
+}
+
 type Option struct {
 	Header
 	Value string `json:"value" doc:"Stored value when selected"`
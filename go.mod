@@ -3,14 +3,17 @@ module github.com/go-msvc/forms
 go 1.19
 
 require (
+	github.com/go-msvc/config v0.0.2
 	github.com/go-msvc/errors v1.2.0
 	github.com/go-msvc/logger v1.0.0
 	github.com/go-msvc/nats-utils v0.0.0-20230311203613-5b399d881185
 	github.com/go-msvc/utils v0.0.0-20230311172718-6824feffcc5f
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/gomarkdown/markdown v0.0.0-20230310225216-e92f2877bcce
+	github.com/google/uuid v1.3.0
 	github.com/gorilla/mux v1.8.0
 	github.com/gorilla/sessions v1.2.1
+	github.com/mattn/go-sqlite3 v1.14.16
 )
 
 replace github.com/go-msvc/humans => ../humans
@@ -18,10 +21,8 @@ replace github.com/go-msvc/humans => ../humans
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/go-msvc/config v0.0.2 // indirect
 	github.com/go-msvc/data v1.0.1 // indirect
 	github.com/go-msvc/humans v0.0.2 // indirect
-	github.com/google/uuid v1.3.0 // indirect
 	github.com/gorilla/securecookie v1.1.1 // indirect
 	github.com/jansemmelink/events v0.0.0-20230315195305-2665510c82ea // indirect
 	github.com/mediocregopher/radix/v3 v3.8.1 // indirect
@@ -0,0 +1,90 @@
+package forms
+
+import (
+	"strings"
+
+	"github.com/go-msvc/errors"
+)
+
+// CampaignRole is what a member can do in a campaign. Each role can do all
+// that the roles below it can do.
+type CampaignRole string
+
+const (
+	CampaignRoleOwner    CampaignRole = "owner"    //manage members and delete the campaign
+	CampaignRoleEditor   CampaignRole = "editor"   //update the campaign and its docs
+	CampaignRoleReviewer CampaignRole = "reviewer" //review docs and move them between queues
+	CampaignRoleViewer   CampaignRole = "viewer"   //see the docs and queues
+)
+
+var campaignRoleRank = map[CampaignRole]int{
+	CampaignRoleViewer:   1,
+	CampaignRoleReviewer: 2,
+	CampaignRoleEditor:   3,
+	CampaignRoleOwner:    4,
+}
+
+func (r CampaignRole) Validate() error {
+	if _, ok := campaignRoleRank[r]; !ok {
+		return errors.Errorf("role:\"%s\" is not owner|editor|reviewer|viewer", r)
+	}
+	return nil
+}
+
+// Includes is true when role r can do all that role other can do
+func (r CampaignRole) Includes(other CampaignRole) bool {
+	return campaignRoleRank[r] > 0 && campaignRoleRank[r] >= campaignRoleRank[other]
+}
+
+// CampaignMember is a user who can see or process the docs of a campaign
+type CampaignMember struct {
+	UserID string       `json:"user_id" doc:"Email of the member"`
+	Role   CampaignRole `json:"role" doc:"owner|editor|reviewer|viewer"`
+}
+
+func (m CampaignMember) Validate() error {
+	if strings.TrimSpace(m.UserID) == "" {
+		return errors.Errorf("missing user_id")
+	}
+	if err := m.Role.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid role")
+	}
+	return nil
+}
+
+// Role returns the role of the user in the campaign, or "" when the user is
+// not a member. The user who created the campaign is always an owner.
+func (c Campaign) Role(userID string) CampaignRole {
+	if userID == "" {
+		return ""
+	}
+	if userID == c.UserID {
+		return CampaignRoleOwner
+	}
+	for _, m := range c.Members {
+		if m.UserID == userID {
+			return m.Role
+		}
+	}
+	return ""
+} //Campaign.Role()
+
+// HasRole is true when the user is a member with the role or a role that
+// includes it, e.g. an editor has the reviewer role
+func (c Campaign) HasRole(userID string, role CampaignRole) bool {
+	return c.Role(userID).Includes(role)
+} //Campaign.HasRole()
+
+func (c Campaign) validateMembers() error {
+	userIDs := []string{c.UserID}
+	for i, m := range c.Members {
+		if err := m.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid members[%d]", i)
+		}
+		userIDs = append(userIDs, m.UserID)
+	}
+	if !uniqNames(userIDs) {
+		return errors.Errorf("members has duplicate user_id or includes the campaign user_id")
+	}
+	return nil
+} //Campaign.validateMembers()
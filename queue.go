@@ -0,0 +1,77 @@
+package forms
+
+import (
+	"time"
+
+	"github.com/go-msvc/errors"
+)
+
+// CampaignQueue is a named list of submitted docs waiting to be processed,
+// e.g. "new", "payment", "done". Submitted docs are placed in the first queue
+// of the campaign and moved to other queues by the people processing them.
+type CampaignQueue struct {
+	Name  string `json:"name" doc:"Unique name of the queue in the campaign written in snake_case, e.g. \"new\""`
+	Title string `json:"title,omitempty" doc:"Optional title to display, defaults to the name"`
+}
+
+func (q CampaignQueue) Validate() error {
+	if _, err := validateName(q.Name); err != nil {
+		return errors.Wrapf(err, "invalid name")
+	}
+	return nil
+}
+
+// DisplayTitle returns the title or else the name of the queue
+func (q CampaignQueue) DisplayTitle() string {
+	if q.Title != "" {
+		return q.Title
+	}
+	return q.Name
+}
+
+// FirstQueue returns the name of the queue where submitted docs are placed,
+// or "" when the campaign has no queues
+func (c Campaign) FirstQueue() string {
+	if len(c.Queues) == 0 {
+		return ""
+	}
+	return c.Queues[0].Name
+} //Campaign.FirstQueue()
+
+// FindQueue returns the queue with the name, false when there is none
+func (c Campaign) FindQueue(name string) (CampaignQueue, bool) {
+	for _, q := range c.Queues {
+		if q.Name == name {
+			return q, true
+		}
+	}
+	return CampaignQueue{}, false
+} //Campaign.FindQueue()
+
+// DocMove is an entry in the audit trail of a doc moving between queues
+type DocMove struct {
+	From    string    `json:"from,omitempty" doc:"Queue the doc was in, omitted when the doc was submitted"`
+	To      string    `json:"to"`
+	Time    time.Time `json:"time"`
+	UserID  string    `json:"user_id,omitempty" doc:"User who moved the doc"`
+	DocRev  int       `json:"doc_rev" doc:"Revision of the doc saved with the move"`
+	Comment string    `json:"comment,omitempty" doc:"Optional reason for the move"`
+}
+
+// Move places the doc in another queue and appends the move to its audit
+// trail, which must be saved in rev
+func (d *Doc) Move(to string, userID string, rev int, now time.Time, comment string) error {
+	if to == d.Queue {
+		return errors.Errorf("doc(%s) is already in queue(%s)", d.ID, to)
+	}
+	d.Moves = append(d.Moves, DocMove{
+		From:    d.Queue,
+		To:      to,
+		Time:    now,
+		UserID:  userID,
+		DocRev:  rev,
+		Comment: comment,
+	})
+	d.Queue = to
+	return nil
+} //Doc.Move()
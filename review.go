@@ -0,0 +1,119 @@
+package forms
+
+import (
+	"time"
+
+	"github.com/go-msvc/errors"
+)
+
+// Submitted docs are reviewed in a workflow of states:
+//
+//	submitted -> in_review -> accepted | rejected | returned
+//	returned -> resubmitted (edited by the submitter) -> in_review -> ...
+//
+// Each change of state is appended to Doc.History, which is never changed,
+// so the history shows who did what when. Reviewers return a doc with
+// comments on the whole doc or on field paths, which are shown next to the
+// fields while the submitter edits the doc.
+var docTransitions = map[DocState][]DocState{
+	DocStateDraft:       {DocStateSubmitted},
+	DocStateSubmitted:   {DocStateInReview},
+	DocStateResubmitted: {DocStateInReview},
+	DocStateInReview:    {DocStateReturned, DocStateAccepted, DocStateRejected},
+	DocStateReturned:    {DocStateResubmitted},
+}
+
+// CanTransition is true when a doc in state s can move to state to. A doc
+// without a state was submitted before docs had states.
+func (s DocState) CanTransition(to DocState) bool {
+	if s == "" {
+		s = DocStateSubmitted
+	}
+	for _, next := range docTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+} //DocState.CanTransition()
+
+// DocTransition is an entry in the history of a doc
+type DocTransition struct {
+	From     DocState     `json:"from,omitempty" doc:"Omitted when the doc was submitted without a draft"`
+	To       DocState     `json:"to"`
+	Time     time.Time    `json:"time"`
+	UserID   string       `json:"user_id,omitempty" doc:"User who made the transition"`
+	DocRev   int          `json:"doc_rev" doc:"Revision of the doc saved with the transition"`
+	Comments []DocComment `json:"comments,omitempty" doc:"Comments from the reviewer, e.g. why the doc was returned"`
+}
+
+// DocComment is a comment on the whole doc or on a field in the doc
+type DocComment struct {
+	Path string `json:"path,omitempty" doc:"Path of the field, e.g. \"name\", \"table_1[0].f1\" or \"sub_1[1].f2\" (see FieldErrors), or omit for the whole doc"`
+	Text string `json:"text"`
+}
+
+func (c DocComment) Validate() error {
+	if c.Text == "" {
+		return errors.Errorf("missing text")
+	}
+	return nil
+}
+
+// Transition appends the change of state to the history of the doc, which
+// must be saved in rev
+func (d *Doc) Transition(to DocState, userID string, rev int, now time.Time, comments []DocComment) error {
+	//a new doc without a state or history is submitted
+	isNew := d.State == "" && len(d.History) == 0
+	if !(isNew && to == DocStateSubmitted) && !d.State.CanTransition(to) {
+		return errors.Errorf("doc(%s) cannot change from %s to %s", d.ID, d.State, to)
+	}
+	d.History = append(d.History, DocTransition{
+		From:     d.State,
+		To:       to,
+		Time:     now,
+		UserID:   userID,
+		DocRev:   rev,
+		Comments: comments,
+	})
+	d.State = to
+	return nil
+} //Doc.Transition()
+
+// ReturnedComments are the comments of the reviewer who returned the doc, or
+// nil when the doc is not returned
+func (d Doc) ReturnedComments() []DocComment {
+	if d.State != DocStateReturned || len(d.History) == 0 {
+		return nil
+	}
+	return d.History[len(d.History)-1].Comments
+} //Doc.ReturnedComments()
+
+// ValidateComments checks that comments refer to items in the form
+func (f Form) ValidateComments(comments []DocComment) error {
+	names := map[string]bool{}
+	for _, s := range f.Sections {
+		for _, n := range s.ItemNames() {
+			names[n] = true
+		}
+	}
+	for i, c := range comments {
+		if err := c.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid comments[%d]", i)
+		}
+		if c.Path != "" && !names[DataPathName(c.Path)] {
+			return errors.Errorf("comments[%d].path:\"%s\" is not in the form", i, c.Path)
+		}
+	}
+	return nil
+} //Form.ValidateComments()
+
+// CommentsByPath groups comments by path to show them in the form, see
+// Form.Comments
+func CommentsByPath(comments []DocComment) map[string][]string {
+	byPath := map[string][]string{}
+	for _, c := range comments {
+		byPath[c.Path] = append(byPath[c.Path], c.Text)
+	}
+	return byPath
+} //CommentsByPath()
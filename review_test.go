@@ -0,0 +1,48 @@
+package forms
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDocTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    DocState
+		history int
+		to      DocState
+		ok      bool
+	}{
+		{"new doc submitted", "", 0, DocStateSubmitted, true},
+		{"new doc cannot be accepted", "", 0, DocStateAccepted, false},
+		{"new doc cannot be returned", "", 0, DocStateReturned, false},
+		{"doc without state is reviewed as submitted", "", 0, DocStateInReview, true},
+		{"doc without state but with history cannot be submitted again", "", 1, DocStateSubmitted, false},
+		{"draft submitted", DocStateDraft, 0, DocStateSubmitted, true},
+		{"submitted in review", DocStateSubmitted, 1, DocStateInReview, true},
+		{"submitted cannot be accepted", DocStateSubmitted, 1, DocStateAccepted, false},
+		{"in review accepted", DocStateInReview, 2, DocStateAccepted, true},
+		{"returned resubmitted", DocStateReturned, 3, DocStateResubmitted, true},
+		{"accepted is final", DocStateAccepted, 3, DocStateInReview, false},
+	}
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := Doc{ID: "d1", State: test.from, History: make([]DocTransition, test.history)}
+			err := d.Transition(test.to, "a@example.com", 1, now, nil)
+			if (err == nil) != test.ok {
+				t.Fatalf("got error %v, expected ok:%v", err, test.ok)
+			}
+			if err != nil {
+				if d.State != test.from || len(d.History) != test.history {
+					t.Fatalf("doc changed by a failed transition: %+v", d)
+				}
+				return
+			}
+			last := d.History[len(d.History)-1]
+			if d.State != test.to || last.From != test.from || last.To != test.to || !last.Time.Equal(now) {
+				t.Fatalf("state %s and history %+v after transition to %s", d.State, last, test.to)
+			}
+		})
+	}
+} //TestDocTransition()
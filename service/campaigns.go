@@ -2,9 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"os"
 	"time"
 
 	"github.com/go-msvc/errors"
@@ -13,34 +10,33 @@ import (
 	"github.com/google/uuid"
 )
 
-var campaignsDir string
-
-func init() {
-	campaignsDir = os.Getenv("CAMPAIGNS_DIR")
-	if campaignsDir == "" {
-		campaignsDir = "./campaigns"
-	}
-	if err := os.MkdirAll(campaignsDir, 0770); err != nil && err != os.ErrExist {
-		panic(fmt.Sprintf("Cannot access CAMPAIGNS_DIR=%s: %+v", campaignsDir, err))
-	}
-}
-
 func addCampaign(ctx context.Context, req formsinterface.AddCampaignRequest) (*formsinterface.AddCampaignResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.Campaign.ID != "" {
 		return nil, errors.Errorf("campaign.id=%s may not be specified when adding a campaign", req.Campaign.ID)
 	}
+	if req.Campaign.UserID != req.Principal.UserID {
+		return nil, formsinterface.PermissionDeniedError{UserID: req.Principal.UserID, Kind: campaignsKind, Reason: "campaign.user_id must be the principal who becomes the owner"}
+	}
 	req.Campaign.ID = uuid.New().String()
+	req.Campaign.Rev = 1
 	req.Campaign.CreateTime = time.Now()
 	req.Campaign.UpdateTime = time.Now()
 	if err := saveCampaign(req.Campaign); err != nil {
 		return nil, errors.Wrapf(err, "failed to save campaign")
 	}
+	storeIndex.set(campaignsKind, campaignIndexEntry(req.Campaign))
 	return &formsinterface.AddCampaignResponse{
 		Campaign: req.Campaign,
 	}, nil
 } //addCampaign()
 
 func getCampaign(ctx context.Context, req formsinterface.GetCampaignRequest) (*formsinterface.GetCampaignResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.ID == "" {
 		return nil, errors.Errorf("id must be specified when getting a campaign")
 	}
@@ -48,70 +44,113 @@ func getCampaign(ctx context.Context, req formsinterface.GetCampaignRequest) (*f
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to load existing campaign")
 	}
+	if !existingCampaign.HasRole(req.Principal.UserID, forms.CampaignRoleViewer) {
+		//anyone may get a campaign to submit docs, but only members see who the members are
+		existingCampaign.Members = nil
+	}
 	return &formsinterface.GetCampaignResponse{
 		Campaign: existingCampaign,
 	}, nil
 } //getCampaign()
 
 func updCampaign(ctx context.Context, req formsinterface.UpdCampaignRequest) (*formsinterface.UpdCampaignResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.Campaign.ID == "" {
 		return nil, errors.Errorf("campaign.id must be specified when updating a campaign")
 	}
-	_, err := loadCampaign(req.Campaign.ID)
+	unlock := writeLocks.lock(campaignsKind, req.Campaign.ID)
+	defer unlock()
+	existingCampaign, err := loadCampaign(req.Campaign.ID)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to load existing campaign")
 	}
+	if err := campaignAccess(existingCampaign, req.Principal.UserID, forms.CampaignRoleEditor); err != nil {
+		return nil, err
+	}
+	if existingCampaign.Rev != req.ExpectedRev {
+		return nil, formsinterface.ConflictError{Kind: "campaign", ID: req.Campaign.ID, ExpectedRev: req.ExpectedRev, LatestRev: existingCampaign.Rev}
+	}
+	//owner and members only change with invite_member and remove_member
+	req.Campaign.UserID = existingCampaign.UserID
+	req.Campaign.Members = existingCampaign.Members
+	req.Campaign.Rev = existingCampaign.Rev + 1
+	req.Campaign.CreateTime = existingCampaign.CreateTime
 	req.Campaign.UpdateTime = time.Now()
 	if err := saveCampaign(req.Campaign); err != nil {
 		return nil, errors.Wrapf(err, "failed to save campaign")
 	}
+	storeIndex.set(campaignsKind, campaignIndexEntry(req.Campaign))
 	return &formsinterface.UpdCampaignResponse{
 		Campaign: req.Campaign,
 	}, nil
 } //updCampaign()
 
 func delCampaign(ctx context.Context, req formsinterface.DelCampaignRequest) (*formsinterface.DelCampaignResponse, error) {
-	campaignDir := campaignsDir + "/" + req.ID
-	if err := os.RemoveAll(campaignDir); err != nil {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	unlock := writeLocks.lock(campaignsKind, req.ID)
+	defer unlock()
+	existingCampaign, err := loadCampaign(req.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load existing campaign")
+	}
+	if err := campaignAccess(existingCampaign, req.Principal.UserID, forms.CampaignRoleOwner); err != nil {
+		return nil, err
+	}
+	if err := store.Delete(campaignsKind, req.ID); err != nil {
 		return nil, errors.Wrapf(err, "failed to remove campaign")
 	}
+	storeIndex.del(campaignsKind, req.ID)
 	return &formsinterface.DelCampaignResponse{}, nil
 }
 
 func findCampaigns(ctx context.Context, req formsinterface.FindCampaignRequest) (*formsinterface.FindCampaignResponse, error) {
-	//should only see campaigns that you own or shared with you...
-	return nil, MyError{Message: "NYI"}
-}
-
-func saveCampaign(f forms.Campaign) error {
-	campaignDir := campaignsDir + "/" + f.ID
-	if err := os.MkdirAll(campaignDir, 0770); err != nil && err != os.ErrExist {
-		return errors.Wrapf(err, "cannot make campaign dir %s", campaignDir)
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
 	}
-	filename := fmt.Sprintf("%s/latest.json", campaignDir)
-	latestFile, err := os.Create(filename)
+	//only campaigns where the principal is a member
+	ids, pageInfo, err := storeIndex.find(campaignsKind, func(e indexEntry) bool {
+		return e.hasMember(req.Principal.UserID) &&
+			(req.UserID == "" || e.UserID == req.UserID) &&
+			(req.FormID == "" || e.FormID == req.FormID) &&
+			req.Created.Contains(e.Created) &&
+			req.Updated.Contains(e.Updated)
+	}, req.Page)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create file %s", filename)
+		return nil, errors.Wrapf(err, "failed to find campaigns")
 	}
-	defer latestFile.Close()
-	if err := json.NewEncoder(latestFile).Encode(f); err != nil {
-		return errors.Wrapf(err, "failed to save latest campaign")
+	res := &formsinterface.FindCampaignResponse{
+		Campaigns: []forms.Campaign{},
+		PageInfo:  pageInfo,
+	}
+	for _, id := range ids {
+		c, err := loadCampaign(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load campaign(%s)", id)
+		}
+		res.Campaigns = append(res.Campaigns, c)
+	}
+	return res, nil
+} //findCampaigns()
+
+func saveCampaign(f forms.Campaign) error {
+	if err := store.Save(campaignsKind, f.ID, f.Rev, f); err != nil {
+		return errors.Wrapf(err, "failed to save campaign")
 	}
 	return nil
 } //saveCampaign()
 
 func loadCampaign(id string) (forms.Campaign, error) {
-	campaignDir := campaignsDir + "/" + id
-	var filename string
-	filename = fmt.Sprintf("%s/latest.json", campaignDir)
-	campaignFile, err := os.Open(filename)
-	if err != nil {
-		return forms.Campaign{}, errors.Wrapf(err, "failed to open file %s", filename)
-	}
-	defer campaignFile.Close()
 	var f forms.Campaign
-	if err := json.NewDecoder(campaignFile).Decode(&f); err != nil {
+	if err := store.Load(campaignsKind, id, 0, &f); err != nil {
 		return forms.Campaign{}, errors.Wrapf(err, "failed to load latest campaign")
 	}
+	if f.Rev == 0 {
+		//saved before campaigns had revisions
+		f.Rev = 1
+	}
 	return f, nil
 } //loadCampaign()
@@ -2,9 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"os"
 	"time"
 
 	"github.com/go-msvc/errors"
@@ -13,38 +10,55 @@ import (
 	"github.com/google/uuid"
 )
 
-var docsDir string
-
-func init() {
-	docsDir = os.Getenv("DOCS_DIR")
-	if docsDir == "" {
-		docsDir = "./docs"
-	}
-	if err := os.MkdirAll(docsDir, 0770); err != nil && err != os.ErrExist {
-		panic(fmt.Sprintf("Cannot access DOCS_DIR=%s: %+v", docsDir, err))
-	}
-}
-
 func addDoc(ctx context.Context, req formsinterface.AddDocRequest) (*formsinterface.AddDocResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.Doc.ID != "" {
 		return nil, errors.Errorf("doc.id=%s may not be specified when adding a doc", req.Doc.ID)
 	}
 	if req.Doc.Rev != 0 {
 		return nil, errors.Errorf("doc.rev=%d may not be specified when adding a doc", req.Doc.Rev)
 	}
+	if req.Doc.UserID != req.Principal.UserID {
+		return nil, formsinterface.PermissionDeniedError{UserID: req.Principal.UserID, Kind: docsKind, Reason: "doc.user_id must be the principal who submits the doc"}
+	}
+	if err := submitAllowed(req.Doc); err != nil {
+		return nil, err
+	}
+	var err error
+	if req.Doc.Data, err = validateDocData(req.Doc); err != nil {
+		return nil, errors.Wrapf(err, "invalid doc data")
+	}
 	req.Doc.ID = uuid.New().String()
 	req.Doc.Rev = 1
 	req.Doc.Timestamp = time.Now()
+	req.Doc.State = ""
+	req.Doc.History = nil
+	if err := req.Doc.Transition(forms.DocStateSubmitted, req.Doc.UserID, req.Doc.Rev, req.Doc.Timestamp, nil); err != nil {
+		return nil, err
+	}
+	req.Doc.Queue = ""
+	req.Doc.Moves = nil
+	if err := enqueueDoc(&req.Doc); err != nil {
+		return nil, err
+	}
+	req.Doc.UpdatedBy = req.Doc.UserID
 
 	if err := saveDoc(req.Doc); err != nil {
 		return nil, errors.Wrapf(err, "failed to save doc")
 	}
+	storeIndex.set(docsKind, docIndexEntry(req.Doc, req.Doc.Timestamp))
+	notifyDoc(req.Doc)
 	return &formsinterface.AddDocResponse{
 		Doc: req.Doc,
 	}, nil
 } //addDoc()
 
 func getDoc(ctx context.Context, req formsinterface.GetDocRequest) (*formsinterface.GetDocResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.ID == "" {
 		return nil, errors.Errorf("id must be specified when getting a doc")
 	}
@@ -52,12 +66,18 @@ func getDoc(ctx context.Context, req formsinterface.GetDocRequest) (*formsinterf
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to load existing doc")
 	}
+	if err := docAccess(existingDoc, req.Principal.UserID, forms.CampaignRoleViewer); err != nil {
+		return nil, err
+	}
 	return &formsinterface.GetDocResponse{
 		Doc: existingDoc,
 	}, nil
 } //getDoc()
 
 func updDoc(ctx context.Context, req formsinterface.UpdDocRequest) (*formsinterface.UpdDocResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.Doc.ID == "" {
 		return nil, errors.Errorf("doc.id must be specified when updating a doc")
 	}
@@ -65,78 +85,223 @@ func updDoc(ctx context.Context, req formsinterface.UpdDocRequest) (*formsinterf
 		return nil, errors.Errorf("doc.rev=%d may not be specified when updating a doc", req.Doc.Rev)
 	}
 
+	unlock := writeLocks.lock(docsKind, req.Doc.ID)
+	defer unlock()
 	existingDoc, err := loadDoc(req.Doc.ID, 0) //0 for latest doc
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to load existing doc")
 	}
+	if existingDoc.State == forms.DocStateDraft {
+		return nil, errors.Errorf("doc(%s) is a draft, use save_draft or submit_draft", req.Doc.ID)
+	}
+	if existingDoc.Rev != req.ExpectedRev {
+		return nil, formsinterface.ConflictError{Kind: "doc", ID: req.Doc.ID, ExpectedRev: req.ExpectedRev, LatestRev: existingDoc.Rev}
+	}
+	//the submitter may edit while the campaign allows it, else campaign editors
+	bySubmitter := existingDoc.UserID == req.Principal.UserID
+	if bySubmitter {
+		if err := userEditAllowed(existingDoc, req.Principal.UserID); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := docAccess(existingDoc, req.Principal.UserID, forms.CampaignRoleEditor); err != nil {
+			return nil, err
+		}
+	}
+	//owner, form, campaign, queue and history cannot change after the doc was
+	//submitted, but the data may be captured on another revision of the form
+	req.Doc.UserID = existingDoc.UserID
+	req.Doc.FormID = existingDoc.FormID
+	if err := formRevExists(req.Doc.FormID, req.Doc.FormRev); err != nil {
+		return nil, err
+	}
+	if req.Doc.Data, err = validateDocData(req.Doc); err != nil {
+		return nil, errors.Wrapf(err, "invalid doc data")
+	}
+	req.Doc.CampaignID = existingDoc.CampaignID
+	req.Doc.State = existingDoc.State
+	req.Doc.History = existingDoc.History
+	req.Doc.Queue = existingDoc.Queue
+	req.Doc.Moves = existingDoc.Moves
 	req.Doc.Rev = existingDoc.Rev + 1
 	req.Doc.Timestamp = time.Now()
+	req.Doc.UpdatedBy = req.Principal.UserID
+	if bySubmitter && req.Doc.State == forms.DocStateReturned {
+		//back to the reviewers after the submitter made the requested changes
+		if err := req.Doc.Transition(forms.DocStateResubmitted, req.Principal.UserID, req.Doc.Rev, req.Doc.Timestamp, nil); err != nil {
+			return nil, err
+		}
+	}
 	if err := saveDoc(req.Doc); err != nil {
 		return nil, errors.Wrapf(err, "failed to save doc")
 	}
+	storeIndex.set(docsKind, docIndexEntry(req.Doc, storeIndex.created(docsKind, req.Doc.ID, req.Doc.Timestamp)))
+	notifyDoc(req.Doc)
 	return &formsinterface.UpdDocResponse{
 		Doc: req.Doc,
 	}, nil
 } //updDoc()
 
 func delDoc(ctx context.Context, req formsinterface.DelDocRequest) (*formsinterface.DelDocResponse, error) {
-	docDir := docsDir + "/" + req.ID
-	if err := os.RemoveAll(docDir); err != nil {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	unlock := writeLocks.lock(docsKind, req.ID)
+	defer unlock()
+	existingDoc, err := loadDoc(req.ID, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load existing doc")
+	}
+	if existingDoc.CampaignID == "" || existingDoc.State == forms.DocStateDraft {
+		//drafts are private until submitted
+		if existingDoc.UserID != req.Principal.UserID {
+			return nil, formsinterface.PermissionDeniedError{UserID: req.Principal.UserID, Kind: docsKind, ID: existingDoc.ID, Reason: "doc belongs to another user"}
+		}
+	} else {
+		//docs submitted in a campaign can only be deleted by its editors
+		campaign, err := loadCampaign(existingDoc.CampaignID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load campaign(%s)", existingDoc.CampaignID)
+		}
+		if err := campaignAccess(campaign, req.Principal.UserID, forms.CampaignRoleEditor); err != nil {
+			return nil, err
+		}
+	}
+	if err := store.Delete(docsKind, req.ID); err != nil {
 		return nil, errors.Wrapf(err, "failed to remove doc")
 	}
+	storeIndex.del(docsKind, req.ID)
 	return &formsinterface.DelDocResponse{}, nil
 }
 
 func findDoc(ctx context.Context, req formsinterface.FindDocRequest) (*formsinterface.FindDocResponse, error) {
-	//should only see docs that you own or shared with you...
-	return nil, MyError{Message: "NYI"}
-}
-
-func saveDoc(f forms.Doc) error {
-	docDir := docsDir + "/" + f.ID
-	if err := os.MkdirAll(docDir, 0770); err != nil && err != os.ErrExist {
-		return errors.Wrapf(err, "cannot make doc dir %s", docDir)
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	//only own docs, or docs in a campaign where the principal is a member
+	if req.CampaignID != "" {
+		campaign, err := loadCampaign(req.CampaignID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load campaign(%s)", req.CampaignID)
+		}
+		if err := campaignAccess(campaign, req.Principal.UserID, forms.CampaignRoleViewer); err != nil {
+			return nil, err
+		}
+	} else {
+		if req.UserID == "" {
+			req.UserID = req.Principal.UserID
+		}
+		if req.UserID != req.Principal.UserID {
+			return nil, formsinterface.PermissionDeniedError{UserID: req.Principal.UserID, Kind: docsKind, Reason: "only own docs can be found without campaign_id"}
+		}
+	}
+	ids, pageInfo, err := storeIndex.find(docsKind, func(e indexEntry) bool {
+		return (req.UserID == "" || e.UserID == req.UserID) &&
+			(req.FormID == "" || e.FormID == req.FormID) &&
+			(req.CampaignID == "" || e.CampaignID == req.CampaignID) &&
+			(req.State == "" || e.State == req.State) &&
+			(e.State != forms.DocStateDraft || e.UserID == req.Principal.UserID) && //drafts are private
+			req.Created.Contains(e.Created) &&
+			req.Updated.Contains(e.Updated)
+	}, req.Page)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to find docs")
+	}
+	res := &formsinterface.FindDocResponse{
+		Docs:     []forms.Doc{},
+		PageInfo: pageInfo,
 	}
+	for _, id := range ids {
+		d, err := loadDoc(id, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load doc(%s)", id)
+		}
+		res.Docs = append(res.Docs, d)
+	}
+	return res, nil
+} //findDoc()
 
-	filename := fmt.Sprintf("%s/latest.json", docDir)
-	latestFile, err := os.Create(filename)
+// userEditAllowed checks that the user submitted the doc and that its campaign
+// still allows edits
+func userEditAllowed(doc forms.Doc, userID string) error {
+	if doc.UserID != userID {
+		return formsinterface.PermissionDeniedError{UserID: userID, Kind: docsKind, ID: doc.ID, Reason: "doc belongs to another user"}
+	}
+	if doc.CampaignID == "" {
+		return errors.Errorf("doc(%s) was not submitted in a campaign", doc.ID)
+	}
+	campaign, err := loadCampaign(doc.CampaignID)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create file %s", filename)
+		return errors.Wrapf(err, "failed to load campaign(%s)", doc.CampaignID)
 	}
-	defer latestFile.Close()
-	if err := json.NewEncoder(latestFile).Encode(f); err != nil {
-		return errors.Wrapf(err, "failed to save latest doc")
+	if err := campaign.EditAllowed(doc, time.Now()); err != nil {
+		return errors.Wrapf(err, "cannot edit doc(%s)", doc.ID)
 	}
+	return nil
+} //userEditAllowed()
 
-	filename = fmt.Sprintf("%s/rev_%d.json", docDir, f.Rev)
-	revFile, err := os.Create(filename)
+// submitAllowed checks that the doc is submitted on the form of its campaign
+// while the campaign is open
+func submitAllowed(doc forms.Doc) error {
+	if doc.CampaignID == "" {
+		return errors.Errorf("doc must be submitted in a campaign")
+	}
+	campaign, err := loadCampaign(doc.CampaignID)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create file %s", filename)
+		return errors.Wrapf(err, "failed to load campaign(%s)", doc.CampaignID)
 	}
-	defer revFile.Close()
-	if err := json.NewEncoder(revFile).Encode(f); err != nil {
-		return errors.Wrapf(err, "failed to save rev doc")
+	if err := campaign.SubmitAllowed(doc, time.Now()); err != nil {
+		return errors.Wrapf(err, "cannot submit doc")
 	}
 	return nil
-} //saveDoc()
+} //submitAllowed()
 
-func loadDoc(id string, rev int) (forms.Doc, error) {
-	docDir := docsDir + "/" + id
-	var filename string
+// formRevExists checks that rev is a revision of the form, so that a doc
+// cannot be validated against another form
+func formRevExists(formID string, rev int) error {
 	if rev == 0 {
-		filename = fmt.Sprintf("%s/latest.json", docDir)
-	} else {
-		filename = fmt.Sprintf("%s/rev_%d.json", docDir, rev)
+		return nil //latest
+	}
+	revs, err := store.Revs(formsKind, formID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list revisions of form(%s)", formID)
+	}
+	for _, r := range revs {
+		if r == rev {
+			return nil
+		}
 	}
+	return errors.Errorf("form(%s) has no rev(%d)", formID, rev)
+} //formRevExists()
 
-	docFile, err := os.Open(filename)
+// validateDocData converts the doc data to the types stored for each field
+// and checks it against the form revision it was captured on
+func validateDocData(doc forms.Doc) (map[string]interface{}, error) {
+	form, err := loadForm(doc.FormID, doc.FormRev)
 	if err != nil {
-		return forms.Doc{}, errors.Wrapf(err, "failed to open file %s", filename)
+		return nil, errors.Wrapf(err, "failed to load form(%s).rev(%d)", doc.FormID, doc.FormRev)
+	}
+	data, err := form.CoerceData(doc.Data)
+	if err != nil {
+		return nil, err
+	}
+	if err := form.ValidateData(data); err != nil {
+		return nil, err
 	}
-	defer docFile.Close()
+	return data, nil
+} //validateDocData()
+
+func saveDoc(f forms.Doc) error {
+	if err := store.Save(docsKind, f.ID, f.Rev, f); err != nil {
+		return errors.Wrapf(err, "failed to save doc")
+	}
+	return nil
+} //saveDoc()
+
+func loadDoc(id string, rev int) (forms.Doc, error) {
 	var f forms.Doc
-	if err := json.NewDecoder(docFile).Decode(&f); err != nil {
-		return forms.Doc{}, errors.Wrapf(err, "failed to load latest doc")
+	if err := store.Load(docsKind, id, rev, &f); err != nil {
+		return forms.Doc{}, errors.Wrapf(err, "failed to load doc.rev(%d)", rev)
 	}
 	return f, nil
 } //loadDoc()
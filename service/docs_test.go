@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// newTestStore makes an empty memory store and index the store of the service
+func newTestStore(t *testing.T) Store {
+	t.Helper()
+	s, err := memoryStoreConfig{}.Create()
+	if err != nil {
+		t.Fatalf("failed to create memory store: %+v", err)
+	}
+	store = s
+	storeIndex.reset()
+	return s
+} //newTestStore()
+
+// testPrincipals makes each user a trusted service, so tests can call
+// operations as the user without a session
+func testPrincipals(userIDs ...string) map[string]formsinterface.Principal {
+	principals = principalsConfig{}
+	byUserID := map[string]formsinterface.Principal{}
+	for _, userID := range userIDs {
+		secret := "secret-of-" + userID + "-0123456789abcdefghijklmnopqrstuvwxyz"
+		principals.Services = append(principals.Services, serviceConfig{UserID: userID, secret: secret})
+		byUserID[userID] = formsinterface.Principal{UserID: userID, Secret: secret}
+	}
+	return byUserID
+} //testPrincipals()
+
+func TestDraftAccess(t *testing.T) {
+	newTestStore(t)
+	p := testPrincipals("owner@example.com", "viewer@example.com", "editor@example.com", "user@example.com")
+	campaign := forms.Campaign{
+		ID:     "c1",
+		Rev:    1,
+		UserID: "owner@example.com",
+		FormID: "f1",
+		Members: []forms.CampaignMember{
+			{UserID: "viewer@example.com", Role: forms.CampaignRoleViewer},
+			{UserID: "editor@example.com", Role: forms.CampaignRoleEditor},
+		},
+	}
+	if err := store.Save(campaignsKind, campaign.ID, campaign.Rev, campaign); err != nil {
+		t.Fatalf("failed to save campaign: %+v", err)
+	}
+	expires := time.Now().Add(time.Hour)
+	draft := forms.Doc{
+		ID:         "d1",
+		FormID:     "f1",
+		FormRev:    1,
+		CampaignID: campaign.ID,
+		UserID:     "user@example.com",
+		State:      forms.DocStateDraft,
+		Expires:    &expires,
+		Data:       map[string]interface{}{"name": "secret"},
+	}
+	if err := saveDoc(draft); err != nil {
+		t.Fatalf("failed to save draft: %+v", err)
+	}
+	storeIndex.set(docsKind, docIndexEntry(draft, time.Now()))
+
+	ctx := context.Background()
+	for _, userID := range []string{"owner@example.com", "viewer@example.com", "editor@example.com"} {
+		if _, err := getDoc(ctx, formsinterface.GetDocRequest{Principal: p[userID], ID: draft.ID}); !isPermissionDenied(err) {
+			t.Errorf("%s get_doc on draft of another user: expected PermissionDeniedError, got %+v", userID, err)
+		}
+		if _, err := listDocRevisions(ctx, formsinterface.ListDocRevisionsRequest{Principal: p[userID], ID: draft.ID}); !isPermissionDenied(err) {
+			t.Errorf("%s list_doc_revisions on draft of another user: expected PermissionDeniedError, got %+v", userID, err)
+		}
+		if _, err := delDoc(ctx, formsinterface.DelDocRequest{Principal: p[userID], ID: draft.ID}); !isPermissionDenied(err) {
+			t.Errorf("%s del_doc on draft of another user: expected PermissionDeniedError, got %+v", userID, err)
+		}
+		res, err := findDoc(ctx, formsinterface.FindDocRequest{Principal: p[userID], CampaignID: campaign.ID})
+		if err != nil {
+			t.Fatalf("%s find_docs failed: %+v", userID, err)
+		}
+		if len(res.Docs) != 0 {
+			t.Errorf("%s found %d docs, expected the draft to be hidden", userID, len(res.Docs))
+		}
+	}
+	res, err := getDoc(ctx, formsinterface.GetDocRequest{Principal: p["user@example.com"], ID: draft.ID})
+	if err != nil {
+		t.Fatalf("user get_doc on own draft failed: %+v", err)
+	}
+	if res.Doc.Data["name"] != "secret" {
+		t.Errorf("got %+v", res.Doc)
+	}
+} //TestDraftAccess()
+
+func isPermissionDenied(err error) bool {
+	_, ok := err.(formsinterface.PermissionDeniedError)
+	return ok
+} //isPermissionDenied()
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+func init() {
+	config.MustConfigure("drafts", draftsConfig{TTL: "720h", SweepInterval: "1h"})
+}
+
+// draftsConfig controls how long drafts are kept and when users are reminded
+// to submit them, e.g. {"drafts":{"ttl":"720h","sweep_interval":"1h"}}
+type draftsConfig struct {
+	TTL           string           `json:"ttl" doc:"Drafts are deleted this long after they were last saved, e.g. \"720h\" for 30 days"`
+	SweepInterval string           `json:"sweep_interval" doc:"How often expired drafts are deleted and reminders are sent, e.g. \"1h\""`
+	Reminders     *remindersConfig `json:"reminders,omitempty" doc:"Optional reminders to submit drafts before the campaign ends"`
+
+	ttl           time.Duration
+	sweepInterval time.Duration
+}
+
+func (c *draftsConfig) Validate() error {
+	var err error
+	if c.ttl, err = time.ParseDuration(c.TTL); err != nil || c.ttl <= 0 {
+		return errors.Errorf("invalid ttl:\"%s\", expecting a positive duration like \"720h\"", c.TTL)
+	}
+	if c.sweepInterval, err = time.ParseDuration(c.SweepInterval); err != nil || c.sweepInterval <= 0 {
+		return errors.Errorf("invalid sweep_interval:\"%s\", expecting a positive duration like \"1h\"", c.SweepInterval)
+	}
+	if c.Reminders != nil {
+		if err := c.Reminders.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid reminders")
+		}
+	}
+	return nil
+}
+
+// remindersConfig pushes a formsinterface.DraftReminder to a redis queue
+type remindersConfig struct {
+	Before string `json:"before" doc:"Remind this long before the campaign end_time, e.g. \"48h\""`
+	Redis  string `json:"redis" doc:"Redis address, e.g. \"localhost:6379\""`
+	Queue  string `json:"queue" doc:"Redis list where reminders are pushed"`
+
+	before time.Duration
+}
+
+func (c *remindersConfig) Validate() error {
+	var err error
+	if c.before, err = time.ParseDuration(c.Before); err != nil || c.before <= 0 {
+		return errors.Errorf("invalid before:\"%s\", expecting a positive duration like \"48h\"", c.Before)
+	}
+	if c.Redis == "" {
+		return errors.Errorf("missing redis")
+	}
+	if c.Queue == "" {
+		return errors.Errorf("missing queue")
+	}
+	return nil
+}
+
+// drafts is loaded from config in main()
+var drafts draftsConfig
+
+func saveDraft(ctx context.Context, req formsinterface.SaveDraftRequest) (*formsinterface.SaveDraftResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	if req.Doc.UserID != req.Principal.UserID {
+		return nil, formsinterface.PermissionDeniedError{UserID: req.Principal.UserID, Kind: docsKind, ID: req.Doc.ID, Reason: "doc.user_id must be the principal who saves the draft"}
+	}
+	if _, err := loadForm(req.Doc.FormID, req.Doc.FormRev); err != nil {
+		return nil, errors.Wrapf(err, "failed to load form(%s).rev(%d)", req.Doc.FormID, req.Doc.FormRev)
+	}
+	if req.Doc.ID == "" {
+		req.Doc.ID = uuid.New().String()
+		req.Doc.Reminded = nil
+	} else {
+		unlock := writeLocks.lock(docsKind, req.Doc.ID)
+		defer unlock()
+		existingDraft, err := loadDraft(req.Doc.ID, req.Principal.UserID)
+		if err != nil {
+			return nil, err
+		}
+		//a draft stays in its campaign and is only reminded once
+		req.Doc.CampaignID = existingDraft.CampaignID
+		req.Doc.Reminded = existingDraft.Reminded
+	}
+	//drafts are not validated and have no revisions until submitted
+	req.Doc.Rev = 0
+	req.Doc.State = forms.DocStateDraft
+	req.Doc.History = nil
+	req.Doc.Queue = ""
+	req.Doc.Moves = nil
+	req.Doc.UpdatedBy = req.Doc.UserID
+	req.Doc.Timestamp = time.Now()
+	expires := req.Doc.Timestamp.Add(drafts.ttl)
+	req.Doc.Expires = &expires
+	if err := saveDoc(req.Doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to save draft")
+	}
+	storeIndex.set(docsKind, docIndexEntry(req.Doc, storeIndex.created(docsKind, req.Doc.ID, req.Doc.Timestamp)))
+	return &formsinterface.SaveDraftResponse{
+		Doc: req.Doc,
+	}, nil
+} //saveDraft()
+
+func getDraft(ctx context.Context, req formsinterface.GetDraftRequest) (*formsinterface.GetDraftResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	id := req.ID
+	if id == "" {
+		//latest draft of the user in the campaign
+		now := time.Now()
+		ids, _, err := storeIndex.find(docsKind, func(e indexEntry) bool {
+			return e.State == forms.DocStateDraft &&
+				e.UserID == req.Principal.UserID &&
+				e.CampaignID == req.CampaignID &&
+				e.Expires.After(now)
+		}, formsinterface.Page{Limit: 1})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find draft")
+		}
+		if len(ids) == 0 {
+			return nil, errors.Errorf("no draft in campaign(%s)", req.CampaignID)
+		}
+		id = ids[0]
+	}
+	draft, err := loadDraft(id, req.Principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &formsinterface.GetDraftResponse{
+		Doc: draft,
+	}, nil
+} //getDraft()
+
+func submitDraft(ctx context.Context, req formsinterface.SubmitDraftRequest) (*formsinterface.SubmitDraftResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	unlock := writeLocks.lock(docsKind, req.ID)
+	defer unlock()
+	doc, err := loadDraft(req.ID, req.Principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := submitAllowed(doc); err != nil {
+		return nil, err
+	}
+	if doc.Data, err = validateDocData(doc); err != nil {
+		return nil, errors.Wrapf(err, "invalid doc data")
+	}
+	doc.Rev = 1
+	doc.Timestamp = time.Now()
+	if err := doc.Transition(forms.DocStateSubmitted, doc.UserID, doc.Rev, doc.Timestamp, nil); err != nil {
+		return nil, err
+	}
+	if err := enqueueDoc(&doc); err != nil {
+		return nil, err
+	}
+	doc.UpdatedBy = doc.UserID
+	doc.Expires = nil
+	doc.Reminded = nil
+	if err := saveDoc(doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to save doc")
+	}
+	storeIndex.set(docsKind, docIndexEntry(doc, storeIndex.created(docsKind, doc.ID, doc.Timestamp)))
+	notifyDoc(doc)
+	return &formsinterface.SubmitDraftResponse{
+		Doc: doc,
+	}, nil
+} //submitDraft()
+
+// loadDraft loads a draft that was not yet submitted, only for the user who
+// saved it
+func loadDraft(id string, userID string) (forms.Doc, error) {
+	doc, err := loadDoc(id, 0)
+	if err != nil {
+		return forms.Doc{}, errors.Wrapf(err, "draft(%s) not found", id)
+	}
+	if doc.State != forms.DocStateDraft {
+		return forms.Doc{}, errors.Errorf("doc(%s) is not a draft", id)
+	}
+	if doc.UserID != userID {
+		return forms.Doc{}, formsinterface.PermissionDeniedError{UserID: userID, Kind: docsKind, ID: id, Reason: "draft belongs to another user"}
+	}
+	if doc.Expires != nil && doc.Expires.Before(time.Now()) {
+		return forms.Doc{}, errors.Errorf("draft(%s) expired at %s", id, doc.Expires.Format(time.RFC3339))
+	}
+	return doc, nil
+} //loadDraft()
+
+// sweepDrafts runs forever to delete expired drafts and to remind users to
+// submit their drafts before the campaign ends
+func sweepDrafts(c draftsConfig) {
+	var reminders *redis.Client
+	if c.Reminders != nil {
+		reminders = redis.NewClient(&redis.Options{
+			Addr: c.Reminders.Redis,
+		})
+	}
+	for {
+		now := time.Now()
+		for _, e := range storeIndex.list(docsKind, func(e indexEntry) bool { return e.State == forms.DocStateDraft }) {
+			if !e.Expires.IsZero() && e.Expires.Before(now) {
+				if err := delExpiredDraft(e.ID, now); err != nil {
+					log.Errorf("failed to delete expired draft(%s): %+v", e.ID, err)
+				}
+				continue
+			}
+			if reminders != nil && e.CampaignID != "" {
+				if err := remindDraft(reminders, *c.Reminders, e, now); err != nil {
+					log.Errorf("failed to remind draft(%s): %+v", e.ID, err)
+				}
+			}
+		}
+		time.Sleep(c.sweepInterval)
+	}
+} //sweepDrafts()
+
+func delExpiredDraft(id string, now time.Time) error {
+	unlock := writeLocks.lock(docsKind, id)
+	defer unlock()
+	//check again in case it was saved or submitted since it was listed
+	doc, err := loadDoc(id, 0)
+	if err != nil {
+		return err
+	}
+	if doc.State != forms.DocStateDraft || doc.Expires == nil || doc.Expires.After(now) {
+		return nil
+	}
+	if err := store.Delete(docsKind, id); err != nil {
+		return errors.Wrapf(err, "failed to remove draft")
+	}
+	storeIndex.del(docsKind, id)
+	log.Debugf("deleted draft(%s) of user(%s) that expired at %s", id, doc.UserID, doc.Expires.Format(time.RFC3339))
+	return nil
+} //delExpiredDraft()
+
+// remindDraft pushes a reminder once when the campaign of the draft ends soon
+func remindDraft(client *redis.Client, c remindersConfig, e indexEntry, now time.Time) error {
+	campaign, err := loadCampaign(e.CampaignID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load campaign(%s)", e.CampaignID)
+	}
+	if campaign.EndTime == nil || now.After(*campaign.EndTime) || now.Before(campaign.EndTime.Add(-c.before)) {
+		return nil
+	}
+	unlock := writeLocks.lock(docsKind, e.ID)
+	defer unlock()
+	doc, err := loadDoc(e.ID, 0)
+	if err != nil {
+		return err
+	}
+	if doc.State != forms.DocStateDraft || doc.Reminded != nil {
+		return nil
+	}
+	jsonReminder, _ := json.Marshal(formsinterface.DraftReminder{
+		DocID:      doc.ID,
+		CampaignID: doc.CampaignID,
+		UserID:     doc.UserID,
+		EndTime:    *campaign.EndTime,
+	})
+	if _, err := client.LPush(context.Background(), c.Queue, jsonReminder).Result(); err != nil {
+		return errors.Wrapf(err, "failed to push reminder")
+	}
+	doc.Reminded = &now
+	if err := saveDoc(doc); err != nil {
+		return errors.Wrapf(err, "failed to save draft")
+	}
+	return nil
+} //remindDraft()
@@ -2,49 +2,92 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"os"
 	"time"
 
+	"github.com/go-msvc/config"
 	"github.com/go-msvc/errors"
 	"github.com/go-msvc/forms"
 	"github.com/go-msvc/forms/service/formsinterface"
 	"github.com/google/uuid"
 )
 
-var formsDir string
-
 func init() {
-	formsDir = os.Getenv("FORMS_DIR")
-	if formsDir == "" {
-		formsDir = "./forms"
-	}
-	if err := os.MkdirAll(formsDir, 0770); err != nil && err != os.ErrExist {
-		panic(fmt.Sprintf("Cannot access FORMS_DIR=%s: %+v", formsDir, err))
-	}
+	config.MustConfigure("forms", formsConfig{})
+}
+
+// formsConfig assigns an owner to forms created before forms had owners,
+// e.g. {"forms":{"legacy_owner":"admin@example.com"}}
+type formsConfig struct {
+	LegacyOwner string `json:"legacy_owner,omitempty" doc:"User ID that becomes the owner of forms without a user_id when the service starts"`
 }
 
+func (c formsConfig) Validate() error {
+	return nil
+}
+
+// migrateFormOwners saves a new revision of each form without a user_id with
+// the legacy owner, because formOwner() denies all changes to such forms.
+// Without a legacy owner the forms are only listed in the log.
+func migrateFormOwners(s Store, owner string) error {
+	ids, err := s.List(formsKind)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list forms")
+	}
+	for _, id := range ids {
+		var f forms.Form
+		if err := s.Load(formsKind, id, 0, &f); err != nil {
+			return errors.Wrapf(err, "failed to load form(%s)", id)
+		}
+		if f.UserID != "" {
+			continue
+		}
+		if owner == "" {
+			log.Errorf("form(%s) has no owner and cannot be changed until forms.legacy_owner is configured", id)
+			continue
+		}
+		f.UserID = owner
+		f.Rev++
+		f.Timestamp = time.Now()
+		f.UpdatedBy = owner
+		if err := s.Save(formsKind, f.ID, f.Rev, f); err != nil {
+			return errors.Wrapf(err, "failed to save form(%s) with owner", id)
+		}
+		log.Debugf("form(%s) now belongs to legacy owner %s", id, owner)
+	}
+	return nil
+} //migrateFormOwners()
+
 func addForm(ctx context.Context, req formsinterface.AddFormRequest) (*formsinterface.AddFormResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.Form.ID != "" {
 		return nil, errors.Errorf("form.id=%s may not be specified when adding a form", req.Form.ID)
 	}
 	if req.Form.Rev != 0 {
 		return nil, errors.Errorf("form.rev=%d may not be specified when adding a form", req.Form.Rev)
 	}
+	if req.Form.UserID != req.Principal.UserID {
+		return nil, formsinterface.PermissionDeniedError{UserID: req.Principal.UserID, Kind: formsKind, Reason: "form.user_id must be the principal who owns the form"}
+	}
 	req.Form.ID = uuid.New().String()
 	req.Form.Rev = 1
 	req.Form.Timestamp = time.Now()
+	req.Form.UpdatedBy = req.Principal.UserID
 
 	if err := saveForm(req.Form); err != nil {
 		return nil, errors.Wrapf(err, "failed to save form")
 	}
+	storeIndex.set(formsKind, formIndexEntry(req.Form, req.Form.Timestamp))
 	return &formsinterface.AddFormResponse{
 		Form: req.Form,
 	}, nil
 } //addForm()
 
 func getForm(ctx context.Context, req formsinterface.GetFormRequest) (*formsinterface.GetFormResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.ID == "" {
 		return nil, errors.Errorf("id must be specified when getting a form")
 	}
@@ -58,6 +101,9 @@ func getForm(ctx context.Context, req formsinterface.GetFormRequest) (*formsinte
 } //getForm()
 
 func updForm(ctx context.Context, req formsinterface.UpdFormRequest) (*formsinterface.UpdFormResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
 	if req.Form.ID == "" {
 		return nil, errors.Errorf("form.id must be specified when updating a form")
 	}
@@ -65,78 +111,104 @@ func updForm(ctx context.Context, req formsinterface.UpdFormRequest) (*formsinte
 		return nil, errors.Errorf("form.rev=%d may not be specified when updating a form", req.Form.Rev)
 	}
 
+	unlock := writeLocks.lock(formsKind, req.Form.ID)
+	defer unlock()
 	existingForm, err := loadForm(req.Form.ID, 0) //0 for latest form
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to load existing form")
 	}
+	if err := formOwner(existingForm, req.Principal.UserID); err != nil {
+		return nil, err
+	}
+	if existingForm.Rev != req.ExpectedRev {
+		return nil, formsinterface.ConflictError{Kind: "form", ID: req.Form.ID, ExpectedRev: req.ExpectedRev, LatestRev: existingForm.Rev}
+	}
+	req.Form.UserID = existingForm.UserID
 	req.Form.Rev = existingForm.Rev + 1
 	req.Form.Timestamp = time.Now()
+	req.Form.UpdatedBy = req.Principal.UserID
 	if err := saveForm(req.Form); err != nil {
 		return nil, errors.Wrapf(err, "failed to save form")
 	}
+	storeIndex.set(formsKind, formIndexEntry(req.Form, storeIndex.created(formsKind, req.Form.ID, req.Form.Timestamp)))
 	return &formsinterface.UpdFormResponse{
 		Form: req.Form,
 	}, nil
 } //updForm()
 
 func delForm(ctx context.Context, req formsinterface.DelFormRequest) (*formsinterface.DelFormResponse, error) {
-	formDir := formsDir + "/" + req.ID
-	if err := os.RemoveAll(formDir); err != nil {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	unlock := writeLocks.lock(formsKind, req.ID)
+	defer unlock()
+	existingForm, err := loadForm(req.ID, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load existing form")
+	}
+	if err := formOwner(existingForm, req.Principal.UserID); err != nil {
+		return nil, err
+	}
+	if err := store.Delete(formsKind, req.ID); err != nil {
 		return nil, errors.Wrapf(err, "failed to remove form")
 	}
+	storeIndex.del(formsKind, req.ID)
 	return &formsinterface.DelFormResponse{}, nil
 }
 
-func findForm(ctx context.Context, req formsinterface.AddFormRequest) (*formsinterface.AddFormResponse, error) {
-	//should only see forms that you own or shared with you...
-	return nil, MyError{Message: "NYI"}
-}
-
-func saveForm(f forms.Form) error {
-	formDir := formsDir + "/" + f.ID
-	if err := os.MkdirAll(formDir, 0770); err != nil && err != os.ErrExist {
-		return errors.Wrapf(err, "cannot make form dir %s", formDir)
+func findForm(ctx context.Context, req formsinterface.FindFormRequest) (*formsinterface.FindFormResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
 	}
-
-	filename := fmt.Sprintf("%s/latest.json", formDir)
-	latestFile, err := os.Create(filename)
+	//only own forms, other forms are only seen in campaigns
+	if req.UserID == "" {
+		req.UserID = req.Principal.UserID
+	}
+	if req.UserID != req.Principal.UserID {
+		return nil, formsinterface.PermissionDeniedError{UserID: req.Principal.UserID, Kind: formsKind, Reason: "only own forms can be found"}
+	}
+	ids, pageInfo, err := storeIndex.find(formsKind, func(e indexEntry) bool {
+		return (req.UserID == "" || e.UserID == req.UserID) &&
+			req.Created.Contains(e.Created) &&
+			req.Updated.Contains(e.Updated)
+	}, req.Page)
 	if err != nil {
-		return errors.Wrapf(err, "failed to create file %s", filename)
+		return nil, errors.Wrapf(err, "failed to find forms")
 	}
-	defer latestFile.Close()
-	if err := json.NewEncoder(latestFile).Encode(f); err != nil {
-		return errors.Wrapf(err, "failed to save latest form")
+	res := &formsinterface.FindFormResponse{
+		Forms:    []forms.Form{},
+		PageInfo: pageInfo,
 	}
+	for _, id := range ids {
+		f, err := loadForm(id, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load form(%s)", id)
+		}
+		res.Forms = append(res.Forms, f)
+	}
+	return res, nil
+} //findForm()
 
-	filename = fmt.Sprintf("%s/rev_%d.json", formDir, f.Rev)
-	revFile, err := os.Create(filename)
-	if err != nil {
-		return errors.Wrapf(err, "failed to create file %s", filename)
+// formOwner checks that the user owns the form, which is needed to change it
+// while any authenticated user can get a form, e.g. to submit it in a campaign
+func formOwner(form forms.Form, userID string) error {
+	if form.UserID != userID {
+		return formsinterface.PermissionDeniedError{UserID: userID, Kind: formsKind, ID: form.ID, Reason: "form belongs to another user"}
 	}
-	defer revFile.Close()
-	if err := json.NewEncoder(revFile).Encode(f); err != nil {
-		return errors.Wrapf(err, "failed to save rev form")
+	return nil
+} //formOwner()
+
+func saveForm(f forms.Form) error {
+	if err := store.Save(formsKind, f.ID, f.Rev, f); err != nil {
+		return errors.Wrapf(err, "failed to save form")
 	}
 	return nil
 } //saveForm()
 
 func loadForm(id string, rev int) (forms.Form, error) {
-	formDir := formsDir + "/" + id
-	var filename string
-	if rev == 0 {
-		filename = fmt.Sprintf("%s/latest.json", formDir)
-	} else {
-		filename = fmt.Sprintf("%s/rev_%d.json", formDir, rev)
-	}
-
-	formFile, err := os.Open(filename)
-	if err != nil {
-		return forms.Form{}, errors.Wrapf(err, "failed to open file %s", filename)
-	}
-	defer formFile.Close()
 	var f forms.Form
-	if err := json.NewDecoder(formFile).Decode(&f); err != nil {
-		return forms.Form{}, errors.Wrapf(err, "failed to load latest form")
+	if err := store.Load(formsKind, id, rev, &f); err != nil {
+		return forms.Form{}, errors.Wrapf(err, "failed to load form.rev(%d)", rev)
 	}
 	return f, nil
 } //loadForm()
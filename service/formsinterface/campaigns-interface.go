@@ -6,10 +6,14 @@ import (
 )
 
 type AddCampaignRequest struct {
-	Campaign forms.Campaign `json:"campaign"`
+	Principal Principal      `json:"principal" doc:"The authenticated user making the request"`
+	Campaign  forms.Campaign `json:"campaign"`
 }
 
 func (req AddCampaignRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if err := req.Campaign.Validate(); err != nil {
 		return errors.Wrapf(err, "invalid campaign")
 	}
@@ -21,10 +25,14 @@ type AddCampaignResponse struct {
 }
 
 type GetCampaignRequest struct {
-	ID string `json:"id"`
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
 }
 
 func (req GetCampaignRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.ID == "" {
 		return errors.Errorf("missing id")
 	}
@@ -36,10 +44,18 @@ type GetCampaignResponse struct {
 }
 
 type UpdCampaignRequest struct {
-	Campaign forms.Campaign `json:"campaign"`
+	Principal   Principal      `json:"principal" doc:"The authenticated user making the request"`
+	Campaign    forms.Campaign `json:"campaign"`
+	ExpectedRev int            `json:"expected_rev" doc:"The latest rev of the campaign that was changed. Update fails with ConflictError if it is no longer the latest."`
 }
 
 func (req UpdCampaignRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ExpectedRev < 1 {
+		return errors.Errorf("missing expected_rev")
+	}
 	if err := req.Campaign.Validate(); err != nil {
 		return errors.Wrapf(err, "invalid campaign")
 	}
@@ -51,10 +67,14 @@ type UpdCampaignResponse struct {
 }
 
 type DelCampaignRequest struct {
-	ID string `json:"id"`
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
 }
 
 func (req DelCampaignRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.ID == "" {
 		return errors.Errorf("missing id")
 	}
@@ -64,14 +84,47 @@ func (req DelCampaignRequest) Validate() error {
 type DelCampaignResponse struct{}
 
 type FindCampaignRequest struct {
-	UserID string `json:"user_id"`
+	Principal Principal  `json:"principal" doc:"The authenticated user making the request, only campaigns where this user is a member are found"`
+	UserID    string     `json:"user_id,omitempty" doc:"Only campaigns created by this user"`
+	FormID    string     `json:"form_id,omitempty" doc:"Only campaigns for this form"`
+	Created   *TimeRange `json:"created,omitempty" doc:"Only campaigns created in this time range"`
+	Updated   *TimeRange `json:"updated,omitempty" doc:"Only campaigns updated in this time range"`
+	Page
+}
+
+func (req FindCampaignRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.Created != nil {
+		if err := req.Created.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid created")
+		}
+	}
+	if req.Updated != nil {
+		if err := req.Updated.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid updated")
+		}
+	}
+	if err := req.Page.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid page")
+	}
+	return nil
 }
 
 type FindCampaignResponse struct {
-	//todo: list of campaigns
+	Campaigns []forms.Campaign `json:"campaigns"`
+	PageInfo
 }
 
+// CampaignNotification is pushed to the campaign queue (see
+// forms.Campaign.NotificationQueue) each time a revision of a doc is saved
 type CampaignNotification struct {
-	CampaingID string `json:"campaign_id"`
-	DocID      string `json:"doc_id"`
+	CampaingID string               `json:"campaign_id"`
+	DocID      string               `json:"doc_id"`
+	DocRev     int                  `json:"doc_rev,omitempty" doc:"Revision of the doc that was saved, more than 1 when the doc was edited or reviewed"`
+	State      forms.DocState       `json:"state,omitempty" doc:"State of the doc in the review workflow"`
+	Transition *forms.DocTransition `json:"transition,omitempty" doc:"Set when the doc changed state in this revision"`
+	Queue      string               `json:"queue,omitempty" doc:"Processing queue of the campaign where the doc is"`
+	Move       *forms.DocMove       `json:"move,omitempty" doc:"Set when the doc moved to another queue in this revision"`
 }
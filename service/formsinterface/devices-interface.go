@@ -0,0 +1,66 @@
+package formsinterface
+
+import (
+	"strings"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+)
+
+// MaxDeviceNameLen is the longest name a user can give a device
+const MaxDeviceNameLen = 50
+
+type ListDevicesRequest struct {
+	Principal Principal `json:"principal" doc:"The authenticated user whose devices are listed"`
+}
+
+func (req ListDevicesRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	return nil
+}
+
+type ListDevicesResponse struct {
+	Devices []forms.Device `json:"devices" doc:"Devices logged in to the session of the user, the most recently used first"`
+}
+
+type RenameDeviceRequest struct {
+	Principal Principal `json:"principal" doc:"The authenticated user who owns the device"`
+	DeviceID  string    `json:"device_id"`
+	Name      string    `json:"name" doc:"User's name for the device, e.g. \"MyPhone\""`
+}
+
+func (req RenameDeviceRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.DeviceID == "" {
+		return errors.Errorf("missing device_id")
+	}
+	if name := strings.TrimSpace(req.Name); name == "" || len(name) > MaxDeviceNameLen {
+		return errors.Errorf("name must be 1..%d characters", MaxDeviceNameLen)
+	}
+	return nil
+}
+
+type RenameDeviceResponse struct {
+	Device forms.Device `json:"device"`
+}
+
+type RevokeDeviceRequest struct {
+	Principal Principal `json:"principal" doc:"The authenticated user who owns the device"`
+	DeviceID  string    `json:"device_id"`
+}
+
+func (req RevokeDeviceRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.DeviceID == "" {
+		return errors.Errorf("missing device_id")
+	}
+	return nil
+}
+
+type RevokeDeviceResponse struct{}
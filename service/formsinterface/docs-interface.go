@@ -6,10 +6,14 @@ import (
 )
 
 type AddDocRequest struct {
-	Doc forms.Doc `json:"doc"`
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	Doc       forms.Doc `json:"doc"`
 }
 
 func (req AddDocRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if err := req.Doc.Validate(); err != nil {
 		return errors.Wrapf(err, "invalid doc")
 	}
@@ -21,11 +25,15 @@ type AddDocResponse struct {
 }
 
 type GetDocRequest struct {
-	ID  string `json:"id"`
-	Rev int    `json:"rev" doc:"Use 0 for the latest version of the doc"`
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
+	Rev       int       `json:"rev" doc:"Use 0 for the latest version of the doc"`
 }
 
 func (req GetDocRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.ID == "" {
 		return errors.Errorf("missing id")
 	}
@@ -37,10 +45,18 @@ type GetDocResponse struct {
 }
 
 type UpdDocRequest struct {
-	Doc forms.Doc `json:"doc"`
+	Principal   Principal `json:"principal" doc:"The authenticated user making the request"`
+	Doc         forms.Doc `json:"doc"`
+	ExpectedRev int       `json:"expected_rev" doc:"The latest rev of the doc that was changed. Update fails with ConflictError if it is no longer the latest."`
 }
 
 func (req UpdDocRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ExpectedRev < 1 {
+		return errors.Errorf("missing expected_rev")
+	}
 	if err := req.Doc.Validate(); err != nil {
 		return errors.Wrapf(err, "invalid doc")
 	}
@@ -52,10 +68,14 @@ type UpdDocResponse struct {
 }
 
 type DelDocRequest struct {
-	ID string `json:"id"`
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
 }
 
 func (req DelDocRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.ID == "" {
 		return errors.Errorf("missing id")
 	}
@@ -64,6 +84,38 @@ func (req DelDocRequest) Validate() error {
 
 type DelDocResponse struct{}
 
-type FindDocRequest struct{}
+type FindDocRequest struct {
+	Principal  Principal      `json:"principal" doc:"The authenticated user making the request, who finds own docs or docs in a campaign where the user is a member"`
+	UserID     string         `json:"user_id,omitempty" doc:"Only docs submitted by this user, defaults to the principal without campaign_id"`
+	FormID     string         `json:"form_id,omitempty" doc:"Only docs captured on this form"`
+	CampaignID string         `json:"campaign_id,omitempty" doc:"Only docs submitted to this campaign"`
+	State      forms.DocState `json:"state,omitempty" doc:"Only docs in this state"`
+	Created    *TimeRange     `json:"created,omitempty" doc:"Only docs first submitted in this time range"`
+	Updated    *TimeRange     `json:"updated,omitempty" doc:"Only docs with the latest revision in this time range"`
+	Page
+}
+
+func (req FindDocRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.Created != nil {
+		if err := req.Created.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid created")
+		}
+	}
+	if req.Updated != nil {
+		if err := req.Updated.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid updated")
+		}
+	}
+	if err := req.Page.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid page")
+	}
+	return nil
+}
 
-type FindDocResponse struct{}
+type FindDocResponse struct {
+	Docs []forms.Doc `json:"docs" doc:"Latest revision of each doc in this page"`
+	PageInfo
+}
@@ -0,0 +1,78 @@
+package formsinterface
+
+import (
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+)
+
+type SaveDraftRequest struct {
+	Principal Principal `json:"principal" doc:"The authenticated user making the request, who must be doc.user_id"`
+	Doc       forms.Doc `json:"doc" doc:"Specify doc.id to update an existing draft, else a new draft is created"`
+}
+
+func (req SaveDraftRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.Doc.UserID == "" {
+		return errors.Errorf("missing doc.user_id")
+	}
+	if err := req.Doc.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid doc")
+	}
+	return nil
+}
+
+type SaveDraftResponse struct {
+	Doc forms.Doc `json:"doc"`
+}
+
+type GetDraftRequest struct {
+	Principal  Principal `json:"principal" doc:"Drafts can only be resumed by the user who saved them"`
+	ID         string    `json:"id,omitempty" doc:"ID of the draft, or omit to get the latest draft of the user in the campaign"`
+	CampaignID string    `json:"campaign_id,omitempty" doc:"Campaign of the draft when id is not specified"`
+}
+
+func (req GetDraftRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ID == "" && req.CampaignID == "" {
+		return errors.Errorf("missing id or campaign_id")
+	}
+	return nil
+}
+
+type GetDraftResponse struct {
+	Doc forms.Doc `json:"doc"`
+}
+
+type SubmitDraftRequest struct {
+	Principal Principal `json:"principal" doc:"Drafts can only be submitted by the user who saved them"`
+	ID        string    `json:"id"`
+}
+
+func (req SubmitDraftRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ID == "" {
+		return errors.Errorf("missing id")
+	}
+	return nil
+}
+
+type SubmitDraftResponse struct {
+	Doc forms.Doc `json:"doc" doc:"The submitted doc with its data validated and converted, see forms.Form.CoerceData()"`
+}
+
+// DraftReminder is pushed to the configured queue when a draft was not yet
+// submitted shortly before the campaign ends
+type DraftReminder struct {
+	DocID      string    `json:"doc_id"`
+	CampaignID string    `json:"campaign_id"`
+	UserID     string    `json:"user_id"`
+	EndTime    time.Time `json:"end_time" doc:"Time when the campaign ends"`
+}
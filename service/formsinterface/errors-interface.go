@@ -0,0 +1,31 @@
+package formsinterface
+
+import "fmt"
+
+// ConflictError is returned when an update specified an expected_rev that is
+// no longer the latest revision, i.e. someone else updated it first. Get the
+// latest revision, apply your changes again and retry the update.
+type ConflictError struct {
+	Kind        string `json:"kind"`
+	ID          string `json:"id"`
+	ExpectedRev int    `json:"expected_rev"`
+	LatestRev   int    `json:"latest_rev"`
+}
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("conflict: %s.id(%s) expected rev %d but latest is rev %d", e.Kind, e.ID, e.ExpectedRev, e.LatestRev)
+}
+
+// PermissionDeniedError is returned when the principal of a request may not
+// do the operation, e.g. the session is not authenticated or the user is not
+// a member of the campaign.
+type PermissionDeniedError struct {
+	UserID string `json:"user_id,omitempty" doc:"The principal, empty when it could not be authenticated"`
+	Kind   string `json:"kind" doc:"forms|docs|campaigns|sessions|..."`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e PermissionDeniedError) Error() string {
+	return fmt.Sprintf("permission denied: user(%s) on %s.id(%s): %s", e.UserID, e.Kind, e.ID, e.Reason)
+}
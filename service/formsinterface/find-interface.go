@@ -0,0 +1,63 @@
+package formsinterface
+
+import (
+	"time"
+
+	"github.com/go-msvc/errors"
+)
+
+// Page controls the order and size of find results
+type Page struct {
+	Sort   string `json:"sort,omitempty" doc:"created|updated with optional \"-\" prefix for descending order. Default is -updated (last updated first)."`
+	Limit  int    `json:"limit,omitempty" doc:"Max nr of results to return 1..100 (default 20)"`
+	Cursor string `json:"cursor,omitempty" doc:"Use next_cursor from the previous response to get the next page"`
+}
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+func (p Page) Validate() error {
+	switch p.Sort {
+	case "", "created", "-created", "updated", "-updated":
+	default:
+		return errors.Errorf("sort:\"%s\" is not created|-created|updated|-updated", p.Sort)
+	}
+	if p.Limit < 0 || p.Limit > MaxPageLimit {
+		return errors.Errorf("limit:%d is not 0..%d", p.Limit, MaxPageLimit)
+	}
+	return nil
+}
+
+// TimeRange matches times in [From..To), each of which is optional
+type TimeRange struct {
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+func (r TimeRange) Validate() error {
+	if r.From != nil && r.To != nil && r.From.After(*r.To) {
+		return errors.Errorf("from:\"%s\" is after to:\"%s\"", *r.From, *r.To)
+	}
+	return nil
+}
+
+func (r *TimeRange) Contains(t time.Time) bool {
+	if r == nil {
+		return true
+	}
+	if r.From != nil && t.Before(*r.From) {
+		return false
+	}
+	if r.To != nil && !t.Before(*r.To) {
+		return false
+	}
+	return true
+}
+
+// PageInfo is returned with the results of find operations
+type PageInfo struct {
+	Total      int    `json:"total" doc:"Nr of matching items across all pages"`
+	NextCursor string `json:"next_cursor,omitempty" doc:"Specify as cursor to get the next page. Not set on the last page."`
+}
@@ -6,10 +6,14 @@ import (
 )
 
 type AddFormRequest struct {
-	Form forms.Form `json:"form"`
+	Principal Principal  `json:"principal" doc:"The authenticated user making the request"`
+	Form      forms.Form `json:"form"`
 }
 
 func (req AddFormRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if err := req.Form.Validate(); err != nil {
 		return errors.Wrapf(err, "invalid form")
 	}
@@ -21,11 +25,15 @@ type AddFormResponse struct {
 }
 
 type GetFormRequest struct {
-	ID  string `json:"id"`
-	Rev int    `json:"rev" doc:"Use 0 for the latest version of the form"`
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
+	Rev       int       `json:"rev" doc:"Use 0 for the latest version of the form"`
 }
 
 func (req GetFormRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.ID == "" {
 		return errors.Errorf("missing id")
 	}
@@ -37,10 +45,18 @@ type GetFormResponse struct {
 }
 
 type UpdFormRequest struct {
-	Form forms.Form `json:"form"`
+	Principal   Principal  `json:"principal" doc:"The authenticated user making the request"`
+	Form        forms.Form `json:"form"`
+	ExpectedRev int        `json:"expected_rev" doc:"The latest rev of the form that was changed. Update fails with ConflictError if it is no longer the latest."`
 }
 
 func (req UpdFormRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ExpectedRev < 1 {
+		return errors.Errorf("missing expected_rev")
+	}
 	if err := req.Form.Validate(); err != nil {
 		return errors.Wrapf(err, "invalid form")
 	}
@@ -52,10 +68,14 @@ type UpdFormResponse struct {
 }
 
 type DelFormRequest struct {
-	ID string `json:"id"`
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
 }
 
 func (req DelFormRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.ID == "" {
 		return errors.Errorf("missing id")
 	}
@@ -64,6 +84,35 @@ func (req DelFormRequest) Validate() error {
 
 type DelFormResponse struct{}
 
-type FindFormRequest struct{}
+type FindFormRequest struct {
+	Principal Principal  `json:"principal" doc:"The authenticated user making the request"`
+	UserID    string     `json:"user_id,omitempty" doc:"Only forms owned by this user, which can only be the principal"`
+	Created   *TimeRange `json:"created,omitempty" doc:"Only forms created in this time range"`
+	Updated   *TimeRange `json:"updated,omitempty" doc:"Only forms with the latest revision in this time range"`
+	Page
+}
+
+func (req FindFormRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.Created != nil {
+		if err := req.Created.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid created")
+		}
+	}
+	if req.Updated != nil {
+		if err := req.Updated.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid updated")
+		}
+	}
+	if err := req.Page.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid page")
+	}
+	return nil
+}
 
-type FindFormResponse struct{}
+type FindFormResponse struct {
+	Forms []forms.Form `json:"forms" doc:"Latest revision of each form in this page"`
+	PageInfo
+}
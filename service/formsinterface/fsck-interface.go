@@ -0,0 +1,29 @@
+package formsinterface
+
+import (
+	"github.com/go-msvc/errors"
+)
+
+type FsckRequest struct {
+	Principal Principal `json:"principal" doc:"A trusted service listed in the forms service config"`
+	Repair    bool      `json:"repair" doc:"Set true to repair problems where possible, else only report them"`
+}
+
+func (req FsckRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	return nil
+}
+
+type FsckResponse struct {
+	Problems []FsckProblem `json:"problems"`
+}
+
+type FsckProblem struct {
+	Kind     string `json:"kind" doc:"forms|docs|campaigns|..."`
+	ID       string `json:"id,omitempty"`
+	File     string `json:"file,omitempty"`
+	Problem  string `json:"problem"`
+	Repaired bool   `json:"repaired"`
+}
@@ -0,0 +1,85 @@
+package formsinterface
+
+import (
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+)
+
+// RequestOtpRequest asks the service to start a login on the device. The
+// service counts the requests per email and remote address to throttle abuse,
+// then generates the OTP and keeps only its hash in the session of the device.
+type RequestOtpRequest struct {
+	Principal  Principal `json:"principal" doc:"A trusted service listed in the forms service config"`
+	DeviceID   string    `json:"device_id"`
+	Email      string    `json:"email"`
+	RemoteAddr string    `json:"remote_addr,omitempty" doc:"IP address of the client, counted to throttle abuse and recorded in lockouts"`
+}
+
+func (req RequestOtpRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.DeviceID == "" {
+		return errors.Errorf("missing device_id")
+	}
+	if req.Email == "" {
+		return errors.Errorf("missing email")
+	}
+	return nil
+}
+
+type RequestOtpResponse struct {
+	Allowed    bool      `json:"allowed" doc:"False when no OTP may be sent now"`
+	RetryAfter time.Time `json:"retry_after,omitempty" doc:"When not allowed, the time after which the user may try again"`
+	Reason     string    `json:"reason,omitempty" doc:"Why it is not allowed"`
+	OTP        string    `json:"otp,omitempty" doc:"When allowed, the OTP that the caller must send to the email"`
+	Expiry     time.Time `json:"expiry,omitempty" doc:"When allowed, the time after which the OTP cannot be used"`
+}
+
+// VerifyOtpRequest checks the OTP entered on the device, and logs the device
+// in to the session of the email when it is correct
+type VerifyOtpRequest struct {
+	Principal  Principal `json:"principal" doc:"A trusted service listed in the forms service config"`
+	DeviceID   string    `json:"device_id"`
+	OTP        string    `json:"otp" doc:"OTP entered by the user"`
+	RemoteAddr string    `json:"remote_addr,omitempty" doc:"IP address of the client, counted to throttle abuse and recorded in lockouts"`
+}
+
+func (req VerifyOtpRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.DeviceID == "" {
+		return errors.Errorf("missing device_id")
+	}
+	if req.OTP == "" {
+		return errors.Errorf("missing otp")
+	}
+	return nil
+}
+
+type VerifyOtpResponse struct {
+	LoggedIn   bool          `json:"logged_in" doc:"True when the OTP was correct"`
+	Allowed    bool          `json:"allowed" doc:"When not logged in, false when the OTP cannot be used any more and the user must request another"`
+	RetryAfter time.Time     `json:"retry_after,omitempty" doc:"When locked out, the time after which the user may try again"`
+	Reason     string        `json:"reason,omitempty" doc:"Why the user is not logged in"`
+	Session    forms.Session `json:"session,omitempty" doc:"When logged in, the authenticated session of the device"`
+}
+
+type ListLockoutsRequest struct {
+	Principal Principal `json:"principal" doc:"A trusted service listed in the forms service config"`
+	Email     string    `json:"email,omitempty" doc:"Only list lockouts of this email"`
+}
+
+func (req ListLockoutsRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	return nil
+}
+
+type ListLockoutsResponse struct {
+	Lockouts []forms.Lockout `json:"lockouts" doc:"Most recent first"`
+}
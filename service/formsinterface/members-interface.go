@@ -0,0 +1,52 @@
+package formsinterface
+
+import (
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+)
+
+type InviteMemberRequest struct {
+	Principal  Principal            `json:"principal" doc:"The authenticated user making the request, who must be an owner of the campaign"`
+	CampaignID string               `json:"campaign_id"`
+	Member     forms.CampaignMember `json:"member" doc:"User to add to the campaign, or to change the role of when already a member"`
+}
+
+func (req InviteMemberRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.CampaignID == "" {
+		return errors.Errorf("missing campaign_id")
+	}
+	if err := req.Member.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid member")
+	}
+	return nil
+}
+
+type InviteMemberResponse struct {
+	Campaign forms.Campaign `json:"campaign" doc:"The new revision of the campaign with the member"`
+}
+
+type RemoveMemberRequest struct {
+	Principal  Principal `json:"principal" doc:"The authenticated user making the request, who must be an owner of the campaign"`
+	CampaignID string    `json:"campaign_id"`
+	UserID     string    `json:"user_id" doc:"Member to remove from the campaign"`
+}
+
+func (req RemoveMemberRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.CampaignID == "" {
+		return errors.Errorf("missing campaign_id")
+	}
+	if req.UserID == "" {
+		return errors.Errorf("missing user_id")
+	}
+	return nil
+}
+
+type RemoveMemberResponse struct {
+	Campaign forms.Campaign `json:"campaign" doc:"The new revision of the campaign without the member"`
+}
@@ -0,0 +1,50 @@
+package formsinterface
+
+import (
+	"github.com/go-msvc/errors"
+)
+
+// Principal is the authenticated user making a request, which the service
+// uses to check that the user may do it, e.g. a member of the campaign.
+// The web app specifies the session of the logged in user, while trusted
+// services configured in the forms service specify their own user_id and the
+// secret shared with the forms service.
+type Principal struct {
+	SessionID string `json:"session_id,omitempty" doc:"Authenticated session of the user, the service uses the email of the session as user_id"`
+	UserID    string `json:"user_id,omitempty" doc:"Email of the user, without session_id only accepted for services listed in the forms service config"`
+	Secret    string `json:"secret,omitempty" doc:"Secret of the trusted service user_id as configured in the forms service, only used without session_id"`
+}
+
+func (p Principal) Validate() error {
+	if p.SessionID == "" && p.UserID == "" {
+		return errors.Errorf("missing session_id or user_id")
+	}
+	if p.SessionID != "" && p.Secret != "" {
+		return errors.Errorf("secret may not be specified with session_id")
+	}
+	return nil
+}
+
+// MinSecretLen makes service secrets too long to guess
+const MinSecretLen = 32
+
+// knownSecrets were published in example configs and must never be used
+var knownSecrets = []string{
+	"change-this-web-secret-to-32-or-more-random-chars",
+}
+
+// ValidateSecret checks a trusted service secret read from the environment
+func ValidateSecret(secret string) error {
+	if secret == "" {
+		return errors.Errorf("missing secret")
+	}
+	if len(secret) < MinSecretLen {
+		return errors.Errorf("secret must be at least %d characters", MinSecretLen)
+	}
+	for _, known := range knownSecrets {
+		if secret == known {
+			return errors.Errorf("secret is a published example, generate a random one")
+		}
+	}
+	return nil
+} //ValidateSecret()
@@ -0,0 +1,62 @@
+package formsinterface
+
+import (
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+)
+
+type ListQueueRequest struct {
+	Principal  Principal `json:"principal" doc:"The authenticated user making the request"`
+	CampaignID string    `json:"campaign_id"`
+	Queue      string    `json:"queue" doc:"Name of the queue in the campaign"`
+	Page
+}
+
+func (req ListQueueRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.CampaignID == "" {
+		return errors.Errorf("missing campaign_id")
+	}
+	if req.Queue == "" {
+		return errors.Errorf("missing queue")
+	}
+	if err := req.Page.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid page")
+	}
+	return nil
+}
+
+type ListQueueResponse struct {
+	Docs []forms.Doc `json:"docs" doc:"Latest revision of each doc in the queue in this page"`
+	PageInfo
+}
+
+type MoveDocRequest struct {
+	Principal   Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID          string    `json:"id"`
+	ExpectedRev int       `json:"expected_rev" doc:"The latest rev of the doc that was moved. Fails with ConflictError if it is no longer the latest."`
+	Queue       string    `json:"queue" doc:"Name of the queue in the campaign to move the doc to"`
+	Comment     string    `json:"comment,omitempty" doc:"Optional reason for the move kept in the audit trail"`
+}
+
+func (req MoveDocRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ID == "" {
+		return errors.Errorf("missing id")
+	}
+	if req.ExpectedRev < 1 {
+		return errors.Errorf("missing expected_rev")
+	}
+	if req.Queue == "" {
+		return errors.Errorf("missing queue")
+	}
+	return nil
+}
+
+type MoveDocResponse struct {
+	Doc forms.Doc `json:"doc" doc:"The new revision of the doc with the move appended to its audit trail"`
+}
@@ -0,0 +1,45 @@
+package formsinterface
+
+import (
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+)
+
+type ReviewDocRequest struct {
+	Principal   Principal          `json:"principal" doc:"The authenticated user making the request"`
+	ID          string             `json:"id"`
+	ExpectedRev int                `json:"expected_rev" doc:"The latest rev of the doc that was reviewed. Fails with ConflictError if it is no longer the latest."`
+	State       forms.DocState     `json:"state" doc:"New state of the doc: in_review, returned, accepted or rejected"`
+	Comments    []forms.DocComment `json:"comments,omitempty" doc:"Comments on the doc or its fields, required to return the doc to the submitter"`
+}
+
+func (req ReviewDocRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ID == "" {
+		return errors.Errorf("missing id")
+	}
+	if req.ExpectedRev < 1 {
+		return errors.Errorf("missing expected_rev")
+	}
+	switch req.State {
+	case forms.DocStateInReview, forms.DocStateAccepted, forms.DocStateRejected:
+	case forms.DocStateReturned:
+		if len(req.Comments) == 0 {
+			return errors.Errorf("missing comments to return the doc")
+		}
+	default:
+		return errors.Errorf("invalid state:\"%s\", expecting in_review, returned, accepted or rejected", req.State)
+	}
+	for i, c := range req.Comments {
+		if err := c.Validate(); err != nil {
+			return errors.Wrapf(err, "invalid comments[%d]", i)
+		}
+	}
+	return nil
+}
+
+type ReviewDocResponse struct {
+	Doc forms.Doc `json:"doc" doc:"The new revision of the doc with the transition appended to its history"`
+}
@@ -0,0 +1,111 @@
+package formsinterface
+
+import (
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+)
+
+// Revision describes one saved revision of a doc or form
+type Revision struct {
+	Rev       int       `json:"rev"`
+	Timestamp time.Time `json:"timestamp" doc:"Time when the revision was created"`
+	UpdatedBy string    `json:"updated_by,omitempty" doc:"User who made the revision"`
+}
+
+type ListDocRevisionsRequest struct {
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
+}
+
+func (req ListDocRevisionsRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ID == "" {
+		return errors.Errorf("missing id")
+	}
+	return nil
+}
+
+type ListDocRevisionsResponse struct {
+	Revisions []Revision `json:"revisions" doc:"In ascending order of rev. Drafts have no revisions until submitted."`
+}
+
+type DiffDocRequest struct {
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
+	FromRev   int       `json:"from_rev,omitempty" doc:"Older revision, defaults to the revision before to_rev"`
+	ToRev     int       `json:"to_rev,omitempty" doc:"Newer revision, use 0 for the latest"`
+}
+
+func (req DiffDocRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ID == "" {
+		return errors.Errorf("missing id")
+	}
+	if req.FromRev < 0 || req.ToRev < 0 {
+		return errors.Errorf("negative rev")
+	}
+	if req.ToRev > 0 && req.FromRev >= req.ToRev {
+		return errors.Errorf("from_rev:%d must be before to_rev:%d", req.FromRev, req.ToRev)
+	}
+	return nil
+}
+
+type DiffDocResponse struct {
+	From    Revision       `json:"from"`
+	To      Revision       `json:"to"`
+	Changes []forms.Change `json:"changes" doc:"Changes in the doc data by path, e.g. \"table_1[0].f1\""`
+}
+
+type ListFormRevisionsRequest struct {
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
+}
+
+func (req ListFormRevisionsRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ID == "" {
+		return errors.Errorf("missing id")
+	}
+	return nil
+}
+
+type ListFormRevisionsResponse struct {
+	Revisions []Revision `json:"revisions" doc:"In ascending order of rev"`
+}
+
+type DiffFormRequest struct {
+	Principal Principal `json:"principal" doc:"The authenticated user making the request"`
+	ID        string    `json:"id"`
+	FromRev   int       `json:"from_rev,omitempty" doc:"Older revision, defaults to the revision before to_rev"`
+	ToRev     int       `json:"to_rev,omitempty" doc:"Newer revision, use 0 for the latest"`
+}
+
+func (req DiffFormRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
+	if req.ID == "" {
+		return errors.Errorf("missing id")
+	}
+	if req.FromRev < 0 || req.ToRev < 0 {
+		return errors.Errorf("negative rev")
+	}
+	if req.ToRev > 0 && req.FromRev >= req.ToRev {
+		return errors.Errorf("from_rev:%d must be before to_rev:%d", req.FromRev, req.ToRev)
+	}
+	return nil
+}
+
+type DiffFormResponse struct {
+	From    Revision       `json:"from"`
+	To      Revision       `json:"to"`
+	Changes []forms.Change `json:"changes" doc:"Changes in the form by path, e.g. \"sections[0].items[2].field.title\""`
+}
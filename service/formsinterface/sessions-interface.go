@@ -5,11 +5,19 @@ import (
 	"github.com/go-msvc/forms"
 )
 
+// Session operations are only allowed for trusted services like the web app,
+// which specify their own principal and the device of the user. A session is
+// only authenticated by the service after verify_otp.
+
 type AddSessionRequest struct {
-	Session forms.Session `json:"session"`
+	Principal Principal     `json:"principal" doc:"A trusted service listed in the forms service config"`
+	Session   forms.Session `json:"session" doc:"Only data is used, the session is not authenticated"`
 }
 
 func (req AddSessionRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if err := req.Session.Validate(); err != nil {
 		return errors.Wrapf(err, "invalid session")
 	}
@@ -21,10 +29,14 @@ type AddSessionResponse struct {
 }
 
 type GetSessionRequest struct {
-	DeviceID string `json:"device_id"`
+	Principal Principal `json:"principal" doc:"A trusted service listed in the forms service config"`
+	DeviceID  string    `json:"device_id"`
 }
 
 func (req GetSessionRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.DeviceID == "" {
 		return errors.Errorf("missing device-id")
 	}
@@ -36,11 +48,16 @@ type GetSessionResponse struct {
 }
 
 type UpdSessionRequest struct {
-	DeviceID string        `json:"device_id"`
-	Session  forms.Session `json:"session"`
+	Principal Principal     `json:"principal" doc:"A trusted service listed in the forms service config"`
+	DeviceID  string        `json:"device_id"`
+	Session   forms.Session `json:"session" doc:"Only data is merged into the session, authenticated and email cannot be changed"`
+	Logout    bool          `json:"logout,omitempty" doc:"Ends the authenticated session on all its devices"`
 }
 
 func (req UpdSessionRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.DeviceID == "" {
 		return errors.Errorf("missing device_id")
 	}
@@ -55,10 +72,14 @@ type UpdSessionResponse struct {
 }
 
 type DelSessionRequest struct {
-	ID string `json:"id"`
+	Principal Principal `json:"principal" doc:"A trusted service listed in the forms service config"`
+	ID        string    `json:"id"`
 }
 
 func (req DelSessionRequest) Validate() error {
+	if err := req.Principal.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid principal")
+	}
 	if req.ID == "" {
 		return errors.Errorf("missing id")
 	}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// Checker is implemented by stores that can check (and repair) their own
+// consistency, e.g. after the service crashed while writing
+type Checker interface {
+	Check(kinds []string, repair bool) ([]formsinterface.FsckProblem, error)
+}
+
+// storeKinds lists all kinds kept in the store
+var storeKinds = []string{formsKind, docsKind, campaignsKind, sessionsKind, devicesKind, lockoutsKind}
+
+func fsck(ctx context.Context, req formsinterface.FsckRequest) (*formsinterface.FsckResponse, error) {
+	if err := authenticateService(&req.Principal, "store"); err != nil {
+		return nil, err
+	}
+	checker, ok := store.(Checker)
+	if !ok {
+		return nil, errors.Errorf("store %T does not support fsck", store)
+	}
+	problems, err := checker.Check(storeKinds, req.Repair)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to check store")
+	}
+	for _, p := range problems {
+		log.Errorf("fsck %s.id(%s) %s: %s (repaired:%v)", p.Kind, p.ID, p.File, p.Problem, p.Repaired)
+	}
+	if req.Repair && len(problems) > 0 {
+		//repairs may have changed the latest of some items, the current index
+		//keeps serving requests until the new one is swapped in
+		storeIndex.rebuild()
+		built, err := buildIndex(store)
+		if err != nil {
+			storeIndex.cancelRebuild()
+			return nil, errors.Wrapf(err, "failed to rebuild index after repair")
+		}
+		storeIndex.replace(built)
+	}
+	return &formsinterface.FsckResponse{
+		Problems: problems,
+	}, nil
+} //fsck()
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// newTestFilesStore returns a files store in a temp dir with forms f1 with
+// revisions 1 and 2 and f2 with revision 1
+func newTestFilesStore(t *testing.T) (*filesStore, string) {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := filesStoreConfig{Dir: dir}.Create()
+	if err != nil {
+		t.Fatalf("failed to create files store: %+v", err)
+	}
+	for _, f := range []forms.Form{
+		{ID: "f1", Rev: 1, UserID: "a@example.com"},
+		{ID: "f1", Rev: 2, UserID: "a@example.com"},
+		{ID: "f2", Rev: 1, UserID: "a@example.com"},
+	} {
+		if err := s.Save(formsKind, f.ID, f.Rev, f); err != nil {
+			t.Fatalf("failed to save form: %+v", err)
+		}
+	}
+	return s.(*filesStore), dir + "/" + formsKind
+} //newTestFilesStore()
+
+func writeTestFile(t *testing.T, filename string, data string) {
+	t.Helper()
+	if err := os.WriteFile(filename, []byte(data), 0660); err != nil {
+		t.Fatalf("failed to write %s: %+v", filename, err)
+	}
+} //writeTestFile()
+
+func TestFilesStoreCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		corrupt  func(t *testing.T, dir string)
+		problems map[string]string //file in the forms dir: problem
+		repaired bool
+		check    func(t *testing.T, s Store, dir string)
+	}{
+		{
+			name:    "no problems",
+			corrupt: func(t *testing.T, dir string) {},
+		},
+		{
+			name: "checksum mismatch repaired from latest",
+			corrupt: func(t *testing.T, dir string) {
+				writeTestFile(t, dir+"/f1/rev_2.json", `{"id":"f1","rev":2,"user_id":"x@example.com"}`+"\n")
+			},
+			problems: map[string]string{"f1/rev_2.json": "checksum mismatch"},
+			repaired: true,
+			check: func(t *testing.T, s Store, dir string) {
+				var f forms.Form
+				if err := s.Load(formsKind, "f1", 2, &f); err != nil || f.UserID != "a@example.com" {
+					t.Errorf("rev 2 not restored: %+v, %+v", f, err)
+				}
+			},
+		},
+		{
+			name: "checksum mismatch of an older revision",
+			corrupt: func(t *testing.T, dir string) {
+				writeTestFile(t, dir+"/f1/rev_1.json", `{"id":"f1","rev":1,"user_id":"x@example.com"}`+"\n")
+			},
+			problems: map[string]string{"f1/rev_1.json": "checksum mismatch"},
+			repaired: false, //latest is rev 2, so there is no good copy of rev 1
+		},
+		{
+			name: "temp files",
+			corrupt: func(t *testing.T, dir string) {
+				writeTestFile(t, dir+"/f1/"+tempFilePrefix+"123", `{"id":"f1","re`)
+				writeTestFile(t, dir+"/"+tempFilePrefix+"456", ``)
+			},
+			problems: map[string]string{
+				"f1/" + tempFilePrefix + "123": "temp file",
+				tempFilePrefix + "456":         "temp file",
+			},
+			repaired: true,
+			check: func(t *testing.T, s Store, dir string) {
+				if _, err := os.Stat(dir + "/f1/" + tempFilePrefix + "123"); !os.IsNotExist(err) {
+					t.Errorf("temp file not removed: %v", err)
+				}
+			},
+		},
+		{
+			name: "latest behind the last revision",
+			corrupt: func(t *testing.T, dir string) {
+				//crash after writing rev 3 but before the latest
+				rev3 := `{"id":"f1","rev":3,"user_id":"a@example.com"}` + "\n"
+				writeTestFile(t, dir+"/f1/rev_3.json", rev3)
+				writeTestFile(t, dir+"/f1/rev_3.sha256", checksum([]byte(rev3)))
+			},
+			problems: map[string]string{"f1/latest.json": "latest is not the same as rev_3"},
+			repaired: true,
+			check: func(t *testing.T, s Store, dir string) {
+				var f forms.Form
+				if err := s.Load(formsKind, "f1", 0, &f); err != nil || f.Rev != 3 {
+					t.Errorf("latest not rev 3: %+v, %+v", f, err)
+				}
+			},
+		},
+		{
+			name: "undecodable latest",
+			corrupt: func(t *testing.T, dir string) {
+				writeTestFile(t, dir+"/f2/latest.json", `{"id":"f2",`)
+			},
+			problems: map[string]string{"f2/latest.json": "latest is invalid"},
+			repaired: true,
+		},
+		{
+			name: "missing checksum",
+			corrupt: func(t *testing.T, dir string) {
+				os.Remove(dir + "/f1/rev_1.sha256")
+			},
+			problems: map[string]string{"f1/rev_1.json": "missing checksum"},
+			repaired: true,
+		},
+		{
+			name: "orphan directory",
+			corrupt: func(t *testing.T, dir string) {
+				os.Mkdir(dir+"/f3", 0770)
+			},
+			problems: map[string]string{"f3": "orphan directory"},
+			repaired: true,
+			check: func(t *testing.T, s Store, dir string) {
+				if ids, _ := s.List(formsKind); len(ids) != 2 {
+					t.Errorf("orphan directory not removed: %v", ids)
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, dir := newTestFilesStore(t)
+			test.corrupt(t, dir)
+
+			//first only report, then repair, then nothing is left to repair
+			for _, repair := range []bool{false, true} {
+				problems, err := s.Check(storeKinds, repair)
+				if err != nil {
+					t.Fatalf("check failed: %+v", err)
+				}
+				if len(problems) != len(test.problems) {
+					t.Fatalf("repair(%v) got %d problems %+v, expected %v", repair, len(problems), problems, test.problems)
+				}
+				for _, p := range problems {
+					expected, ok := test.problems[strings.TrimPrefix(p.File, dir+"/")]
+					if !ok || !strings.HasPrefix(p.Problem, expected) || p.Kind != formsKind {
+						t.Errorf("repair(%v) unexpected problem %+v, expected %v", repair, p, test.problems)
+					}
+					if p.Repaired != (repair && test.repaired) {
+						t.Errorf("repair(%v) problem %+v repaired:%v", repair, p, p.Repaired)
+					}
+				}
+			}
+			if test.check != nil {
+				test.check(t, s, dir)
+			}
+			problems, err := s.Check(storeKinds, false)
+			if err != nil {
+				t.Fatalf("check after repair failed: %+v", err)
+			}
+			if test.repaired && len(problems) > 0 {
+				t.Errorf("problems after repair: %+v", problems)
+			}
+		})
+	}
+} //TestFilesStoreCheck()
+
+func TestFsckRebuildsIndex(t *testing.T) {
+	s, dir := newTestFilesStore(t)
+	store = s
+	storeIndex.reset()
+	p := testPrincipals("fsck@example.com")["fsck@example.com"]
+
+	//f1 was saved as rev 3 but the service crashed before the latest was
+	//written and before it was indexed
+	rev3 := `{"id":"f1","rev":3,"user_id":"b@example.com"}` + "\n"
+	writeTestFile(t, dir+"/f1/rev_3.json", rev3)
+	writeTestFile(t, dir+"/f1/rev_3.sha256", checksum([]byte(rev3)))
+
+	res, err := fsck(context.Background(), formsinterface.FsckRequest{Principal: p, Repair: true})
+	if err != nil {
+		t.Fatalf("fsck failed: %+v", err)
+	}
+	if len(res.Problems) != 1 || !res.Problems[0].Repaired {
+		t.Fatalf("expected one repaired problem, got %+v", res.Problems)
+	}
+	e, ok := storeIndex.get(formsKind, "f1")
+	if !ok || e.UserID != "b@example.com" {
+		t.Fatalf("index not rebuilt from the repaired latest: %+v", e)
+	}
+	if _, ok := storeIndex.get(formsKind, "f2"); !ok {
+		t.Fatalf("f2 not in the rebuilt index")
+	}
+} //TestFsckRebuildsIndex()
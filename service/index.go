@@ -0,0 +1,386 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// indexEntry holds the lookup values of one item in the store
+// so that find operations do not have to load every item
+type indexEntry struct {
+	ID         string
+	UserID     string
+	FormID     string
+	CampaignID string
+	State      forms.DocState
+	Queue      string
+	Created    time.Time
+	Updated    time.Time
+	Expires    time.Time //zero when the item does not expire
+	Members    []string  //campaign members other than UserID
+	SessionID  string    //session of a device
+}
+
+// hasMember is true when the user created the campaign or is one of its members
+func (e indexEntry) hasMember(userID string) bool {
+	if userID == e.UserID {
+		return true
+	}
+	for _, m := range e.Members {
+		if m == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// index of all items in the store by kind, built when the service starts
+// and updated after each item is saved or deleted
+type index struct {
+	sync.Mutex
+	entries map[string]map[string]indexEntry //[kind][id]
+	changed map[string]map[string]bool       //[kind][id] set or deleted while a new index is built, nil when not building
+}
+
+func newIndex() *index {
+	return &index{entries: map[string]map[string]indexEntry{}}
+}
+
+var storeIndex = newIndex()
+
+// buildIndex loads the latest of each form, doc, campaign, session and device
+// from the store into a new index, which replace() swaps in while the current
+// index keeps serving requests
+func buildIndex(s Store) (*index, error) {
+	built := newIndex()
+	formIDs, err := s.List(formsKind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list forms")
+	}
+	for _, id := range formIDs {
+		var f, first forms.Form
+		if err := s.Load(formsKind, id, 0, &f); err != nil {
+			log.Errorf("not indexed (run fsck): failed to load form(%s): %+v", id, err)
+			continue
+		}
+		if err := s.Load(formsKind, id, 1, &first); err != nil {
+			first = f
+		}
+		built.set(formsKind, formIndexEntry(f, first.Timestamp))
+	}
+
+	docIDs, err := s.List(docsKind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list docs")
+	}
+	for _, id := range docIDs {
+		var d, first forms.Doc
+		if err := s.Load(docsKind, id, 0, &d); err != nil {
+			log.Errorf("not indexed (run fsck): failed to load doc(%s): %+v", id, err)
+			continue
+		}
+		if err := s.Load(docsKind, id, 1, &first); err != nil {
+			first = d
+		}
+		built.set(docsKind, docIndexEntry(d, first.Timestamp))
+	}
+
+	campaignIDs, err := s.List(campaignsKind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list campaigns")
+	}
+	for _, id := range campaignIDs {
+		var c forms.Campaign
+		if err := s.Load(campaignsKind, id, 0, &c); err != nil {
+			log.Errorf("not indexed (run fsck): failed to load campaign(%s): %+v", id, err)
+			continue
+		}
+		built.set(campaignsKind, campaignIndexEntry(c))
+	}
+
+	sessionIDs, err := s.List(sessionsKind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list sessions")
+	}
+	for _, id := range sessionIDs {
+		var session forms.Session
+		if err := s.Load(sessionsKind, id, 0, &session); err != nil {
+			log.Errorf("not indexed (run fsck): failed to load session(%s): %+v", id, err)
+			continue
+		}
+		built.set(sessionsKind, sessionIndexEntry(session))
+	}
+
+	deviceIDs, err := s.List(devicesKind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list devices")
+	}
+	for _, id := range deviceIDs {
+		var d forms.Device
+		if err := s.Load(devicesKind, id, 0, &d); err != nil {
+			log.Errorf("not indexed (run fsck): failed to load device(%s): %+v", id, err)
+			continue
+		}
+		built.set(devicesKind, deviceIndexEntry(d))
+	}
+	log.Debugf("indexed %d forms, %d docs, %d campaigns, %d sessions and %d devices", len(formIDs), len(docIDs), len(campaignIDs), len(sessionIDs), len(deviceIDs))
+	return built, nil
+} //buildIndex()
+
+func formIndexEntry(f forms.Form, created time.Time) indexEntry {
+	return indexEntry{
+		ID:      f.ID,
+		UserID:  f.UserID,
+		Created: created,
+		Updated: f.Timestamp,
+	}
+}
+
+func docIndexEntry(d forms.Doc, created time.Time) indexEntry {
+	e := indexEntry{
+		ID:         d.ID,
+		UserID:     d.UserID,
+		FormID:     d.FormID,
+		CampaignID: d.CampaignID,
+		State:      d.State,
+		Queue:      d.Queue,
+		Created:    created,
+		Updated:    d.Timestamp,
+	}
+	if d.Expires != nil {
+		e.Expires = *d.Expires
+	}
+	return e
+}
+
+func campaignIndexEntry(c forms.Campaign) indexEntry {
+	e := indexEntry{
+		ID:      c.ID,
+		UserID:  c.UserID,
+		FormID:  c.FormID,
+		Created: c.CreateTime,
+		Updated: c.UpdateTime,
+	}
+	for _, m := range c.Members {
+		e.Members = append(e.Members, m.UserID)
+	}
+	return e
+}
+
+// sessionIndexEntry has the email of authenticated sessions as UserID
+func sessionIndexEntry(s forms.Session) indexEntry {
+	e := indexEntry{
+		ID:      s.ID,
+		Created: s.TimeCreated,
+		Updated: s.TimeUpdated,
+		Expires: s.TimeExpires,
+	}
+	if s.Authenticated {
+		e.UserID = s.Email
+	}
+	return e
+}
+
+func deviceIndexEntry(d forms.Device) indexEntry {
+	return indexEntry{
+		ID:        d.ID,
+		SessionID: d.SessionID,
+		Created:   d.TimeCreated,
+		Updated:   d.TimeLast,
+		Expires:   d.TimeExpires,
+	}
+}
+
+func (i *index) reset() {
+	i.Lock()
+	defer i.Unlock()
+	i.entries = map[string]map[string]indexEntry{}
+}
+
+// rebuild starts to record the entries that change until replace() is called
+func (i *index) rebuild() {
+	i.Lock()
+	defer i.Unlock()
+	i.changed = map[string]map[string]bool{}
+}
+
+// cancelRebuild stops recording changes when no index was built
+func (i *index) cancelRebuild() {
+	i.Lock()
+	defer i.Unlock()
+	i.changed = nil
+}
+
+// replace swaps in the entries of the built index. Entries that changed since
+// rebuild() was called are kept, because the built index may have loaded them
+// before they changed.
+func (i *index) replace(built *index) {
+	i.Lock()
+	defer i.Unlock()
+	for kind, ids := range i.changed {
+		for id := range ids {
+			if e, ok := i.entries[kind][id]; ok {
+				built.setEntry(kind, e)
+			} else {
+				delete(built.entries[kind], id)
+			}
+		}
+	}
+	i.entries = built.entries
+	i.changed = nil
+}
+
+func (i *index) set(kind string, e indexEntry) {
+	i.Lock()
+	defer i.Unlock()
+	i.setEntry(kind, e)
+	i.markChanged(kind, e.ID)
+}
+
+// setEntry expects the lock to be held by the caller
+func (i *index) setEntry(kind string, e indexEntry) {
+	entryByID, ok := i.entries[kind]
+	if !ok {
+		entryByID = map[string]indexEntry{}
+		i.entries[kind] = entryByID
+	}
+	entryByID[e.ID] = e
+}
+
+// markChanged expects the lock to be held by the caller
+func (i *index) markChanged(kind string, id string) {
+	if i.changed == nil {
+		return //not rebuilding
+	}
+	if i.changed[kind] == nil {
+		i.changed[kind] = map[string]bool{}
+	}
+	i.changed[kind][id] = true
+}
+
+func (i *index) get(kind string, id string) (indexEntry, bool) {
+	i.Lock()
+	defer i.Unlock()
+	e, ok := i.entries[kind][id]
+	return e, ok
+}
+
+// created returns the creation time already indexed for kind.id, or t for a new entry
+func (i *index) created(kind string, id string, t time.Time) time.Time {
+	if e, ok := i.get(kind, id); ok {
+		return e.Created
+	}
+	return t
+}
+
+func (i *index) del(kind string, id string) {
+	i.Lock()
+	defer i.Unlock()
+	delete(i.entries[kind], id)
+	i.markChanged(kind, id)
+}
+
+// list returns the entries of kind that match, in no specific order
+func (i *index) list(kind string, match func(indexEntry) bool) []indexEntry {
+	i.Lock()
+	defer i.Unlock()
+	matches := []indexEntry{}
+	for _, e := range i.entries[kind] {
+		if match(e) {
+			matches = append(matches, e)
+		}
+	}
+	return matches
+}
+
+// find returns the ids in the requested page of entries that match,
+// with the total nr of matches and the cursor to the next page
+func (i *index) find(kind string, match func(indexEntry) bool, page formsinterface.Page) ([]string, formsinterface.PageInfo, error) {
+	matches := i.list(kind, match)
+
+	sortField := strings.TrimPrefix(page.Sort, "-")
+	descending := page.Sort == "" || strings.HasPrefix(page.Sort, "-")
+	if sortField == "" {
+		sortField = "updated"
+	}
+	sortTime := func(e indexEntry) time.Time {
+		if sortField == "created" {
+			return e.Created
+		}
+		return e.Updated
+	}
+	//less is true when a is listed before b
+	less := func(aTime time.Time, aID string, bTime time.Time, bID string) bool {
+		if !aTime.Equal(bTime) {
+			return aTime.Before(bTime) != descending
+		}
+		return aID < bID
+	}
+	sort.Slice(matches, func(a, b int) bool {
+		return less(sortTime(matches[a]), matches[a].ID, sortTime(matches[b]), matches[b].ID)
+	})
+
+	//skip entries up to and including the cursor
+	start := 0
+	if page.Cursor != "" {
+		cursorTime, cursorID, err := decodeCursor(page.Cursor)
+		if err != nil {
+			return nil, formsinterface.PageInfo{}, errors.Wrapf(err, "invalid cursor")
+		}
+		start = sort.Search(len(matches), func(n int) bool {
+			return less(cursorTime, cursorID, sortTime(matches[n]), matches[n].ID)
+		})
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = formsinterface.DefaultPageLimit
+	}
+	if limit > formsinterface.MaxPageLimit {
+		limit = formsinterface.MaxPageLimit
+	}
+	end := start + limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+	ids := []string{}
+	for _, e := range matches[start:end] {
+		ids = append(ids, e.ID)
+	}
+	info := formsinterface.PageInfo{Total: len(matches)}
+	if end < len(matches) && end > start {
+		last := matches[end-1]
+		info.NextCursor = encodeCursor(sortTime(last), last.ID)
+	}
+	return ids, info, nil
+} //index.find()
+
+// cursor is the sort key of the last item in a page
+func encodeCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", t.UnixNano(), id)))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", errors.Wrapf(err, "cannot decode")
+	}
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", errors.Errorf("not <time>:<id>")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", errors.Errorf("invalid time")
+	}
+	return time.Unix(0, nanos), parts[1], nil
+}
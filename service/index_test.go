@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// testIndex returns an index with n forms created a minute apart, of which
+// every 3 were updated at the same time to test the order of equal times
+func testIndex(n int) *index {
+	i := newIndex()
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	for x := 0; x < n; x++ {
+		i.set(formsKind, indexEntry{
+			ID:      fmt.Sprintf("f%03d", x),
+			UserID:  "a@example.com",
+			Created: t0.Add(time.Duration(x) * time.Minute),
+			Updated: t0.Add(time.Duration(x/3) * time.Hour),
+		})
+	}
+	return i
+} //testIndex()
+
+func TestIndexFindPages(t *testing.T) {
+	n := 50
+	i := testIndex(n)
+	all := func(indexEntry) bool { return true }
+	ascending := []string{}
+	for x := 0; x < n; x++ {
+		ascending = append(ascending, fmt.Sprintf("f%03d", x))
+	}
+	//equal updated times are listed by id in both directions
+	descendingUpdated := []string{}
+	for hour := (n - 1) / 3; hour >= 0; hour-- {
+		for x := hour * 3; x < hour*3+3 && x < n; x++ {
+			descendingUpdated = append(descendingUpdated, fmt.Sprintf("f%03d", x))
+		}
+	}
+	descendingCreated := []string{}
+	for x := n - 1; x >= 0; x-- {
+		descendingCreated = append(descendingCreated, fmt.Sprintf("f%03d", x))
+	}
+	tests := []struct {
+		sort     string
+		expected []string
+	}{
+		{"", descendingUpdated},
+		{"-updated", descendingUpdated},
+		{"updated", ascending},
+		{"created", ascending},
+		{"-created", descendingCreated},
+	}
+	for _, test := range tests {
+		for _, limit := range []int{1, 7, 25, 50, 100} {
+			t.Run(fmt.Sprintf("sort(%s).limit(%d)", test.sort, limit), func(t *testing.T) {
+				ids := []string{}
+				page := formsinterface.Page{Sort: test.sort, Limit: limit}
+				for pages := 0; ; pages++ {
+					if pages > n {
+						t.Fatalf("more than %d pages", n)
+					}
+					pageIDs, info, err := i.find(formsKind, all, page)
+					if err != nil {
+						t.Fatalf("find failed: %+v", err)
+					}
+					if info.Total != n {
+						t.Fatalf("total %d, expected %d", info.Total, n)
+					}
+					if len(pageIDs) > limit {
+						t.Fatalf("page of %d, expected at most %d", len(pageIDs), limit)
+					}
+					ids = append(ids, pageIDs...)
+					if info.NextCursor == "" {
+						break
+					}
+					page.Cursor = info.NextCursor
+				}
+				if !reflect.DeepEqual(ids, test.expected) {
+					t.Fatalf("got %v, expected %v", ids, test.expected)
+				}
+			})
+		}
+	}
+} //TestIndexFindPages()
+
+func TestIndexFindLimit(t *testing.T) {
+	i := testIndex(2 * formsinterface.MaxPageLimit)
+	all := func(indexEntry) bool { return true }
+	tests := []struct {
+		limit    int
+		expected int
+	}{
+		{0, formsinterface.DefaultPageLimit},
+		{-1, formsinterface.DefaultPageLimit},
+		{1, 1},
+		{formsinterface.MaxPageLimit, formsinterface.MaxPageLimit},
+		{formsinterface.MaxPageLimit + 1, formsinterface.MaxPageLimit},
+	}
+	for _, test := range tests {
+		ids, info, err := i.find(formsKind, all, formsinterface.Page{Limit: test.limit})
+		if err != nil {
+			t.Fatalf("limit(%d) find failed: %+v", test.limit, err)
+		}
+		if len(ids) != test.expected || info.NextCursor == "" {
+			t.Errorf("limit(%d) got %d ids and cursor \"%s\", expected %d and a cursor", test.limit, len(ids), info.NextCursor, test.expected)
+		}
+	}
+	if err := (formsinterface.Page{Limit: formsinterface.MaxPageLimit + 1}).Validate(); err == nil {
+		t.Errorf("page with limit above max is valid")
+	}
+} //TestIndexFindLimit()
+
+func TestIndexFindCursor(t *testing.T) {
+	i := testIndex(10)
+	all := func(indexEntry) bool { return true }
+	ids, info, err := i.find(formsKind, all, formsinterface.Page{Sort: "created", Limit: 4})
+	if err != nil {
+		t.Fatalf("find failed: %+v", err)
+	}
+
+	//the next page starts after the cursor, even when the item at the
+	//cursor was deleted in between
+	i.del(formsKind, ids[len(ids)-1])
+	next, _, err := i.find(formsKind, all, formsinterface.Page{Sort: "created", Limit: 4, Cursor: info.NextCursor})
+	if err != nil {
+		t.Fatalf("find next page failed: %+v", err)
+	}
+	if !reflect.DeepEqual(next, []string{"f004", "f005", "f006", "f007"}) {
+		t.Fatalf("next page %v", next)
+	}
+
+	for _, cursor := range []string{
+		"!not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("no separator")),
+		base64.RawURLEncoding.EncodeToString([]byte("time:f001")),
+	} {
+		if _, _, err := i.find(formsKind, all, formsinterface.Page{Cursor: cursor}); err == nil {
+			t.Errorf("invalid cursor \"%s\" accepted", cursor)
+		}
+	}
+} //TestIndexFindCursor()
+
+func TestFindDocFilters(t *testing.T) {
+	newTestStore(t)
+	p := testPrincipals("a@example.com", "b@example.com")
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	docs := []struct {
+		id      string
+		userID  string
+		formID  string
+		state   forms.DocState
+		created time.Time
+	}{
+		{"d1", "a@example.com", "f1", forms.DocStateSubmitted, t0},
+		{"d2", "a@example.com", "f1", forms.DocStateAccepted, t0.Add(time.Hour)},
+		{"d3", "a@example.com", "f2", forms.DocStateSubmitted, t0.Add(2 * time.Hour)},
+		{"d4", "a@example.com", "f1", forms.DocStateSubmitted, t0.Add(3 * time.Hour)},
+		{"d5", "b@example.com", "f1", forms.DocStateSubmitted, t0.Add(time.Hour)},
+	}
+	for _, d := range docs {
+		doc := forms.Doc{ID: d.id, Rev: 1, UserID: d.userID, FormID: d.formID, FormRev: 1, State: d.state, Timestamp: d.created}
+		if err := saveDoc(doc); err != nil {
+			t.Fatalf("failed to save doc: %+v", err)
+		}
+		storeIndex.set(docsKind, docIndexEntry(doc, d.created))
+	}
+	from := t0.Add(time.Hour)
+	to := t0.Add(3 * time.Hour)
+	tests := []struct {
+		name     string
+		req      formsinterface.FindDocRequest
+		expected []string
+	}{
+		{"own docs", formsinterface.FindDocRequest{}, []string{"d1", "d2", "d3", "d4"}},
+		{"form", formsinterface.FindDocRequest{FormID: "f1"}, []string{"d1", "d2", "d4"}},
+		{"form and state", formsinterface.FindDocRequest{FormID: "f1", State: forms.DocStateSubmitted}, []string{"d1", "d4"}},
+		{"created from", formsinterface.FindDocRequest{Created: &formsinterface.TimeRange{From: &from}}, []string{"d2", "d3", "d4"}},
+		{"created range", formsinterface.FindDocRequest{Created: &formsinterface.TimeRange{From: &from, To: &to}}, []string{"d2", "d3"}},
+		{"form and created range", formsinterface.FindDocRequest{FormID: "f1", Created: &formsinterface.TimeRange{From: &from, To: &to}}, []string{"d2"}},
+		{"all filters", formsinterface.FindDocRequest{FormID: "f1", State: forms.DocStateSubmitted, Created: &formsinterface.TimeRange{From: &from, To: &to}}, []string{}},
+		{"no match", formsinterface.FindDocRequest{FormID: "f3"}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.req.Principal = p["a@example.com"]
+			res, err := findDoc(context.Background(), test.req)
+			if err != nil {
+				t.Fatalf("find_docs failed: %+v", err)
+			}
+			ids := []string{}
+			for _, d := range res.Docs {
+				ids = append(ids, d.ID)
+			}
+			sort.Strings(ids)
+			if !reflect.DeepEqual(ids, test.expected) || res.Total != len(test.expected) {
+				t.Fatalf("got %v (total %d), expected %v", ids, res.Total, test.expected)
+			}
+		})
+	}
+	//docs of other users are not found without a campaign
+	if _, err := findDoc(context.Background(), formsinterface.FindDocRequest{Principal: p["a@example.com"], UserID: "b@example.com"}); !isPermissionDenied(err) {
+		t.Fatalf("expected PermissionDeniedError, got %+v", err)
+	}
+} //TestFindDocFilters()
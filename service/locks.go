@@ -0,0 +1,41 @@
+package main
+
+import "sync"
+
+// idLocks serializes writes to the same item so that two concurrent updates
+// cannot both read the same latest rev and write the same next rev
+type idLocks struct {
+	sync.Mutex
+	locks map[string]*idLock
+}
+
+type idLock struct {
+	sync.Mutex
+	nrUsers int
+}
+
+var writeLocks = &idLocks{locks: map[string]*idLock{}}
+
+// lock kind.id and return the func to unlock it
+func (l *idLocks) lock(kind string, id string) (unlock func()) {
+	key := kind + "/" + id
+	l.Lock()
+	il, ok := l.locks[key]
+	if !ok {
+		il = &idLock{}
+		l.locks[key] = il
+	}
+	il.nrUsers++
+	l.Unlock()
+
+	il.Lock()
+	return func() {
+		il.Unlock()
+		l.Lock()
+		il.nrUsers--
+		if il.nrUsers == 0 {
+			delete(l.locks, key)
+		}
+		l.Unlock()
+	}
+} //idLocks.lock()
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/google/uuid"
+)
+
+func init() {
+	config.MustConfigure("logins", loginsConfig{MaxAttempts: 5, Lockout: "1h", Backoff: "30s", MaxBackoff: "1h"})
+}
+
+// loginsConfig throttles the login of each email and each remote address,
+// e.g. {"logins":{"max_attempts":5,"lockout":"1h","backoff":"30s","max_backoff":"1h"}}
+type loginsConfig struct {
+	MaxAttempts int    `json:"max_attempts" doc:"Wrong OTPs after which the OTP is invalidated and the email or remote address is locked out"`
+	Lockout     string `json:"lockout" doc:"How long login is blocked after max_attempts wrong OTPs, e.g. \"1h\""`
+	Backoff     string `json:"backoff" doc:"Wait before another OTP can be requested, doubled on each request, e.g. \"30s\""`
+	MaxBackoff  string `json:"max_backoff" doc:"Longest wait between OTP requests, and the requests are forgotten after this long, e.g. \"1h\""`
+
+	lockout    time.Duration
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func (c *loginsConfig) Validate() error {
+	var err error
+	if c.MaxAttempts < 1 {
+		return errors.Errorf("max_attempts:%d must be positive", c.MaxAttempts)
+	}
+	if c.lockout, err = time.ParseDuration(c.Lockout); err != nil || c.lockout <= 0 {
+		return errors.Errorf("invalid lockout:\"%s\", expecting a positive duration like \"1h\"", c.Lockout)
+	}
+	if c.backoff, err = time.ParseDuration(c.Backoff); err != nil || c.backoff <= 0 {
+		return errors.Errorf("invalid backoff:\"%s\", expecting a positive duration like \"30s\"", c.Backoff)
+	}
+	if c.maxBackoff, err = time.ParseDuration(c.MaxBackoff); err != nil || c.maxBackoff < c.backoff {
+		return errors.Errorf("invalid max_backoff:\"%s\", expecting a duration of at least backoff like \"1h\"", c.MaxBackoff)
+	}
+	return nil
+}
+
+// wait returns how long after the previous OTP request another may be sent,
+// when n were requested, i.e. backoff * 2^(n-1) up to max_backoff
+func (c loginsConfig) wait(n int) time.Duration {
+	if n < 1 {
+		return 0
+	}
+	d := c.backoff
+	for i := 1; i < n && d < c.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > c.maxBackoff {
+		return c.maxBackoff
+	}
+	return d
+} //loginsConfig.wait()
+
+// loginEvent is a step in the login process, counted per email and remote
+// address to throttle and lock out abuse
+type loginEvent string
+
+const (
+	loginEventOtpRequested loginEvent = "otp_requested" //before an OTP is sent
+	loginEventOtpFailed    loginEvent = "otp_failed"    //a wrong OTP was entered
+	loginEventOtpPassed    loginEvent = "otp_passed"    //the correct OTP was entered
+)
+
+// loginDecision tells if a login step is allowed, else why not and until when
+type loginDecision struct {
+	allowed    bool
+	retryAfter time.Time
+	reason     string
+}
+
+// loginGuard counts login attempts per email and per remote address, not per
+// device, because the client can clear its device cookie to get a new device
+// id for every attempt. The counters are kept in memory because they only matter for a short time, but lockouts are
+// saved in the store for auditing.
+type loginGuard struct {
+	mutex    sync.Mutex
+	store    Store
+	config   loginsConfig
+	counters map[string]*loginCounter //by "email:<email>" or "address:<remote addr>"
+}
+
+type loginCounter struct {
+	subject     string //e.g. "email" or "address" to explain lockouts
+	requests    int    //OTPs requested, forgotten after max_backoff
+	lastRequest time.Time
+	failures    int //wrong OTPs since the last login or lockout
+	lockedUntil time.Time
+	updated     time.Time
+}
+
+// logins is created from config in main()
+var logins *loginGuard
+
+func newLoginGuard(s Store, c loginsConfig) *loginGuard {
+	return &loginGuard{
+		store:    s,
+		config:   c,
+		counters: map[string]*loginCounter{},
+	}
+} //newLoginGuard()
+
+// reserve counts an OTP attempt as failed before the OTP is compared, so that
+// parallel attempts cannot exceed max_attempts before their failures are
+// recorded. It is not allowed while locked out or when max_attempts are
+// already used. After the comparison, report the attempt with otp_failed or
+// otp_passed, or call unreserve() when the OTP was not compared.
+func (g *loginGuard) reserve(email string, remoteAddr string, now time.Time) loginDecision {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	counters := g.subjectCounters(email, remoteAddr, now)
+	if d := g.locked(counters, now); !d.allowed {
+		return d
+	}
+	for _, c := range counters {
+		if c.failures >= g.config.MaxAttempts {
+			return loginDecision{
+				allowed: false,
+				reason:  fmt.Sprintf("too many OTP attempts for the %s", c.subject),
+			}
+		}
+	}
+	for _, c := range counters {
+		c.failures++
+	}
+	return loginDecision{allowed: true}
+} //loginGuard.reserve()
+
+// unreserve takes back an attempt that was reserved but not used
+func (g *loginGuard) unreserve(email string, remoteAddr string, now time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for _, c := range g.subjectCounters(email, remoteAddr, now) {
+		if c.failures > 0 {
+			c.failures--
+		}
+	}
+} //loginGuard.unreserve()
+
+// locked returns a decision that is not allowed if any counter is locked out
+func (g *loginGuard) locked(counters []*loginCounter, now time.Time) loginDecision {
+	for _, c := range counters {
+		if c.lockedUntil.After(now) {
+			return loginDecision{
+				allowed:    false,
+				retryAfter: c.lockedUntil,
+				reason:     fmt.Sprintf("too many wrong OTPs for the %s", c.subject),
+			}
+		}
+	}
+	return loginDecision{allowed: true}
+} //loginGuard.locked()
+
+// attempt counts the login step and returns if it is allowed. The device is
+// only recorded in lockouts.
+func (g *loginGuard) attempt(event loginEvent, email string, deviceID string, remoteAddr string, now time.Time) (loginDecision, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	counters := g.subjectCounters(email, remoteAddr, now)
+
+	//nothing is allowed while locked out
+	if d := g.locked(counters, now); !d.allowed {
+		return d, nil
+	}
+
+	switch event {
+	case loginEventOtpRequested:
+		for _, c := range counters {
+			if now.Sub(c.lastRequest) > g.config.maxBackoff {
+				c.requests = 0
+			}
+			if next := c.lastRequest.Add(g.config.wait(c.requests)); c.requests > 0 && now.Before(next) {
+				return loginDecision{
+					allowed:    false,
+					retryAfter: next,
+					reason:     fmt.Sprintf("too many OTP requests for the %s", c.subject),
+				}, nil
+			}
+		}
+		for _, c := range counters {
+			c.requests++
+			c.lastRequest = now
+		}
+
+	case loginEventOtpFailed:
+		//the failure was counted when the attempt was reserved
+		d := loginDecision{allowed: true}
+		for _, c := range counters {
+			if c.failures < g.config.MaxAttempts {
+				continue
+			}
+			c.failures = 0
+			c.lockedUntil = now.Add(g.config.lockout)
+			d = loginDecision{
+				allowed:    false,
+				retryAfter: c.lockedUntil,
+				reason:     fmt.Sprintf("%d wrong OTPs for the %s", g.config.MaxAttempts, c.subject),
+			}
+			lockout := forms.Lockout{
+				ID:         uuid.New().String(),
+				Time:       now,
+				Until:      c.lockedUntil,
+				Email:      email,
+				DeviceID:   deviceID,
+				RemoteAddr: remoteAddr,
+				Reason:     d.reason,
+			}
+			if err := g.store.Save(lockoutsKind, lockout.ID, 0, lockout); err != nil {
+				return loginDecision{}, errors.Wrapf(err, "failed to save lockout")
+			}
+			log.Errorf("LOCKOUT email(%s) device(%s) addr(%s) until %s: %s", email, deviceID, remoteAddr, lockout.Until, lockout.Reason)
+		}
+		return d, nil
+
+	case loginEventOtpPassed:
+		delete(g.counters, "email:"+email)
+		delete(g.counters, "address:"+remoteAddr)
+	}
+	return loginDecision{allowed: true}, nil
+} //loginGuard.attempt()
+
+// subjectCounters returns the counters of the email and of the remote address,
+// when it is known
+func (g *loginGuard) subjectCounters(email string, remoteAddr string, now time.Time) []*loginCounter {
+	counters := []*loginCounter{g.counter("email", email, now)}
+	if remoteAddr != "" {
+		counters = append(counters, g.counter("address", remoteAddr, now))
+	}
+	return counters
+} //loginGuard.subjectCounters()
+
+// counter returns the counter of the subject, creating it if not found
+func (g *loginGuard) counter(subject string, id string, now time.Time) *loginCounter {
+	key := subject + ":" + id
+	c, ok := g.counters[key]
+	if !ok {
+		c = &loginCounter{subject: subject}
+		g.counters[key] = c
+	}
+	c.updated = now
+	return c
+} //loginGuard.counter()
+
+// sweep forgets unused counters every max backoff
+func (g *loginGuard) sweep() {
+	for {
+		time.Sleep(g.config.maxBackoff)
+		g.forget(time.Now())
+	}
+} //loginGuard.sweep()
+
+// forget deletes counters that are no longer locked and were not used for
+// longer than the lockout and max backoff
+func (g *loginGuard) forget(now time.Time) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for key, c := range g.counters {
+		if c.lockedUntil.Before(now) && now.Sub(c.updated) > g.config.lockout && now.Sub(c.updated) > g.config.maxBackoff {
+			delete(g.counters, key)
+		}
+	}
+} //loginGuard.forget()
+
+// requestOtp generates the OTP for the trusted caller to send to the email,
+// unless the email or remote address must wait before another OTP is sent
+func requestOtp(ctx context.Context, req formsinterface.RequestOtpRequest) (*formsinterface.RequestOtpResponse, error) {
+	if err := authenticateService(&req.Principal, sessionsKind); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	d, err := logins.attempt(loginEventOtpRequested, req.Email, req.DeviceID, req.RemoteAddr, now)
+	if err != nil {
+		return nil, err
+	}
+	if !d.allowed {
+		log.Debugf("OTP for email(%s) device(%s) denied until %s: %s", req.Email, req.DeviceID, d.retryAfter, d.reason)
+		return &formsinterface.RequestOtpResponse{Allowed: false, RetryAfter: d.retryAfter, Reason: d.reason}, nil
+	}
+	otp, err := loginOtp.newOtp()
+	if err != nil {
+		return nil, err
+	}
+	expiry := now.Add(loginOtp.ttl)
+	if err := sessions.setOtp(req.DeviceID, req.Email, otpHash(otp), expiry); err != nil {
+		return nil, err
+	}
+	return &formsinterface.RequestOtpResponse{
+		Allowed: true,
+		OTP:     otp,
+		Expiry:  expiry,
+	}, nil
+} //requestOtp()
+
+// verifyOtp logs the device in when the OTP is correct and neither the email
+// nor the remote address is locked out
+func verifyOtp(ctx context.Context, req formsinterface.VerifyOtpRequest) (*formsinterface.VerifyOtpResponse, error) {
+	if err := authenticateService(&req.Principal, sessionsKind); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	email, err := sessions.otpEmail(req.DeviceID, now)
+	if err != nil {
+		return nil, err
+	}
+	if email == "" {
+		return &formsinterface.VerifyOtpResponse{Allowed: false, Reason: "the OTP expired or was not sent"}, nil
+	}
+
+	//the email or address may have been locked out on another device, or
+	//too many attempts are being verified in parallel
+	if d := logins.reserve(email, req.RemoteAddr, now); !d.allowed {
+		if err := sessions.clearOtp(req.DeviceID); err != nil {
+			return nil, err
+		}
+		return &formsinterface.VerifyOtpResponse{Allowed: false, RetryAfter: d.retryAfter, Reason: d.reason}, nil
+	}
+
+	session, err := sessions.login(req.DeviceID, email, req.OTP, now)
+	if err != nil {
+		logins.unreserve(email, req.RemoteAddr, now)
+		return nil, err
+	}
+	if session == nil {
+		log.Errorf("device(%s) entered wrong OTP for %s", req.DeviceID, email)
+		d, err := logins.attempt(loginEventOtpFailed, email, req.DeviceID, req.RemoteAddr, now)
+		if err != nil {
+			return nil, err
+		}
+		if !d.allowed {
+			//locked out - the OTP cannot be used any more
+			if err := sessions.clearOtp(req.DeviceID); err != nil {
+				return nil, err
+			}
+			return &formsinterface.VerifyOtpResponse{Allowed: false, RetryAfter: d.retryAfter, Reason: d.reason}, nil
+		}
+		return &formsinterface.VerifyOtpResponse{Allowed: true, Reason: "wrong OTP"}, nil
+	}
+	if _, err := logins.attempt(loginEventOtpPassed, email, req.DeviceID, req.RemoteAddr, now); err != nil {
+		return nil, err
+	}
+	return &formsinterface.VerifyOtpResponse{
+		LoggedIn: true,
+		Allowed:  true,
+		Session:  *session,
+	}, nil
+} //verifyOtp()
+
+func listLockouts(ctx context.Context, req formsinterface.ListLockoutsRequest) (*formsinterface.ListLockoutsResponse, error) {
+	if err := authenticateService(&req.Principal, lockoutsKind); err != nil {
+		return nil, err
+	}
+	ids, err := store.List(lockoutsKind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list lockouts")
+	}
+	res := &formsinterface.ListLockoutsResponse{
+		Lockouts: []forms.Lockout{},
+	}
+	for _, id := range ids {
+		var lockout forms.Lockout
+		if err := store.Load(lockoutsKind, id, 0, &lockout); err != nil {
+			return nil, errors.Wrapf(err, "failed to load lockout(%s)", id)
+		}
+		if req.Email != "" && lockout.Email != req.Email {
+			continue
+		}
+		res.Lockouts = append(res.Lockouts, lockout)
+	}
+	sort.Slice(res.Lockouts, func(i, j int) bool { return res.Lockouts[i].Time.After(res.Lockouts[j].Time) })
+	return res, nil
+} //listLockouts()
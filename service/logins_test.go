@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// newTestLogins makes the sessions, login guard and OTP config of the service
+// with an empty memory store
+func newTestLogins(t *testing.T, c loginsConfig) {
+	t.Helper()
+	s := newTestStore(t)
+	sc := sessionsConfig{IdleTTL: "1h", MaxTTL: "2h", SweepInterval: "1h"}
+	if err := sc.Validate(); err != nil {
+		t.Fatalf("invalid sessions config: %+v", err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("invalid logins config: %+v", err)
+	}
+	oc := otpConfig{Length: 6, TTL: "10m"}
+	if err := oc.Validate(); err != nil {
+		t.Fatalf("invalid otp config: %+v", err)
+	}
+	sessions = newSessionManager(s, sc)
+	logins = newLoginGuard(s, c)
+	loginOtp = oc
+} //newTestLogins()
+
+// TestVerifyOtpParallel fires more wrong OTPs than max_attempts in parallel,
+// which must lock out the email and device
+func TestVerifyOtpParallel(t *testing.T) {
+	c := loginsConfig{MaxAttempts: 5, Lockout: "1h", Backoff: "30s", MaxBackoff: "1h"}
+	newTestLogins(t, c)
+	p := testPrincipals("web@example.com")["web@example.com"]
+	email := "a@example.com"
+	if _, err := sessions.get("d1"); err != nil {
+		t.Fatalf("get failed: %+v", err)
+	}
+	if err := sessions.setOtp("d1", email, otpHash("ABCDEF"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("setOtp failed: %+v", err)
+	}
+
+	ctx := context.Background()
+	mutex := sync.Mutex{}
+	wrong := 0
+	wg := sync.WaitGroup{}
+	for i := 0; i < c.MaxAttempts+1; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			//errors are expected when the OTP was cleared by the lockout
+			res, err := verifyOtp(ctx, formsinterface.VerifyOtpRequest{Principal: p, DeviceID: "d1", OTP: "ABCDEG", RemoteAddr: "10.0.0.1"})
+			if err != nil {
+				return
+			}
+			if res.LoggedIn {
+				t.Errorf("wrong OTP logged in")
+			}
+			if res.Allowed {
+				mutex.Lock()
+				wrong++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if wrong > c.MaxAttempts-1 {
+		t.Errorf("%d wrong OTPs allowed to retry, expected at most %d", wrong, c.MaxAttempts-1)
+	}
+
+	ids, err := store.List(lockoutsKind)
+	if err != nil {
+		t.Fatalf("failed to list lockouts: %+v", err)
+	}
+	if len(ids) == 0 {
+		t.Fatalf("no lockout after %d parallel wrong OTPs", c.MaxAttempts+1)
+	}
+
+	//even the correct OTP is refused while locked out
+	if err := sessions.setOtp("d1", email, otpHash("ABCDEF"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("setOtp failed: %+v", err)
+	}
+	res, err := verifyOtp(ctx, formsinterface.VerifyOtpRequest{Principal: p, DeviceID: "d1", OTP: "ABCDEF"})
+	if err != nil {
+		t.Fatalf("verify_otp failed: %+v", err)
+	}
+	if res.LoggedIn || res.Allowed || res.RetryAfter.IsZero() {
+		t.Fatalf("expected lockout, got %+v", res)
+	}
+} //TestVerifyOtpParallel()
+
+// newTestGuard returns a login guard with an empty memory store
+func newTestGuard(t *testing.T) (*loginGuard, Store) {
+	t.Helper()
+	s, err := memoryStoreConfig{}.Create()
+	if err != nil {
+		t.Fatalf("failed to create memory store: %+v", err)
+	}
+	c := loginsConfig{MaxAttempts: 3, Lockout: "1h", Backoff: "30s", MaxBackoff: "10m"}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("invalid logins config: %+v", err)
+	}
+	return newLoginGuard(s, c), s
+} //newTestGuard()
+
+func TestLoginGuardBackoff(t *testing.T) {
+	g, _ := newTestGuard(t)
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		email      string
+		addr       string
+		at         time.Duration
+		allowed    bool
+		retryAfter time.Duration
+	}{
+		{"first", "a@example.com", "10.0.0.1", 0, true, 0},
+		{"again at once", "a@example.com", "10.0.0.1", time.Second, false, 30 * time.Second},
+		{"same email other address", "a@example.com", "10.0.0.2", time.Second, false, 30 * time.Second},
+		{"same address other email", "b@example.com", "10.0.0.1", time.Second, false, 30 * time.Second},
+		{"other email and address", "b@example.com", "10.0.0.2", time.Second, true, 0},
+		{"after backoff", "a@example.com", "10.0.0.1", 30 * time.Second, true, 0},
+		{"backoff doubles", "a@example.com", "10.0.0.1", 60 * time.Second, false, 90 * time.Second},
+		{"after doubled backoff", "a@example.com", "10.0.0.1", 90 * time.Second, true, 0},
+		{"after max backoff", "a@example.com", "10.0.0.1", 90*time.Second + 10*time.Minute + time.Second, true, 0},
+		{"backoff restarted", "a@example.com", "10.0.0.1", 90*time.Second + 10*time.Minute + 2*time.Second, false, 90*time.Second + 10*time.Minute + 31*time.Second},
+	}
+	for _, test := range tests {
+		d, err := g.attempt(loginEventOtpRequested, test.email, "d1", test.addr, t0.Add(test.at))
+		if err != nil {
+			t.Fatalf("%s: attempt failed: %+v", test.name, err)
+		}
+		if d.allowed != test.allowed {
+			t.Fatalf("%s: allowed:%v, expected %v (%s)", test.name, d.allowed, test.allowed, d.reason)
+		}
+		if !test.allowed && !d.retryAfter.Equal(t0.Add(test.retryAfter)) {
+			t.Fatalf("%s: retry after %s, expected %s", test.name, d.retryAfter, t0.Add(test.retryAfter))
+		}
+	}
+} //TestLoginGuardBackoff()
+
+func TestLoginGuardLockout(t *testing.T) {
+	g, s := newTestGuard(t)
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	email := "a@example.com"
+	addr := "10.0.0.1"
+
+	//a new device cookie for every attempt does not avoid the lockout
+	var d loginDecision
+	for i := 0; i < g.config.MaxAttempts; i++ {
+		if d = g.reserve(email, addr, now); !d.allowed {
+			t.Fatalf("attempt %d not allowed: %s", i+1, d.reason)
+		}
+		var err error
+		if d, err = g.attempt(loginEventOtpFailed, email, fmt.Sprintf("d%d", i), addr, now); err != nil {
+			t.Fatalf("attempt failed: %+v", err)
+		}
+		if i < g.config.MaxAttempts-1 && !d.allowed {
+			t.Fatalf("locked out after %d wrong OTPs", i+1)
+		}
+	}
+	if d.allowed || !d.retryAfter.Equal(now.Add(g.config.lockout)) {
+		t.Fatalf("not locked out after %d wrong OTPs: %+v", g.config.MaxAttempts, d)
+	}
+	ids, err := s.List(lockoutsKind)
+	if err != nil || len(ids) != 2 {
+		t.Fatalf("expected lockouts of the email and address, got %v, %+v", ids, err)
+	}
+
+	//while locked out nothing is allowed for the email or the address
+	later := now.Add(time.Minute)
+	for _, test := range []struct{ email, addr string }{
+		{email, addr},
+		{email, "10.0.0.2"},
+		{"b@example.com", addr},
+	} {
+		if d := g.reserve(test.email, test.addr, later); d.allowed {
+			t.Errorf("email(%s) addr(%s) allowed to verify while locked out", test.email, test.addr)
+		}
+		if d, _ := g.attempt(loginEventOtpRequested, test.email, "d9", test.addr, later); d.allowed {
+			t.Errorf("email(%s) addr(%s) allowed to request while locked out", test.email, test.addr)
+		}
+	}
+	if d := g.reserve("b@example.com", "10.0.0.2", later); !d.allowed {
+		t.Errorf("other email and address not allowed: %s", d.reason)
+	}
+
+	//after the lockout the count starts again, and a login resets it
+	after := now.Add(g.config.lockout)
+	if d := g.reserve(email, addr, after); !d.allowed {
+		t.Fatalf("not allowed after lockout: %s", d.reason)
+	}
+	if _, err := g.attempt(loginEventOtpPassed, email, "d1", addr, after); err != nil {
+		t.Fatalf("attempt failed: %+v", err)
+	}
+	if len(g.counters) != 2 { //only b@example.com and 10.0.0.2
+		t.Fatalf("counters not deleted after login: %d", len(g.counters))
+	}
+} //TestLoginGuardLockout()
+
+func TestLoginGuardUnreserve(t *testing.T) {
+	g, _ := newTestGuard(t)
+	now := time.Now()
+	//attempts that never compared the OTP do not count
+	for i := 0; i < 2*g.config.MaxAttempts; i++ {
+		if d := g.reserve("a@example.com", "10.0.0.1", now); !d.allowed {
+			t.Fatalf("attempt %d not allowed: %s", i+1, d.reason)
+		}
+		g.unreserve("a@example.com", "10.0.0.1", now)
+	}
+	//reserved attempts count before they are reported
+	for i := 0; i < g.config.MaxAttempts; i++ {
+		if d := g.reserve("a@example.com", "10.0.0.1", now); !d.allowed {
+			t.Fatalf("attempt %d not allowed: %s", i+1, d.reason)
+		}
+	}
+	if d := g.reserve("a@example.com", "10.0.0.1", now); d.allowed {
+		t.Fatalf("more than %d attempts reserved", g.config.MaxAttempts)
+	}
+} //TestLoginGuardUnreserve()
+
+func TestLoginGuardForget(t *testing.T) {
+	g, _ := newTestGuard(t)
+	t0 := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	g.attempt(loginEventOtpRequested, "a@example.com", "d1", "10.0.0.1", t0)
+	t1 := t0.Add(time.Minute)
+	for i := 0; i < g.config.MaxAttempts; i++ {
+		g.reserve("b@example.com", "10.0.0.2", t1)
+		g.attempt(loginEventOtpFailed, "b@example.com", "d2", "10.0.0.2", t1)
+	}
+	if len(g.counters) != 4 {
+		t.Fatalf("%d counters, expected 4", len(g.counters))
+	}
+	tests := []struct {
+		name    string
+		at      time.Time
+		counted []string
+	}{
+		{"used recently", t0.Add(g.config.maxBackoff + time.Second), []string{"email:a@example.com", "address:10.0.0.1", "email:b@example.com", "address:10.0.0.2"}},
+		{"not used for lockout", t0.Add(g.config.lockout + time.Second), []string{"email:b@example.com", "address:10.0.0.2"}},
+		{"lockout ended", t1.Add(g.config.lockout + time.Second), []string{}},
+	}
+	for _, test := range tests {
+		g.forget(test.at)
+		if len(g.counters) != len(test.counted) {
+			t.Fatalf("%s: %d counters, expected %v", test.name, len(g.counters), test.counted)
+		}
+		for _, key := range test.counted {
+			if _, ok := g.counters[key]; !ok {
+				t.Fatalf("%s: forgot %s", test.name, key)
+			}
+		}
+	}
+} //TestLoginGuardForget()
@@ -14,27 +14,66 @@ func main() {
 		ms.WithOper("upd_form", updForm),
 		ms.WithOper("del_form", delForm),
 		ms.WithOper("find_forms", findForm),
+		ms.WithOper("list_form_revisions", listFormRevisions),
+		ms.WithOper("diff_form", diffForm),
 
 		ms.WithOper("add_doc", addDoc),
 		ms.WithOper("get_doc", getDoc),
 		ms.WithOper("upd_doc", updDoc),
 		ms.WithOper("del_doc", delDoc),
 		ms.WithOper("find_docs", findDoc),
+		ms.WithOper("list_doc_revisions", listDocRevisions),
+		ms.WithOper("diff_doc", diffDoc),
+		ms.WithOper("review_doc", reviewDoc),
+		ms.WithOper("list_queue", listQueue),
+		ms.WithOper("move_doc", moveDoc),
+
+		ms.WithOper("save_draft", saveDraft),
+		ms.WithOper("get_draft", getDraft),
+		ms.WithOper("submit_draft", submitDraft),
 
 		ms.WithOper("add_campaign", addCampaign),
 		ms.WithOper("get_campaign", getCampaign),
 		ms.WithOper("upd_campaign", updCampaign),
 		ms.WithOper("del_campaign", delCampaign),
 		ms.WithOper("find_campaigns", findCampaigns),
+		ms.WithOper("invite_member", inviteMember),
+		ms.WithOper("remove_member", removeMember),
 
 		ms.WithOper("add_session", addSession),
 		ms.WithOper("get_session", getSession),
 		ms.WithOper("upd_session", updSession),
 		ms.WithOper("del_session", delSession),
+		ms.WithOper("list_devices", listDevices),
+		ms.WithOper("rename_device", renameDevice),
+		ms.WithOper("revoke_device", revokeDevice),
+		ms.WithOper("request_otp", requestOtp),
+		ms.WithOper("verify_otp", verifyOtp),
+		ms.WithOper("list_lockouts", listLockouts),
+
+		ms.WithOper("fsck", fsck),
 	)
 	if err := config.Load(); err != nil {
 		panic(err)
 	}
+	store = config.Get("store").(Store)
+	if err := migrateFormOwners(store, config.Get("forms").(formsConfig).LegacyOwner); err != nil {
+		panic(err)
+	}
+	built, err := buildIndex(store)
+	if err != nil {
+		panic(err)
+	}
+	storeIndex.replace(built)
+	principals = config.Get("principals").(principalsConfig)
+	sessions = newSessionManager(store, config.Get("sessions").(sessionsConfig))
+	go sessions.sweep()
+	logins = newLoginGuard(store, config.Get("logins").(loginsConfig))
+	loginOtp = config.Get("otp").(otpConfig)
+	go logins.sweep()
+	notifications = newNotifications(config.Get("notifications").(notificationsConfig))
+	drafts = config.Get("drafts").(draftsConfig)
+	go sweepDrafts(drafts)
 	ms.Configure()
 	ms.Serve()
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// campaignAccess checks that the user has the role, or a role that includes
+// it, in the campaign
+func campaignAccess(campaign forms.Campaign, userID string, role forms.CampaignRole) error {
+	if !campaign.HasRole(userID, role) {
+		return formsinterface.PermissionDeniedError{UserID: userID, Kind: campaignsKind, ID: campaign.ID, Reason: fmt.Sprintf("not a %s of the campaign", role)}
+	}
+	return nil
+} //campaignAccess()
+
+// docAccess checks that the user submitted the doc or has the role in the
+// campaign where it was submitted. A draft is only accessed by its user,
+// whatever the role of others in the campaign.
+func docAccess(doc forms.Doc, userID string, role forms.CampaignRole) error {
+	if doc.UserID == userID {
+		return nil
+	}
+	if doc.State == forms.DocStateDraft {
+		return formsinterface.PermissionDeniedError{UserID: userID, Kind: docsKind, ID: doc.ID, Reason: "draft belongs to another user"}
+	}
+	if doc.CampaignID == "" {
+		return formsinterface.PermissionDeniedError{UserID: userID, Kind: docsKind, ID: doc.ID, Reason: "doc belongs to another user"}
+	}
+	campaign, err := loadCampaign(doc.CampaignID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load campaign(%s)", doc.CampaignID)
+	}
+	return campaignAccess(campaign, userID, role)
+} //docAccess()
+
+func inviteMember(ctx context.Context, req formsinterface.InviteMemberRequest) (*formsinterface.InviteMemberResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	campaign, err := updMembers(req.CampaignID, req.Principal, func(c *forms.Campaign) error {
+		if req.Member.UserID == c.UserID {
+			return errors.Errorf("user(%s) created the campaign and is always an owner", c.UserID)
+		}
+		for i, m := range c.Members {
+			if m.UserID == req.Member.UserID {
+				c.Members[i].Role = req.Member.Role
+				return nil
+			}
+		}
+		c.Members = append(c.Members, req.Member)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &formsinterface.InviteMemberResponse{
+		Campaign: campaign,
+	}, nil
+} //inviteMember()
+
+func removeMember(ctx context.Context, req formsinterface.RemoveMemberRequest) (*formsinterface.RemoveMemberResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	campaign, err := updMembers(req.CampaignID, req.Principal, func(c *forms.Campaign) error {
+		for i, m := range c.Members {
+			if m.UserID == req.UserID {
+				c.Members = append(c.Members[:i], c.Members[i+1:]...)
+				return nil
+			}
+		}
+		return errors.Errorf("user(%s) is not a member of campaign(%s)", req.UserID, c.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &formsinterface.RemoveMemberResponse{
+		Campaign: campaign,
+	}, nil
+} //removeMember()
+
+// updMembers changes the members of a campaign, which only owners may do, and
+// saves it as a new revision
+func updMembers(campaignID string, principal formsinterface.Principal, change func(c *forms.Campaign) error) (forms.Campaign, error) {
+	unlock := writeLocks.lock(campaignsKind, campaignID)
+	defer unlock()
+	campaign, err := loadCampaign(campaignID)
+	if err != nil {
+		return forms.Campaign{}, errors.Wrapf(err, "failed to load existing campaign")
+	}
+	if err := campaignAccess(campaign, principal.UserID, forms.CampaignRoleOwner); err != nil {
+		return forms.Campaign{}, err
+	}
+	if err := change(&campaign); err != nil {
+		return forms.Campaign{}, err
+	}
+	if err := campaign.Validate(); err != nil {
+		return forms.Campaign{}, errors.Wrapf(err, "invalid campaign")
+	}
+	campaign.Rev++
+	campaign.UpdateTime = time.Now()
+	if err := saveCampaign(campaign); err != nil {
+		return forms.Campaign{}, errors.Wrapf(err, "failed to save campaign")
+	}
+	storeIndex.set(campaignsKind, campaignIndexEntry(campaign))
+	return campaign, nil
+} //updMembers()
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/go-redis/redis/v8"
+)
+
+func init() {
+	config.MustConfigure("notifications", notificationsConfig{})
+}
+
+// notificationsConfig specifies where notifications about docs are pushed,
+// e.g. {"notifications":{"redis":"localhost:6379"}}
+type notificationsConfig struct {
+	Redis string `json:"redis,omitempty" doc:"Redis address, e.g. \"localhost:6379\". If not specified, notifications are only logged."`
+}
+
+func (c notificationsConfig) Validate() error {
+	return nil
+}
+
+// notifications is created from config in main(), nil when not configured
+var notifications *redis.Client
+
+func newNotifications(c notificationsConfig) *redis.Client {
+	if c.Redis == "" {
+		return nil
+	}
+	return redis.NewClient(&redis.Options{
+		Addr: c.Redis,
+	})
+} //newNotifications()
+
+// notifyDoc pushes a formsinterface.CampaignNotification to the campaign queue
+// after a doc revision was saved, e.g. when it was submitted, edited or changed
+// state in the review workflow. Failures are logged because the doc was saved.
+func notifyDoc(doc forms.Doc) {
+	if err := pushDocNotification(doc); err != nil {
+		log.Errorf("failed to notify doc(%s).rev(%d): %+v", doc.ID, doc.Rev, err)
+	}
+} //notifyDoc()
+
+func pushDocNotification(doc forms.Doc) error {
+	if doc.CampaignID == "" {
+		return nil
+	}
+	campaign, err := loadCampaign(doc.CampaignID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load campaign(%s)", doc.CampaignID)
+	}
+	notification := formsinterface.CampaignNotification{
+		CampaingID: campaign.ID,
+		DocID:      doc.ID,
+		DocRev:     doc.Rev,
+		State:      doc.State,
+		Queue:      doc.Queue,
+	}
+	if n := len(doc.History); n > 0 && doc.History[n-1].DocRev == doc.Rev {
+		notification.Transition = &doc.History[n-1]
+	}
+	if n := len(doc.Moves); n > 0 && doc.Moves[n-1].DocRev == doc.Rev {
+		notification.Move = &doc.Moves[n-1]
+	}
+	jsonNotification, _ := json.Marshal(notification)
+	if notifications == nil {
+		log.Debugf("notification for queue(%s): %s", campaign.NotificationQueue(), jsonNotification)
+		return nil
+	}
+	if _, err := notifications.LPush(context.Background(), campaign.NotificationQueue(), jsonNotification).Result(); err != nil {
+		return errors.Wrapf(err, "failed to push to queue(%s)", campaign.NotificationQueue())
+	}
+	return nil
+} //pushDocNotification()
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+)
+
+func init() {
+	config.MustConfigure("otp", otpConfig{Length: 6, TTL: "10m"})
+}
+
+// otpConfig controls the OTP generated to login, e.g. {"otp":{"length":6,"ttl":"10m"}}
+type otpConfig struct {
+	Length int    `json:"length" doc:"Number of letters in the OTP"`
+	TTL    string `json:"ttl" doc:"How long the OTP can be used after it was requested, e.g. \"10m\""`
+
+	ttl time.Duration
+}
+
+func (c *otpConfig) Validate() error {
+	var err error
+	if c.Length < 4 || c.Length > 32 {
+		return errors.Errorf("length:%d must be 4..32", c.Length)
+	}
+	if c.ttl, err = time.ParseDuration(c.TTL); err != nil || c.ttl <= 0 {
+		return errors.Errorf("invalid ttl:\"%s\", expecting a positive duration like \"10m\"", c.TTL)
+	}
+	return nil
+}
+
+// loginOtp is loaded from config in main()
+var loginOtp otpConfig
+
+// otpLetters excludes letters that are easily confused, e.g. I and O with 1 and 0
+const otpLetters = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// newOtp returns a random OTP from a cryptographically secure source
+func (c otpConfig) newOtp() (string, error) {
+	letters := make([]byte, c.Length)
+	max := big.NewInt(int64(len(otpLetters)))
+	for i := range letters {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to generate OTP")
+		}
+		letters[i] = otpLetters[n.Int64()]
+	}
+	return string(letters), nil
+} //otpConfig.newOtp()
+
+// otpHash returns the hash kept in the session, so the OTP cannot be read from
+// the store
+func otpHash(otp string) string {
+	sum := sha256.Sum256([]byte(strings.ToUpper(strings.TrimSpace(otp))))
+	return hex.EncodeToString(sum[:])
+} //otpHash()
+
+// otpMatch compares the hash of the entered OTP in constant time so that the
+// response time does not reveal how much of it is correct
+func otpMatch(entered string, expectedHash string) bool {
+	if expectedHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(otpHash(entered)), []byte(expectedHash)) == 1
+} //otpMatch()
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/subtle"
+	"os"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+func init() {
+	config.MustConfigure("principals", principalsConfig{})
+}
+
+// principalsConfig lists the services trusted to specify a user_id without a
+// session, with the environment variable holding the secret of each, e.g.
+// {"principals":{"services":[{"user_id":"consumer@example.com","secret_env":"FORMS_CONSUMER_SECRET"}]}}
+type principalsConfig struct {
+	Services []serviceConfig `json:"services,omitempty" doc:"Trusted services like the consumer, which still need a role in each campaign they access"`
+}
+
+// serviceConfig is a trusted service that identifies itself with its user_id
+// and the secret shared with the forms service
+type serviceConfig struct {
+	UserID    string `json:"user_id" doc:"User ID of the service"`
+	SecretEnv string `json:"secret_env" doc:"Environment variable with the secret that the service specifies in its principal, at least 32 characters"`
+
+	secret string
+}
+
+func (c *principalsConfig) Validate() error {
+	userIDs := map[string]bool{}
+	for i := range c.Services {
+		s := &c.Services[i]
+		if s.UserID == "" {
+			return errors.Errorf("services[%d] missing user_id", i)
+		}
+		if userIDs[s.UserID] {
+			return errors.Errorf("services[%d] duplicate user_id:\"%s\"", i, s.UserID)
+		}
+		userIDs[s.UserID] = true
+		if s.SecretEnv == "" {
+			return errors.Errorf("services[%d] missing secret_env", i)
+		}
+		s.secret = os.Getenv(s.SecretEnv)
+		if err := formsinterface.ValidateSecret(s.secret); err != nil {
+			return errors.Wrapf(err, "services[%d] invalid secret in %s", i, s.SecretEnv)
+		}
+	}
+	return nil
+}
+
+// principals is loaded from config in main()
+var principals principalsConfig
+
+// authenticate sets principal.UserID to the email of the authenticated session
+// or checks that the user_id and secret are of a trusted service. Every
+// operation calls it before using the principal to check access.
+func authenticate(principal *formsinterface.Principal) error {
+	if principal.SessionID == "" {
+		for _, s := range principals.Services {
+			//compare the secret in constant time so the response time does not reveal it
+			if principal.UserID != "" && principal.UserID == s.UserID &&
+				subtle.ConstantTimeCompare([]byte(principal.Secret), []byte(s.secret)) == 1 {
+				return nil
+			}
+		}
+		return formsinterface.PermissionDeniedError{Kind: "sessions", Reason: "user_id and secret without session_id is not a trusted service"}
+	}
+	email, err := sessions.authenticatedEmail(principal.SessionID)
+	if err != nil {
+		return err
+	}
+	if email == "" {
+		return formsinterface.PermissionDeniedError{Kind: "sessions", ID: principal.SessionID, Reason: "session is not authenticated"}
+	}
+	if principal.UserID != "" && principal.UserID != email {
+		return formsinterface.PermissionDeniedError{Kind: "sessions", ID: principal.SessionID, Reason: "user_id is not the session email"}
+	}
+	principal.UserID = email
+	return nil
+} //authenticate()
+
+// authenticateService checks that the principal is a trusted service and not a
+// user session, for operations that are not limited to one user
+func authenticateService(principal *formsinterface.Principal, kind string) error {
+	if err := authenticate(principal); err != nil {
+		return err
+	}
+	if principal.SessionID != "" {
+		return formsinterface.PermissionDeniedError{UserID: principal.UserID, Kind: kind, Reason: "only allowed for trusted services"}
+	}
+	return nil
+} //authenticateService()
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// enqueueDoc places a submitted doc in the first queue of its campaign
+func enqueueDoc(doc *forms.Doc) error {
+	if doc.CampaignID == "" {
+		return nil
+	}
+	campaign, err := loadCampaign(doc.CampaignID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load campaign(%s)", doc.CampaignID)
+	}
+	if q := campaign.FirstQueue(); q != "" {
+		return doc.Move(q, doc.UserID, doc.Rev, doc.Timestamp, "")
+	}
+	return nil
+} //enqueueDoc()
+
+func listQueue(ctx context.Context, req formsinterface.ListQueueRequest) (*formsinterface.ListQueueResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	campaign, err := loadCampaign(req.CampaignID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load campaign(%s)", req.CampaignID)
+	}
+	if err := campaignAccess(campaign, req.Principal.UserID, forms.CampaignRoleViewer); err != nil {
+		return nil, err
+	}
+	ids, pageInfo, err := storeIndex.find(docsKind, func(e indexEntry) bool {
+		return e.CampaignID == req.CampaignID && e.Queue == req.Queue
+	}, req.Page)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list queue(%s)", req.Queue)
+	}
+	res := &formsinterface.ListQueueResponse{
+		Docs:     []forms.Doc{},
+		PageInfo: pageInfo,
+	}
+	for _, id := range ids {
+		d, err := loadDoc(id, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load doc(%s)", id)
+		}
+		res.Docs = append(res.Docs, d)
+	}
+	return res, nil
+} //listQueue()
+
+// moveDoc moves a doc to another queue of its campaign and saves it as a new
+// revision with the move appended to its audit trail
+func moveDoc(ctx context.Context, req formsinterface.MoveDocRequest) (*formsinterface.MoveDocResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	unlock := writeLocks.lock(docsKind, req.ID)
+	defer unlock()
+	doc, err := loadDoc(req.ID, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load existing doc")
+	}
+	if doc.Rev != req.ExpectedRev {
+		return nil, formsinterface.ConflictError{Kind: "doc", ID: req.ID, ExpectedRev: req.ExpectedRev, LatestRev: doc.Rev}
+	}
+	if doc.CampaignID == "" {
+		return nil, errors.Errorf("doc(%s) was not submitted in a campaign", doc.ID)
+	}
+	campaign, err := loadCampaign(doc.CampaignID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load campaign(%s)", doc.CampaignID)
+	}
+	if err := campaignAccess(campaign, req.Principal.UserID, forms.CampaignRoleReviewer); err != nil {
+		return nil, err
+	}
+	if _, ok := campaign.FindQueue(req.Queue); !ok {
+		return nil, errors.Errorf("campaign(%s) has no queue(%s)", campaign.ID, req.Queue)
+	}
+	doc.Rev++
+	doc.Timestamp = time.Now()
+	doc.UpdatedBy = req.Principal.UserID
+	if err := doc.Move(req.Queue, req.Principal.UserID, doc.Rev, doc.Timestamp, req.Comment); err != nil {
+		return nil, err
+	}
+	if err := saveDoc(doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to save doc")
+	}
+	storeIndex.set(docsKind, docIndexEntry(doc, storeIndex.created(docsKind, doc.ID, doc.Timestamp)))
+	notifyDoc(doc)
+	return &formsinterface.MoveDocResponse{
+		Doc: doc,
+	}, nil
+} //moveDoc()
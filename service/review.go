@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+// reviewDoc changes the state of a submitted doc in the review workflow and
+// saves it as a new revision with the transition appended to its history
+func reviewDoc(ctx context.Context, req formsinterface.ReviewDocRequest) (*formsinterface.ReviewDocResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	unlock := writeLocks.lock(docsKind, req.ID)
+	defer unlock()
+	doc, err := loadDoc(req.ID, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load existing doc")
+	}
+	if doc.Rev != req.ExpectedRev {
+		return nil, formsinterface.ConflictError{Kind: "doc", ID: req.ID, ExpectedRev: req.ExpectedRev, LatestRev: doc.Rev}
+	}
+	if doc.CampaignID == "" {
+		return nil, errors.Errorf("doc(%s) was not submitted in a campaign", doc.ID)
+	}
+	campaign, err := loadCampaign(doc.CampaignID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load campaign(%s)", doc.CampaignID)
+	}
+	if err := campaignAccess(campaign, req.Principal.UserID, forms.CampaignRoleReviewer); err != nil {
+		return nil, err
+	}
+	if len(req.Comments) > 0 {
+		form, err := loadForm(doc.FormID, doc.FormRev)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load form(%s).rev(%d)", doc.FormID, doc.FormRev)
+		}
+		if err := form.ValidateComments(req.Comments); err != nil {
+			return nil, err
+		}
+	}
+	doc.Rev++
+	doc.Timestamp = time.Now()
+	doc.UpdatedBy = req.Principal.UserID
+	if err := doc.Transition(req.State, req.Principal.UserID, doc.Rev, doc.Timestamp, req.Comments); err != nil {
+		return nil, err
+	}
+	if err := saveDoc(doc); err != nil {
+		return nil, errors.Wrapf(err, "failed to save doc")
+	}
+	storeIndex.set(docsKind, docIndexEntry(doc, storeIndex.created(docsKind, doc.ID, doc.Timestamp)))
+	notifyDoc(doc)
+	return &formsinterface.ReviewDocResponse{
+		Doc: doc,
+	}, nil
+} //reviewDoc()
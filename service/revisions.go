@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+func listDocRevisions(ctx context.Context, req formsinterface.ListDocRevisionsRequest) (*formsinterface.ListDocRevisionsResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	latest, err := loadDoc(req.ID, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load doc(%s)", req.ID)
+	}
+	if err := docAccess(latest, req.Principal.UserID, forms.CampaignRoleViewer); err != nil {
+		return nil, err
+	}
+	revs, err := store.Revs(docsKind, req.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list doc(%s) revisions", req.ID)
+	}
+	res := &formsinterface.ListDocRevisionsResponse{
+		Revisions: []formsinterface.Revision{},
+	}
+	for _, rev := range revs {
+		doc, err := loadDoc(req.ID, rev)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load doc(%s)", req.ID)
+		}
+		res.Revisions = append(res.Revisions, docRevision(doc))
+	}
+	return res, nil
+} //listDocRevisions()
+
+func diffDoc(ctx context.Context, req formsinterface.DiffDocRequest) (*formsinterface.DiffDocResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	to, err := loadDoc(req.ID, req.ToRev)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load doc(%s)", req.ID)
+	}
+	if err := docAccess(to, req.Principal.UserID, forms.CampaignRoleViewer); err != nil {
+		return nil, err
+	}
+	fromRev, err := diffFromRev(req.FromRev, to.Rev)
+	if err != nil {
+		return nil, err
+	}
+	from, err := loadDoc(req.ID, fromRev)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load doc(%s)", req.ID)
+	}
+	changes, err := forms.DiffData(from.Data, to.Data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compare doc(%s).rev(%d) with rev(%d)", req.ID, from.Rev, to.Rev)
+	}
+	return &formsinterface.DiffDocResponse{
+		From:    docRevision(from),
+		To:      docRevision(to),
+		Changes: changes,
+	}, nil
+} //diffDoc()
+
+func listFormRevisions(ctx context.Context, req formsinterface.ListFormRevisionsRequest) (*formsinterface.ListFormRevisionsResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	latest, err := loadForm(req.ID, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load form(%s)", req.ID)
+	}
+	if err := formOwner(latest, req.Principal.UserID); err != nil {
+		return nil, err
+	}
+	revs, err := store.Revs(formsKind, req.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list form(%s) revisions", req.ID)
+	}
+	res := &formsinterface.ListFormRevisionsResponse{
+		Revisions: []formsinterface.Revision{},
+	}
+	for _, rev := range revs {
+		form, err := loadForm(req.ID, rev)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load form(%s)", req.ID)
+		}
+		res.Revisions = append(res.Revisions, formRevision(form))
+	}
+	return res, nil
+} //listFormRevisions()
+
+func diffForm(ctx context.Context, req formsinterface.DiffFormRequest) (*formsinterface.DiffFormResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	to, err := loadForm(req.ID, req.ToRev)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load form(%s)", req.ID)
+	}
+	if err := formOwner(to, req.Principal.UserID); err != nil {
+		return nil, err
+	}
+	fromRev, err := diffFromRev(req.FromRev, to.Rev)
+	if err != nil {
+		return nil, err
+	}
+	from, err := loadForm(req.ID, fromRev)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load form(%s)", req.ID)
+	}
+	changes, err := forms.DiffForms(from, to)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compare form(%s).rev(%d) with rev(%d)", req.ID, from.Rev, to.Rev)
+	}
+	return &formsinterface.DiffFormResponse{
+		From:    formRevision(from),
+		To:      formRevision(to),
+		Changes: changes,
+	}, nil
+} //diffForm()
+
+// diffFromRev returns the revision to compare with toRev, which is the one
+// before it when not specified
+func diffFromRev(fromRev int, toRev int) (int, error) {
+	if fromRev == 0 {
+		fromRev = toRev - 1
+	}
+	if fromRev < 1 || fromRev >= toRev {
+		return 0, errors.Errorf("no revision before rev(%d) to compare with", toRev)
+	}
+	return fromRev, nil
+} //diffFromRev()
+
+func docRevision(doc forms.Doc) formsinterface.Revision {
+	updatedBy := doc.UpdatedBy
+	if updatedBy == "" {
+		updatedBy = doc.UserID //revisions saved before updated_by was recorded
+	}
+	return formsinterface.Revision{
+		Rev:       doc.Rev,
+		Timestamp: doc.Timestamp,
+		UpdatedBy: updatedBy,
+	}
+} //docRevision()
+
+func formRevision(form forms.Form) formsinterface.Revision {
+	updatedBy := form.UpdatedBy
+	if updatedBy == "" {
+		updatedBy = form.UserID //revisions saved before updated_by was recorded
+	}
+	return formsinterface.Revision{
+		Rev:       form.Rev,
+		Timestamp: form.Timestamp,
+		UpdatedBy: updatedBy,
+	}
+} //formRevision()
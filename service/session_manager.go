@@ -0,0 +1,479 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/google/uuid"
+)
+
+// sessionManager keeps the sessions and devices in the store. Operations may
+// be called concurrently, so every method holds the lock for its whole
+// read-modify-write and only returns copies, never values that another
+// request can change. Methods starting with load/save/delete expect the lock
+// to be held by the caller.
+type sessionManager struct {
+	mutex  sync.Mutex
+	store  Store
+	config sessionsConfig
+}
+
+func newSessionManager(s Store, c sessionsConfig) *sessionManager {
+	return &sessionManager{
+		store:  s,
+		config: c,
+	}
+} //newSessionManager()
+
+func (m *sessionManager) add(session forms.Session) (forms.Session, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	//only login() authenticates a session
+	session.ID = uuid.New().String()
+	session.Authenticated = false
+	session.Email = ""
+	session.OtpHash = ""
+	session.OtpExpiry = nil
+	session.TimeCreated = time.Now()
+	session.TimeUpdated = session.TimeCreated
+	if err := m.saveSession(&session); err != nil {
+		return forms.Session{}, err
+	}
+	return copySession(session), nil
+} //sessionManager.add()
+
+// get returns the session of the device, creating the device if not found or
+// expired, and a new unauthenticated session if the device has none
+func (m *sessionManager) get(deviceID string) (forms.Session, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+
+	device, err := m.loadDevice(deviceID, now)
+	if err != nil {
+		return forms.Session{}, err
+	}
+	if device == nil {
+		device = &forms.Device{
+			ID:          deviceID,
+			TimeCreated: now,
+			Name:        "My Device",
+			SessionID:   "",
+		}
+		log.Debugf("device(%s) is NEW", device.ID)
+	}
+	device.TimeLast = now
+
+	var session *forms.Session
+	if device.SessionID != "" {
+		if session, err = m.loadSession(device.SessionID, now); err != nil {
+			return forms.Session{}, err
+		}
+		if session == nil {
+			device.SessionID = ""
+		}
+	}
+	if device.SessionID == "" {
+		//create a new unauthenticated blank session
+		session = &forms.Session{
+			ID:            uuid.New().String(),
+			Authenticated: false,
+			Email:         "",
+			TimeCreated:   now,
+			TimeUpdated:   now,
+			Data:          map[string]interface{}{},
+		}
+		if err := m.saveSession(session); err != nil {
+			return forms.Session{}, err
+		}
+		device.SessionID = session.ID
+		log.Debugf("device(%s).session(%s) created", device.ID, device.SessionID)
+	} else {
+		log.Debugf("device(%s).session(%s) existed", device.ID, device.SessionID)
+	}
+	if err := m.saveDevice(device); err != nil {
+		return forms.Session{}, err
+	}
+	return copySession(*session), nil
+} //sessionManager.get()
+
+// update merges the session data into the session of the device, and logs the
+// user out of the authenticated session on all devices when logout is true.
+// The authenticated flag and email cannot be changed, see login(). It returns
+// nil after logout.
+func (m *sessionManager) update(deviceID string, session forms.Session, logout bool) (*forms.Session, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+
+	device, existingSession, err := m.loadDeviceSession(deviceID, now)
+	if err != nil {
+		return nil, err
+	}
+	if device.SessionID != session.ID {
+		return nil, errors.Errorf("device.id(%s) cannot update session.id(%s)", deviceID, session.ID)
+	}
+	device.TimeLast = now
+
+	if logout && existingSession.Authenticated {
+		//user is logging out - cleanup - all other devices will be logged out too
+		log.Debugf("device(%s) logged out and ended session(%s) for email(%s)", device.ID, existingSession.ID, existingSession.Email)
+		if err := m.deleteSession(existingSession.ID); err != nil {
+			return nil, err
+		}
+		device.SessionID = ""
+		if err := m.saveDevice(device); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	} //if logout
+
+	//update session data
+	if existingSession.Data == nil {
+		existingSession.Data = map[string]interface{}{}
+	}
+	for n, v := range session.Data {
+		existingSession.Data[n] = v
+	}
+	existingSession.TimeUpdated = now
+	if err := m.saveSession(existingSession); err != nil {
+		return nil, err
+	}
+	if err := m.saveDevice(device); err != nil {
+		return nil, err
+	}
+	updatedSession := copySession(*existingSession)
+	return &updatedSession, nil
+} //sessionManager.update()
+
+// setOtp keeps the hash of the OTP sent to the email in the session of the
+// device until it is verified with login()
+func (m *sessionManager) setOtp(deviceID string, email string, hash string, expiry time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	device, session, err := m.loadDeviceSession(deviceID, now)
+	if err != nil {
+		return err
+	}
+	if session.Authenticated {
+		return errors.Errorf("device(%s) is already logged in as %s", deviceID, session.Email)
+	}
+	session.Email = email
+	session.OtpHash = hash
+	session.OtpExpiry = &expiry
+	session.TimeUpdated = now
+	if err := m.saveSession(session); err != nil {
+		return err
+	}
+	device.TimeLast = now
+	if err := m.saveDevice(device); err != nil {
+		return err
+	}
+	log.Debugf("device(%s).session(%s) OTP set for %s", deviceID, session.ID, email)
+	return nil
+} //sessionManager.setOtp()
+
+// otpEmail returns the email where the OTP of the device was sent, or "" when
+// no OTP was sent or it expired
+func (m *sessionManager) otpEmail(deviceID string, now time.Time) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, session, err := m.loadDeviceSession(deviceID, now)
+	if err != nil {
+		return "", err
+	}
+	if session.Authenticated || session.OtpHash == "" || session.OtpExpiry == nil || session.OtpExpiry.Before(now) {
+		return "", nil
+	}
+	return session.Email, nil
+} //sessionManager.otpEmail()
+
+// clearOtp removes the OTP from the session of the device so it cannot be
+// used again
+func (m *sessionManager) clearOtp(deviceID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, session, err := m.loadDeviceSession(deviceID, time.Now())
+	if err != nil {
+		return err
+	}
+	session.OtpHash = ""
+	session.OtpExpiry = nil
+	return m.saveSession(session)
+} //sessionManager.clearOtp()
+
+// login authenticates the device when the OTP matches the one sent to the
+// email. If the email already has an authenticated session, the device joins
+// it, else the session of the device becomes authenticated. It returns nil
+// when the OTP is wrong.
+func (m *sessionManager) login(deviceID string, email string, otp string, now time.Time) (*forms.Session, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	device, session, err := m.loadDeviceSession(deviceID, now)
+	if err != nil {
+		return nil, err
+	}
+	if session.Authenticated || session.Email != email || session.OtpExpiry == nil || session.OtpExpiry.Before(now) {
+		return nil, errors.Errorf("device(%s) OTP for %s is no longer valid", deviceID, email)
+	}
+	if !otpMatch(otp, session.OtpHash) {
+		return nil, nil
+	}
+	session.OtpHash = ""
+	session.OtpExpiry = nil
+	device.TimeLast = now
+
+	authenticatedSession, err := m.loadSessionByEmail(email, now)
+	if err != nil {
+		return nil, err
+	}
+	if authenticatedSession != nil {
+		//switch to it with the data of the temp session and delete the temp session
+		if err := m.deleteSession(session.ID); err != nil {
+			return nil, err
+		}
+		if authenticatedSession.Data == nil {
+			authenticatedSession.Data = map[string]interface{}{}
+		}
+		for n, v := range session.Data {
+			authenticatedSession.Data[n] = v
+		}
+		session = authenticatedSession
+		log.Debugf("device(%s) authenticated and joins session(%s) for email(%s)", device.ID, session.ID, session.Email)
+	} else {
+		session.Authenticated = true
+		log.Debugf("device(%s) authenticated and new session(%s) for email(%s)", device.ID, session.ID, session.Email)
+	}
+	device.SessionID = session.ID
+	session.TimeUpdated = now
+	if err := m.saveSession(session); err != nil {
+		return nil, err
+	}
+	if err := m.saveDevice(device); err != nil {
+		return nil, err
+	}
+	loggedIn := copySession(*session)
+	return &loggedIn, nil
+} //sessionManager.login()
+
+func (m *sessionManager) del(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.deleteSession(id)
+} //sessionManager.del()
+
+// authenticatedEmail returns the email of the session, or "" when the session
+// does not exist, expired or is not authenticated
+func (m *sessionManager) authenticatedEmail(id string) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	session, err := m.loadSession(id, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if session == nil || !session.Authenticated {
+		return "", nil
+	}
+	return session.Email, nil
+} //sessionManager.authenticatedEmail()
+
+// devices returns the devices logged in to the authenticated session of the
+// user, the most recently used first
+func (m *sessionManager) devices(email string) ([]forms.Device, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	devices := []forms.Device{}
+	session, err := m.loadSessionByEmail(email, now)
+	if err != nil || session == nil {
+		return devices, err
+	}
+	for _, e := range storeIndex.list(devicesKind, func(e indexEntry) bool { return e.SessionID == session.ID }) {
+		device, err := m.loadDevice(e.ID, now)
+		if err != nil {
+			return nil, err
+		}
+		if device != nil {
+			devices = append(devices, *device)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].TimeLast.After(devices[j].TimeLast) })
+	return devices, nil
+} //sessionManager.devices()
+
+func (m *sessionManager) renameDevice(email string, deviceID string, name string) (forms.Device, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	device, err := m.loadUserDevice(email, deviceID, time.Now())
+	if err != nil {
+		return forms.Device{}, err
+	}
+	device.Name = strings.TrimSpace(name)
+	if err := m.saveDevice(device); err != nil {
+		return forms.Device{}, err
+	}
+	return *device, nil
+} //sessionManager.renameDevice()
+
+// revokeDevice deletes the device, so it gets a new unauthenticated session on
+// its next request and has to login again
+func (m *sessionManager) revokeDevice(email string, deviceID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, err := m.loadUserDevice(email, deviceID, time.Now()); err != nil {
+		return err
+	}
+	if err := m.store.Delete(devicesKind, deviceID); err != nil {
+		return errors.Wrapf(err, "failed to delete device(%s)", deviceID)
+	}
+	storeIndex.del(devicesKind, deviceID)
+	log.Debugf("device(%s) of %s revoked", deviceID, email)
+	return nil
+} //sessionManager.revokeDevice()
+
+// sweep is the janitor that deletes expired sessions and devices
+func (m *sessionManager) sweep() {
+	for {
+		now := time.Now()
+		for _, kind := range []string{sessionsKind, devicesKind} {
+			for _, e := range storeIndex.list(kind, func(e indexEntry) bool { return e.Expires.Before(now) }) {
+				if err := m.delExpired(kind, e.ID, now); err != nil {
+					log.Errorf("failed to delete expired %s(%s): %+v", kind, e.ID, err)
+				}
+			}
+		}
+		time.Sleep(m.config.sweepInterval)
+	}
+} //sessionManager.sweep()
+
+func (m *sessionManager) delExpired(kind string, id string, now time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	//check again in case it was used since it was listed
+	if e, ok := storeIndex.get(kind, id); !ok || e.Expires.After(now) {
+		return nil
+	}
+	if err := m.store.Delete(kind, id); err != nil {
+		return errors.Wrapf(err, "failed to remove %s(%s)", kind, id)
+	}
+	storeIndex.del(kind, id)
+	log.Debugf("deleted %s(%s) that expired", kind, id)
+	return nil
+} //sessionManager.delExpired()
+
+// loadSession returns nil when the session does not exist or expired
+func (m *sessionManager) loadSession(id string, now time.Time) (*forms.Session, error) {
+	if e, ok := storeIndex.get(sessionsKind, id); !ok || e.Expires.Before(now) {
+		return nil, nil
+	}
+	var s forms.Session
+	if err := m.store.Load(sessionsKind, id, 0, &s); err != nil {
+		return nil, errors.Wrapf(err, "failed to load session(%s)", id)
+	}
+	return &s, nil
+} //sessionManager.loadSession()
+
+// loadSessionByEmail returns the authenticated session of the user, nil if
+// the user has no session that did not expire
+func (m *sessionManager) loadSessionByEmail(email string, now time.Time) (*forms.Session, error) {
+	entries := storeIndex.list(sessionsKind, func(e indexEntry) bool { return e.UserID == email && e.Expires.After(now) })
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return m.loadSession(entries[0].ID, now)
+} //sessionManager.loadSessionByEmail()
+
+// saveSession extends the expiry of the session and saves it
+func (m *sessionManager) saveSession(s *forms.Session) error {
+	s.TimeExpires = m.config.expires(s.TimeCreated, s.TimeUpdated)
+	if err := m.store.Save(sessionsKind, s.ID, 0, s); err != nil {
+		return errors.Wrapf(err, "failed to save session(%s)", s.ID)
+	}
+	storeIndex.set(sessionsKind, sessionIndexEntry(*s))
+	return nil
+} //sessionManager.saveSession()
+
+func (m *sessionManager) deleteSession(id string) error {
+	if err := m.store.Delete(sessionsKind, id); err != nil {
+		return errors.Wrapf(err, "failed to delete session(%s)", id)
+	}
+	storeIndex.del(sessionsKind, id)
+	return nil
+} //sessionManager.deleteSession()
+
+// loadDevice returns nil when the device does not exist or expired
+func (m *sessionManager) loadDevice(id string, now time.Time) (*forms.Device, error) {
+	if e, ok := storeIndex.get(devicesKind, id); !ok || e.Expires.Before(now) {
+		return nil, nil
+	}
+	var d forms.Device
+	if err := m.store.Load(devicesKind, id, 0, &d); err != nil {
+		return nil, errors.Wrapf(err, "failed to load device(%s)", id)
+	}
+	return &d, nil
+} //sessionManager.loadDevice()
+
+// loadDeviceSession returns the device and its session, which must exist
+func (m *sessionManager) loadDeviceSession(deviceID string, now time.Time) (*forms.Device, *forms.Session, error) {
+	device, err := m.loadDevice(deviceID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if device == nil {
+		return nil, nil, errors.Errorf("device.id not found")
+	}
+	session, err := m.loadSession(device.SessionID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil {
+		return nil, nil, errors.Errorf("session not found")
+	}
+	return device, session, nil
+} //sessionManager.loadDeviceSession()
+
+// loadUserDevice returns the device if it is logged in to the authenticated
+// session of the user
+func (m *sessionManager) loadUserDevice(email string, deviceID string, now time.Time) (*forms.Device, error) {
+	session, err := m.loadSessionByEmail(email, now)
+	if err != nil {
+		return nil, err
+	}
+	device, err := m.loadDevice(deviceID, now)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || device == nil || device.SessionID != session.ID {
+		return nil, formsinterface.PermissionDeniedError{UserID: email, Kind: devicesKind, ID: deviceID, Reason: "device is not logged in as the user"}
+	}
+	return device, nil
+} //sessionManager.loadUserDevice()
+
+// saveDevice extends the expiry of the device and saves it
+func (m *sessionManager) saveDevice(d *forms.Device) error {
+	d.TimeExpires = m.config.expires(d.TimeCreated, d.TimeLast)
+	if err := m.store.Save(devicesKind, d.ID, 0, d); err != nil {
+		return errors.Wrapf(err, "failed to save device(%s)", d.ID)
+	}
+	storeIndex.set(devicesKind, deviceIndexEntry(*d))
+	return nil
+} //sessionManager.saveDevice()
+
+// copySession returns a session with its own data map, so that the caller
+// can change it without changing the value kept by the manager, and without
+// the OTP hash that must not leave the service
+func copySession(s forms.Session) forms.Session {
+	s.OtpHash = ""
+	data := make(map[string]interface{}, len(s.Data))
+	for n, v := range s.Data {
+		data[n] = v
+	}
+	s.Data = data
+	return s
+} //copySession()
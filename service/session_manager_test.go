@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSessionManager returns a session manager with an empty memory store
+// and index
+func newTestSessionManager(t *testing.T) *sessionManager {
+	t.Helper()
+	s, err := memoryStoreConfig{}.Create()
+	if err != nil {
+		t.Fatalf("failed to create memory store: %+v", err)
+	}
+	c := sessionsConfig{IdleTTL: "1h", MaxTTL: "2h", SweepInterval: "1h"}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("invalid sessions config: %+v", err)
+	}
+	storeIndex.reset()
+	return newSessionManager(s, c)
+} //newTestSessionManager()
+
+// testLogin sends an OTP to the email and logs the device in with it, unless
+// the device is already logged in
+func testLogin(m *sessionManager, deviceID string, email string) error {
+	session, err := m.get(deviceID)
+	if err != nil {
+		return err
+	}
+	if session.Authenticated && session.Email == email {
+		return nil
+	}
+	otp := "ABCDEF"
+	if err := m.setOtp(deviceID, email, otpHash(otp), time.Now().Add(time.Minute)); err != nil {
+		return err
+	}
+	loggedIn, err := m.login(deviceID, email, otp, time.Now())
+	if err != nil {
+		return err
+	}
+	if loggedIn == nil {
+		return fmt.Errorf("device(%s) OTP did not match", deviceID)
+	}
+	return nil
+} //testLogin()
+
+// testLogout ends the session of the device if it is authenticated
+func testLogout(m *sessionManager, deviceID string) error {
+	session, err := m.get(deviceID)
+	if err != nil {
+		return err
+	}
+	_, err = m.update(deviceID, session, true)
+	return err
+} //testLogout()
+
+func TestSessionManagerLoginJoinLogout(t *testing.T) {
+	m := newTestSessionManager(t)
+	if err := testLogin(m, "d1", "a@example.com"); err != nil {
+		t.Fatalf("d1 login failed: %+v", err)
+	}
+	if err := testLogin(m, "d2", "a@example.com"); err != nil {
+		t.Fatalf("d2 login failed: %+v", err)
+	}
+	s1, err := m.get("d1")
+	if err != nil {
+		t.Fatalf("get d1 failed: %+v", err)
+	}
+	s2, err := m.get("d2")
+	if err != nil {
+		t.Fatalf("get d2 failed: %+v", err)
+	}
+	if !s1.Authenticated || s1.ID != s2.ID {
+		t.Fatalf("d2 did not join the session of d1: %+v %+v", s1, s2)
+	}
+	if s1.OtpHash != "" {
+		t.Fatalf("session returned the OTP hash")
+	}
+
+	//returned sessions are copies
+	s1.Data["x"] = 1
+	if s, _ := m.get("d1"); s.Data["x"] != nil {
+		t.Fatalf("changed session data without update")
+	}
+
+	if err := testLogout(m, "d2"); err != nil {
+		t.Fatalf("logout failed: %+v", err)
+	}
+	if s, _ := m.get("d1"); s.Authenticated {
+		t.Fatalf("d1 still logged in after d2 logged out")
+	}
+} //TestSessionManagerLoginJoinLogout()
+
+func TestSessionManagerUpdateCannotAuthenticate(t *testing.T) {
+	m := newTestSessionManager(t)
+	session, err := m.get("d1")
+	if err != nil {
+		t.Fatalf("get failed: %+v", err)
+	}
+	session.Authenticated = true
+	session.Email = "a@example.com"
+	updated, err := m.update("d1", session, false)
+	if err != nil {
+		t.Fatalf("update failed: %+v", err)
+	}
+	if updated.Authenticated || updated.Email != "" {
+		t.Fatalf("update authenticated the session: %+v", updated)
+	}
+	if email, _ := m.authenticatedEmail(session.ID); email != "" {
+		t.Fatalf("session authenticated as %s", email)
+	}
+} //TestSessionManagerUpdateCannotAuthenticate()
+
+func TestSessionManagerWrongOtp(t *testing.T) {
+	m := newTestSessionManager(t)
+	if _, err := m.get("d1"); err != nil {
+		t.Fatalf("get failed: %+v", err)
+	}
+	if err := m.setOtp("d1", "a@example.com", otpHash("ABCDEF"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("setOtp failed: %+v", err)
+	}
+	session, err := m.login("d1", "a@example.com", "ABCDEG", time.Now())
+	if err != nil || session != nil {
+		t.Fatalf("wrong OTP logged in: %+v %+v", session, err)
+	}
+	if _, err := m.login("d1", "a@example.com", "ABCDEF", time.Now().Add(2*time.Minute)); err == nil {
+		t.Fatalf("expired OTP logged in")
+	}
+} //TestSessionManagerWrongOtp()
+
+// TestSessionManagerConcurrent races get, login, logout and join on the same
+// devices and session, run with -race to detect unguarded access
+func TestSessionManagerConcurrent(t *testing.T) {
+	m := newTestSessionManager(t)
+	email := "a@example.com"
+	devices := []string{"d1", "d2"}
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				deviceID := devices[(w+i)%len(devices)]
+				//errors are expected when another goroutine changed the session
+				//in between, e.g. the OTP is no longer valid after a login
+				switch (w + i) % 4 {
+				case 0:
+					m.get(deviceID)
+				case 1:
+					testLogin(m, deviceID, email)
+				case 2:
+					testLogout(m, deviceID)
+				case 3:
+					if session, err := m.get(deviceID); err == nil {
+						session.Data[fmt.Sprintf("w%d", w)] = i
+						m.update(deviceID, session, false)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	//there is only one authenticated session for the email, which every
+	//authenticated device uses
+	authenticated := storeIndex.list(sessionsKind, func(e indexEntry) bool { return e.UserID == email })
+	if len(authenticated) > 1 {
+		t.Fatalf("%d authenticated sessions for %s", len(authenticated), email)
+	}
+	for _, deviceID := range devices {
+		session, err := m.get(deviceID)
+		if err != nil {
+			t.Fatalf("get %s failed: %+v", deviceID, err)
+		}
+		if session.Authenticated && (len(authenticated) != 1 || session.ID != authenticated[0].ID) {
+			t.Fatalf("device(%s) is authenticated in session(%s) that is not the session of the email", deviceID, session.ID)
+		}
+	}
+
+	//the devices can still login and join after the race
+	for _, deviceID := range devices {
+		if err := testLogin(m, deviceID, email); err != nil {
+			t.Fatalf("%s login failed: %+v", deviceID, err)
+		}
+	}
+	s1, _ := m.get(devices[0])
+	s2, _ := m.get(devices[1])
+	if !s1.Authenticated || s1.ID != s2.ID {
+		t.Fatalf("devices not in the same session after login: %+v %+v", s1, s2)
+	}
+} //TestSessionManagerConcurrent()
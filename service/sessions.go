@@ -2,155 +2,152 @@ package main
 
 import (
 	"context"
-	"sync"
 	"time"
 
+	"github.com/go-msvc/config"
 	"github.com/go-msvc/errors"
-	"github.com/go-msvc/forms"
 	"github.com/go-msvc/forms/service/formsinterface"
 	"github.com/go-msvc/logger"
-	"github.com/google/uuid"
 )
 
-//todo: cleanup expired sessions
-//todo: cleanup expired devices and ask new login when used again...
-//todo: store centrally - like everything else :-) currently in memory
+func init() {
+	config.MustConfigure("sessions", sessionsConfig{IdleTTL: "168h", MaxTTL: "720h", SweepInterval: "1h"})
+}
+
+// sessionsConfig controls when sessions and devices expire, after which the
+// user has to login again, e.g. {"sessions":{"idle_ttl":"168h","max_ttl":"720h","sweep_interval":"1h"}}
+type sessionsConfig struct {
+	IdleTTL       string `json:"idle_ttl" doc:"Sessions and devices expire when not used for this long, e.g. \"168h\" for 7 days"`
+	MaxTTL        string `json:"max_ttl" doc:"Sessions and devices expire this long after they were created, even when used, e.g. \"720h\" for 30 days"`
+	SweepInterval string `json:"sweep_interval" doc:"How often expired sessions and devices are deleted, e.g. \"1h\""`
+
+	idleTTL       time.Duration
+	maxTTL        time.Duration
+	sweepInterval time.Duration
+}
+
+func (c *sessionsConfig) Validate() error {
+	var err error
+	if c.idleTTL, err = time.ParseDuration(c.IdleTTL); err != nil || c.idleTTL <= 0 {
+		return errors.Errorf("invalid idle_ttl:\"%s\", expecting a positive duration like \"168h\"", c.IdleTTL)
+	}
+	if c.maxTTL, err = time.ParseDuration(c.MaxTTL); err != nil || c.maxTTL < c.idleTTL {
+		return errors.Errorf("invalid max_ttl:\"%s\", expecting a duration of at least idle_ttl like \"720h\"", c.MaxTTL)
+	}
+	if c.sweepInterval, err = time.ParseDuration(c.SweepInterval); err != nil || c.sweepInterval <= 0 {
+		return errors.Errorf("invalid sweep_interval:\"%s\", expecting a positive duration like \"1h\"", c.SweepInterval)
+	}
+	return nil
+}
+
+// expires returns when an item created and last used at the specified times
+// expires, i.e. after the idle time but not later than the max time
+func (c sessionsConfig) expires(created time.Time, used time.Time) time.Time {
+	t := used.Add(c.idleTTL)
+	if max := created.Add(c.maxTTL); max.Before(t) {
+		return max
+	}
+	return t
+} //sessionsConfig.expires()
 
 var (
-	log            = logger.New().WithLevel(logger.LevelDebug)
-	sessionsMutex  sync.Mutex
-	deviceByID     = map[string]*forms.Device{}
-	sessionByEmail = map[string]*forms.Session{}
-	sessionByID    = map[string]*forms.Session{}
+	log = logger.New().WithLevel(logger.LevelDebug)
+
+	// sessions is created from config in main()
+	sessions *sessionManager
 )
 
 func addSession(ctx context.Context, req formsinterface.AddSessionRequest) (*formsinterface.AddSessionResponse, error) {
+	if err := authenticateService(&req.Principal, sessionsKind); err != nil {
+		return nil, err
+	}
 	if req.Session.ID != "" {
 		return nil, errors.Errorf("session.id=%s may not be specified when adding a session", req.Session.ID)
 	}
-	req.Session.ID = uuid.New().String()
-	req.Session.TimeCreated = time.Now()
-	req.Session.TimeUpdated = time.Now()
-	sessionsMutex.Lock()
-	defer sessionsMutex.Unlock()
-	sessionByID[req.Session.ID] = &req.Session
+	session, err := sessions.add(req.Session)
+	if err != nil {
+		return nil, err
+	}
 	return &formsinterface.AddSessionResponse{
-		Session: req.Session,
+		Session: session,
 	}, nil
 } //addSession()
 
 func getSession(ctx context.Context, req formsinterface.GetSessionRequest) (*formsinterface.GetSessionResponse, error) {
-	//device is created if not found
-	device, ok := deviceByID[req.DeviceID]
-	if !ok {
-		device = &forms.Device{
-			ID:          req.DeviceID,
-			TimeCreated: time.Now(),
-			Name:        "My Device",
-			SessionID:   "",
-		}
-		deviceByID[req.DeviceID] = device
-		log.Debugf("device(%s) is NEW", device.ID)
-	}
-	device.TimeLast = time.Now()
-
-	var session *forms.Session
-	if device.SessionID != "" {
-		session, ok = sessionByID[device.SessionID]
-		if !ok {
-			device.SessionID = ""
-		}
-	}
-	if device.SessionID == "" {
-		//create a new unauthenticated blank session
-		session = &forms.Session{
-			ID:            uuid.New().String(),
-			Authenticated: false,
-			Email:         "",
-			TimeCreated:   time.Now(),
-			TimeUpdated:   time.Now(),
-			Data:          map[string]interface{}{},
-		}
-		sessionByID[session.ID] = session
-		device.SessionID = session.ID
-		log.Debugf("device(%s).session(%s) created", device.ID, device.SessionID)
-	} else {
-		log.Debugf("device(%s).session(%s) existed", device.ID, device.SessionID)
+	if err := authenticateService(&req.Principal, sessionsKind); err != nil {
+		return nil, err
+	}
+	session, err := sessions.get(req.DeviceID)
+	if err != nil {
+		return nil, err
 	}
 	return &formsinterface.GetSessionResponse{
-		Session: *session,
+		Session: session,
 	}, nil
 } //getSession()
 
 func updSession(ctx context.Context, req formsinterface.UpdSessionRequest) (*formsinterface.UpdSessionResponse, error) {
+	if err := authenticateService(&req.Principal, sessionsKind); err != nil {
+		return nil, err
+	}
 	if req.DeviceID == "" {
 		return nil, errors.Errorf("device_id must be specified when updating a session")
 	}
 	if req.Session.ID == "" {
 		return nil, errors.Errorf("session.id must be specified when updating a session")
 	}
-
-	device, ok := deviceByID[req.DeviceID]
-	if !ok {
-		return nil, errors.Errorf("device.id not found")
-	}
-	if device.SessionID != req.Session.ID {
-		return nil, errors.Errorf("device.id(%s) cannot update session.id(%s)", req.DeviceID, req.Session.ID)
-	}
-	device.TimeLast = time.Now()
-
-	existingSession, ok := sessionByID[req.Session.ID]
-	if !ok {
-		return nil, errors.Errorf("session not found")
+	session, err := sessions.update(req.DeviceID, req.Session, req.Logout)
+	if err != nil {
+		return nil, err
 	}
-	if req.Session.Email != "" {
-		existingSession.Email = req.Session.Email
-	}
-
-	if existingSession.Authenticated && !req.Session.Authenticated {
-		//user is logging out - cleanup - all other devices will be logged out too
-		log.Debugf("device(%s) logged out and ended session(%s) for email(%s)", device.ID, existingSession.ID, existingSession.Email)
-		delete(sessionByEmail, existingSession.Email)
-		delete(sessionByID, device.SessionID)
-		existingSession.Authenticated = false
-		existingSession.Email = ""
-		device.SessionID = ""
+	if session == nil {
+		//logged out
 		return nil, nil
-	} //if logout
-
-	if req.Session.Email != "" && req.Session.Authenticated && !existingSession.Authenticated {
-		//logged in with a temp session
-		//if already has authenticated session for this email, switch over to that session
-		//else make this the authenticated session
-		if authenticatedSession, ok := sessionByEmail[req.Session.Email]; ok && authenticatedSession.Authenticated && authenticatedSession.Email == req.Session.Email {
-			//switch and delete the temp session
-			delete(sessionByID, req.Session.ID)
-			existingSession = authenticatedSession
-			device.SessionID = authenticatedSession.ID
-			log.Debugf("device(%s) authenticated and joins session(%s) for email(%s)", device.ID, existingSession.ID, existingSession.Email)
-		} else {
-			existingSession.Authenticated = true
-			existingSession.Email = req.Session.Email
-			sessionByEmail[req.Session.Email] = existingSession
-			log.Debugf("device(%s) authenticated and new session(%s) for email(%s)", device.ID, existingSession.ID, existingSession.Email)
-		}
-		existingSession.TimeUpdated = time.Now()
-	}
-
-	//update session data
-	for n, v := range req.Session.Data {
-		existingSession.Data[n] = v
-	}
-	existingSession.TimeUpdated = time.Now()
-
+	}
 	return &formsinterface.UpdSessionResponse{
-		Session: *existingSession,
+		Session: *session,
 	}, nil
 } //updSession()
 
 func delSession(ctx context.Context, req formsinterface.DelSessionRequest) error {
-	sessionsMutex.Lock()
-	defer sessionsMutex.Unlock()
-	delete(sessionByID, req.ID)
-	return nil
+	if err := authenticateService(&req.Principal, sessionsKind); err != nil {
+		return err
+	}
+	return sessions.del(req.ID)
 } //delSession()
+
+func listDevices(ctx context.Context, req formsinterface.ListDevicesRequest) (*formsinterface.ListDevicesResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	devices, err := sessions.devices(req.Principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &formsinterface.ListDevicesResponse{
+		Devices: devices,
+	}, nil
+} //listDevices()
+
+func renameDevice(ctx context.Context, req formsinterface.RenameDeviceRequest) (*formsinterface.RenameDeviceResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	device, err := sessions.renameDevice(req.Principal.UserID, req.DeviceID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &formsinterface.RenameDeviceResponse{
+		Device: device,
+	}, nil
+} //renameDevice()
+
+func revokeDevice(ctx context.Context, req formsinterface.RevokeDeviceRequest) (*formsinterface.RevokeDeviceResponse, error) {
+	if err := authenticate(&req.Principal); err != nil {
+		return nil, err
+	}
+	if err := sessions.revokeDevice(req.Principal.UserID, req.DeviceID); err != nil {
+		return nil, err
+	}
+	return &formsinterface.RevokeDeviceResponse{}, nil
+} //revokeDevice()
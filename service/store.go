@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+)
+
+// Store keeps the JSON encoded revisions of each kind of entity (forms, docs,
+// campaigns, ...) identified by id. Revisions are numbered 1,2,3,... and the
+// last saved revision is also kept as the latest. Entities without revisions
+// are saved with rev 0 which only updates the latest.
+//
+// Implementations are registered with config.RegisterConstructor() and the one
+// to use is selected in config.json, e.g. {"store":{"files":{"dir":"."}}}
+type Store interface {
+	// Save item as rev of kind.id and make it the latest
+	Save(kind string, id string, rev int, item interface{}) error
+	// Load kind.id.rev (use 0 for the latest) into item which must be a pointer
+	Load(kind string, id string, rev int, item interface{}) error
+	// Delete kind.id with all its revisions
+	Delete(kind string, id string) error
+	// List the ids of kind
+	List(kind string) ([]string, error)
+	// Revs lists the revisions of kind.id in ascending order
+	Revs(kind string, id string) ([]int, error)
+}
+
+const (
+	formsKind     = "forms"
+	docsKind      = "docs"
+	campaignsKind = "campaigns"
+	sessionsKind  = "sessions"
+	devicesKind   = "devices"
+	lockoutsKind  = "lockouts"
+)
+
+// store is created from config in main()
+var store Store
+
+func init() {
+	config.MustConstruct("store", reflect.TypeOf((*Store)(nil)).Elem())
+}
+
+// validID rejects ids that are not a single path element, e.g.
+// "../sessions/<id>" would access an item of another kind in the files store.
+// Every Store method checks the id with it, also where the backend does not
+// use paths, so that all backends accept the same ids.
+func validID(id string) error {
+	if id == "" || strings.ContainsAny(id, "/\\\x00") || strings.Contains(id, "..") {
+		return errors.Errorf("invalid id \"%s\"", id)
+	}
+	return nil
+} //validID()
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+func init() {
+	config.RegisterConstructor("files", filesStoreConfig{})
+}
+
+// filesStoreConfig stores each kind in its own directory <dir>/<kind> with a
+// sub directory per id that contains latest.json and rev_<n>.json files.
+// The directory of a kind can also be set in the environment, e.g. FORMS_DIR.
+type filesStoreConfig struct {
+	Dir string `json:"dir" doc:"Parent directory of the kind directories (default: current directory)"`
+}
+
+func (c filesStoreConfig) Validate() error {
+	return nil
+}
+
+func (c filesStoreConfig) Create() (Store, error) {
+	if c.Dir == "" {
+		c.Dir = "."
+	}
+	return &filesStore{
+		config: c,
+		dirs:   map[string]string{},
+	}, nil
+}
+
+type filesStore struct {
+	config    filesStoreConfig
+	dirsMutex sync.Mutex
+	dirs      map[string]string
+}
+
+// kindDir returns the directory of kind, creating it on first use
+func (s *filesStore) kindDir(kind string) (string, error) {
+	s.dirsMutex.Lock()
+	defer s.dirsMutex.Unlock()
+	if dir, ok := s.dirs[kind]; ok {
+		return dir, nil
+	}
+	dir := os.Getenv(strings.ToUpper(kind) + "_DIR")
+	if dir == "" {
+		dir = s.config.Dir + "/" + kind
+	}
+	if err := os.MkdirAll(dir, 0770); err != nil && err != os.ErrExist {
+		return "", errors.Wrapf(err, "cannot access %s dir %s", kind, dir)
+	}
+	s.dirs[kind] = dir
+	return dir, nil
+}
+
+func (s *filesStore) Save(kind string, id string, rev int, item interface{}) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	kindDir, err := s.kindDir(kind)
+	if err != nil {
+		return err
+	}
+	itemDir := kindDir + "/" + id
+	if err := os.MkdirAll(itemDir, 0770); err != nil && err != os.ErrExist {
+		return errors.Wrapf(err, "cannot make %s dir %s", kind, itemDir)
+	}
+	jsonItem, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s", kind)
+	}
+	jsonItem = append(jsonItem, '\n')
+
+	//write the revision with its checksum before the latest, so that after a
+	//crash the latest is never ahead of the revisions and fsck can repair it
+	if rev > 0 {
+		revFilename := fmt.Sprintf("%s/rev_%d.json", itemDir, rev)
+		if err := writeFileAtomic(revFilename, jsonItem); err != nil {
+			return errors.Wrapf(err, "failed to save %s", kind)
+		}
+		if err := writeFileAtomic(checksumFilename(revFilename), []byte(checksum(jsonItem))); err != nil {
+			return errors.Wrapf(err, "failed to save %s checksum", kind)
+		}
+	}
+	if err := writeFileAtomic(fmt.Sprintf("%s/latest.json", itemDir), jsonItem); err != nil {
+		return errors.Wrapf(err, "failed to save %s", kind)
+	}
+	return nil
+} //filesStore.Save()
+
+func (s *filesStore) Load(kind string, id string, rev int, item interface{}) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	kindDir, err := s.kindDir(kind)
+	if err != nil {
+		return err
+	}
+	itemDir := kindDir + "/" + id
+	var filename string
+	if rev == 0 {
+		filename = fmt.Sprintf("%s/latest.json", itemDir)
+	} else {
+		filename = fmt.Sprintf("%s/rev_%d.json", itemDir, rev)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %s", filename)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(item); err != nil {
+		return errors.Wrapf(err, "failed to load %s", filename)
+	}
+	return nil
+} //filesStore.Load()
+
+func (s *filesStore) Delete(kind string, id string) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	kindDir, err := s.kindDir(kind)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(kindDir + "/" + id); err != nil {
+		return errors.Wrapf(err, "failed to remove %s", kind)
+	}
+	return nil
+} //filesStore.Delete()
+
+func (s *filesStore) List(kind string) ([]string, error) {
+	kindDir, err := s.kindDir(kind)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(kindDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read dir %s", kindDir)
+	}
+	ids := []string{}
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, nil
+} //filesStore.List()
+
+func (s *filesStore) Revs(kind string, id string) ([]int, error) {
+	if err := validID(id); err != nil {
+		return nil, err
+	}
+	kindDir, err := s.kindDir(kind)
+	if err != nil {
+		return nil, err
+	}
+	itemDir := kindDir + "/" + id
+	entries, err := os.ReadDir(itemDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read dir %s", itemDir)
+	}
+	revs := []int{}
+	for _, e := range entries {
+		if rev, ok := revFromFilename(e.Name()); ok {
+			revs = append(revs, rev)
+		}
+	}
+	sort.Ints(revs)
+	return revs, nil
+} //filesStore.Revs()
+
+// revFromFilename returns n from "rev_<n>.json"
+func revFromFilename(filename string) (int, bool) {
+	if !strings.HasPrefix(filename, "rev_") || !strings.HasSuffix(filename, ".json") {
+		return 0, false
+	}
+	rev, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(filename, "rev_"), ".json"))
+	if err != nil || rev < 1 {
+		return 0, false
+	}
+	return rev, true
+} //revFromFilename()
+
+// writeFileAtomic writes a temp file in the same dir and renames it over
+// filename, so readers only ever see the old or the complete new content
+func writeFileAtomic(filename string, data []byte) error {
+	dir := filepath.Dir(filename)
+	f, err := os.CreateTemp(dir, tempFilePrefix+"*")
+	if err != nil {
+		return errors.Wrapf(err, "failed to create temp file in %s", dir)
+	}
+	tempFilename := f.Name()
+	defer os.Remove(tempFilename) //fails silently after rename
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to write %s", tempFilename)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "failed to sync %s", tempFilename)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %s", tempFilename)
+	}
+	if err := os.Rename(tempFilename, filename); err != nil {
+		return errors.Wrapf(err, "failed to rename %s to %s", tempFilename, filename)
+	}
+	//sync the dir so the rename survives a crash
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+} //writeFileAtomic()
+
+const tempFilePrefix = ".tmp-"
+
+// checksumFilename is "rev_<n>.sha256" for "rev_<n>.json"
+func checksumFilename(revFilename string) string {
+	return strings.TrimSuffix(revFilename, ".json") + ".sha256"
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Check implements Checker to find (and repair) problems left by a crash or
+// manual edits: temp files, revisions that fail their checksum, latest.json
+// that is not the same as the highest valid revision, undecodable JSON and
+// directories without any valid content.
+func (s *filesStore) Check(kinds []string, repair bool) ([]formsinterface.FsckProblem, error) {
+	problems := []formsinterface.FsckProblem{}
+	for _, kind := range kinds {
+		kindDir, err := s.kindDir(kind)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(kindDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read dir %s", kindDir)
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				if strings.HasPrefix(e.Name(), tempFilePrefix) {
+					p := formsinterface.FsckProblem{Kind: kind, File: kindDir + "/" + e.Name(), Problem: "temp file"}
+					p.Repaired = repair && os.Remove(p.File) == nil
+					problems = append(problems, p)
+				}
+				continue
+			}
+			problems = append(problems, s.checkItem(kind, e.Name(), repair)...)
+		}
+	}
+	return problems, nil
+} //filesStore.Check()
+
+func (s *filesStore) checkItem(kind string, id string, repair bool) []formsinterface.FsckProblem {
+	kindDir, _ := s.kindDir(kind)
+	itemDir := kindDir + "/" + id
+	problems := []formsinterface.FsckProblem{}
+	problem := func(filename string, msg string) *formsinterface.FsckProblem {
+		problems = append(problems, formsinterface.FsckProblem{Kind: kind, ID: id, File: filename, Problem: msg})
+		return &problems[len(problems)-1]
+	}
+
+	entries, err := os.ReadDir(itemDir)
+	if err != nil {
+		problem(itemDir, fmt.Sprintf("cannot read dir: %v", err))
+		return problems
+	}
+	latestFilename := itemDir + "/latest.json"
+	latest, latestErr := os.ReadFile(latestFilename)
+	if latestErr == nil && !json.Valid(latest) {
+		latestErr = errors.Errorf("undecodable JSON")
+	}
+
+	//check revisions against their checksums
+	revs := []int{}
+	revData := map[int][]byte{}
+	for _, e := range entries {
+		filename := itemDir + "/" + e.Name()
+		if strings.HasPrefix(e.Name(), tempFilePrefix) {
+			p := problem(filename, "temp file")
+			p.Repaired = repair && os.Remove(filename) == nil
+			continue
+		}
+		rev, ok := revFromFilename(e.Name())
+		if !ok {
+			continue
+		}
+		revs = append(revs, rev)
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			problem(filename, fmt.Sprintf("cannot read: %v", err))
+			continue
+		}
+		expectedSum, err := os.ReadFile(checksumFilename(filename))
+		if err != nil {
+			if !json.Valid(data) {
+				problem(filename, "undecodable JSON without checksum")
+				continue
+			}
+			p := problem(filename, "missing checksum")
+			p.Repaired = repair && writeFileAtomic(checksumFilename(filename), []byte(checksum(data))) == nil
+			revData[rev] = data
+			continue
+		}
+		if checksum(data) != string(expectedSum) {
+			//the latest is written after the rev, so it may still have the good copy
+			p := problem(filename, "checksum mismatch")
+			if latestErr == nil && checksum(latest) == string(expectedSum) {
+				revData[rev] = latest
+				p.Repaired = repair && writeFileAtomic(filename, latest) == nil
+			}
+			continue
+		}
+		revData[rev] = data
+	}
+	sort.Ints(revs)
+
+	//latest must be the same as the highest valid revision
+	highestValidRev := 0
+	for _, rev := range revs {
+		if _, ok := revData[rev]; ok {
+			highestValidRev = rev
+		}
+	}
+	if highestValidRev == 0 {
+		if len(revs) == 0 && latestErr == nil {
+			return problems //item without revisions
+		}
+		if latestErr != nil {
+			p := problem(itemDir, "orphan directory without a valid latest or revision")
+			if repair && len(revs) == 0 {
+				p.Repaired = os.RemoveAll(itemDir) == nil
+			}
+		}
+		return problems
+	}
+	expectedLatest := revData[highestValidRev]
+	if latestErr != nil || string(latest) != string(expectedLatest) {
+		msg := fmt.Sprintf("latest is not the same as rev_%d", highestValidRev)
+		if latestErr != nil {
+			msg = fmt.Sprintf("latest is invalid (%v)", latestErr)
+		}
+		p := problem(latestFilename, msg)
+		p.Repaired = repair && writeFileAtomic(latestFilename, expectedLatest) == nil
+	}
+	return problems
+} //filesStore.checkItem()
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+)
+
+func init() {
+	config.RegisterConstructor("memory", memoryStoreConfig{})
+}
+
+// memoryStoreConfig keeps everything in process memory, which is lost when the
+// service stops. Use it for tests.
+type memoryStoreConfig struct{}
+
+func (c memoryStoreConfig) Validate() error {
+	return nil
+}
+
+func (c memoryStoreConfig) Create() (Store, error) {
+	return &memoryStore{
+		items: map[string]map[string]*memoryItem{},
+	}, nil
+}
+
+type memoryStore struct {
+	sync.Mutex
+	items map[string]map[string]*memoryItem //[kind][id]
+}
+
+// memoryItem stores JSON so that loaded values never share memory with the store
+type memoryItem struct {
+	latest []byte
+	revs   map[int][]byte
+}
+
+func (s *memoryStore) Save(kind string, id string, rev int, item interface{}) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	jsonItem, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s", kind)
+	}
+	s.Lock()
+	defer s.Unlock()
+	itemByID, ok := s.items[kind]
+	if !ok {
+		itemByID = map[string]*memoryItem{}
+		s.items[kind] = itemByID
+	}
+	mi, ok := itemByID[id]
+	if !ok {
+		mi = &memoryItem{revs: map[int][]byte{}}
+		itemByID[id] = mi
+	}
+	mi.latest = jsonItem
+	if rev > 0 {
+		mi.revs[rev] = jsonItem
+	}
+	return nil
+} //memoryStore.Save()
+
+func (s *memoryStore) Load(kind string, id string, rev int, item interface{}) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	s.Lock()
+	mi, ok := s.items[kind][id]
+	var jsonItem []byte
+	if ok {
+		if rev == 0 {
+			jsonItem = mi.latest
+		} else {
+			jsonItem, ok = mi.revs[rev]
+		}
+	}
+	s.Unlock()
+	if !ok {
+		return errors.Errorf("%s.id(%s).rev(%d) not found", kind, id, rev)
+	}
+	if err := json.Unmarshal(jsonItem, item); err != nil {
+		return errors.Wrapf(err, "failed to decode %s", kind)
+	}
+	return nil
+} //memoryStore.Load()
+
+func (s *memoryStore) Delete(kind string, id string) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	delete(s.items[kind], id)
+	return nil
+} //memoryStore.Delete()
+
+func (s *memoryStore) List(kind string) ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+	ids := make([]string, 0, len(s.items[kind]))
+	for id := range s.items[kind] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+} //memoryStore.List()
+
+func (s *memoryStore) Revs(kind string, id string) ([]int, error) {
+	if err := validID(id); err != nil {
+		return nil, err
+	}
+	s.Lock()
+	defer s.Unlock()
+	mi, ok := s.items[kind][id]
+	if !ok {
+		return nil, errors.Errorf("%s.id(%s) not found", kind, id)
+	}
+	revs := make([]int, 0, len(mi.revs))
+	for rev := range mi.revs {
+		revs = append(revs, rev)
+	}
+	sort.Ints(revs)
+	return revs, nil
+} //memoryStore.Revs()
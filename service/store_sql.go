@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	config.RegisterConstructor("sqlite", sqlStoreConfig{})
+}
+
+// sqlStoreConfig stores everything in one table of an embedded SQLite
+// database file, with one row per revision and rev=0 for the latest.
+type sqlStoreConfig struct {
+	Filename string `json:"filename" doc:"SQLite database file (default: ./forms.db)"`
+}
+
+func (c sqlStoreConfig) Validate() error {
+	return nil
+}
+
+func (c sqlStoreConfig) Create() (Store, error) {
+	if c.Filename == "" {
+		c.Filename = "./forms.db"
+	}
+	db, err := sql.Open("sqlite3", c.Filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open sqlite db %s", c.Filename)
+	}
+	//sqlite allows only one writer at a time
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS items (
+		kind TEXT NOT NULL,
+		id   TEXT NOT NULL,
+		rev  INTEGER NOT NULL,
+		data TEXT NOT NULL,
+		PRIMARY KEY (kind, id, rev)
+	)`); err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "failed to create items table")
+	}
+	return &sqlStore{
+		config: c,
+		db:     db,
+	}, nil
+}
+
+type sqlStore struct {
+	config sqlStoreConfig
+	db     *sql.DB
+}
+
+func (s *sqlStore) Save(kind string, id string, rev int, item interface{}) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	jsonItem, err := json.Marshal(item)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode %s", kind)
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrapf(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+	revs := []int{0}
+	if rev > 0 {
+		revs = append(revs, rev)
+	}
+	for _, r := range revs {
+		if _, err := tx.Exec(
+			`INSERT OR REPLACE INTO items (kind, id, rev, data) VALUES (?, ?, ?, ?)`,
+			kind, id, r, string(jsonItem),
+		); err != nil {
+			return errors.Wrapf(err, "failed to save %s.id(%s).rev(%d)", kind, id, r)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return errors.Wrapf(err, "failed to commit %s.id(%s)", kind, id)
+	}
+	return nil
+} //sqlStore.Save()
+
+func (s *sqlStore) Load(kind string, id string, rev int, item interface{}) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	var jsonItem string
+	if err := s.db.QueryRow(
+		`SELECT data FROM items WHERE kind=? AND id=? AND rev=?`,
+		kind, id, rev,
+	).Scan(&jsonItem); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.Errorf("%s.id(%s).rev(%d) not found", kind, id, rev)
+		}
+		return errors.Wrapf(err, "failed to load %s.id(%s).rev(%d)", kind, id, rev)
+	}
+	if err := json.Unmarshal([]byte(jsonItem), item); err != nil {
+		return errors.Wrapf(err, "failed to decode %s", kind)
+	}
+	return nil
+} //sqlStore.Load()
+
+func (s *sqlStore) Delete(kind string, id string) error {
+	if err := validID(id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM items WHERE kind=? AND id=?`, kind, id); err != nil {
+		return errors.Wrapf(err, "failed to delete %s.id(%s)", kind, id)
+	}
+	return nil
+} //sqlStore.Delete()
+
+func (s *sqlStore) List(kind string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM items WHERE kind=? AND rev=0 ORDER BY id`, kind)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s", kind)
+	}
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s id", kind)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+} //sqlStore.List()
+
+func (s *sqlStore) Revs(kind string, id string) ([]int, error) {
+	if err := validID(id); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT rev FROM items WHERE kind=? AND id=? AND rev>0 ORDER BY rev`, kind, id)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list %s.id(%s) revs", kind, id)
+	}
+	defer rows.Close()
+	revs := []int{}
+	for rows.Next() {
+		var rev int
+		if err := rows.Scan(&rev); err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s.id(%s) rev", kind, id)
+		}
+		revs = append(revs, rev)
+	}
+	return revs, rows.Err()
+} //sqlStore.Revs()
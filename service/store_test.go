@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testStores creates each Store implementation with empty storage
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	stores := map[string]Store{}
+	for name, c := range map[string]interface{ Create() (Store, error) }{
+		"memory": memoryStoreConfig{},
+		"files":  filesStoreConfig{Dir: t.TempDir()},
+		"sqlite": sqlStoreConfig{Filename: t.TempDir() + "/forms.db"},
+	} {
+		s, err := c.Create()
+		if err != nil {
+			t.Fatalf("failed to create %s store: %+v", name, err)
+		}
+		stores[name] = s
+	}
+	return stores
+} //testStores()
+
+type testStoreItem struct {
+	ID    string `json:"id"`
+	Rev   int    `json:"rev"`
+	Value string `json:"value"`
+}
+
+// TestStore runs the same operations on every Store implementation, which
+// must all give the same results
+func TestStore(t *testing.T) {
+	for name, s := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			testStore(t, s)
+		})
+	}
+} //TestStore()
+
+func testStore(t *testing.T, s Store) {
+	//empty kind
+	if ids, err := s.List(formsKind); err != nil || len(ids) != 0 {
+		t.Fatalf("list empty kind: %v, %+v", ids, err)
+	}
+	var item testStoreItem
+	if err := s.Load(formsKind, "f1", 0, &item); err == nil {
+		t.Fatalf("loaded item that was not saved")
+	}
+
+	//revisions
+	for rev := 1; rev <= 3; rev++ {
+		if err := s.Save(formsKind, "f1", rev, testStoreItem{ID: "f1", Rev: rev, Value: "v"}); err != nil {
+			t.Fatalf("save rev %d failed: %+v", rev, err)
+		}
+	}
+	if err := s.Save(formsKind, "f2", 1, testStoreItem{ID: "f2", Rev: 1}); err != nil {
+		t.Fatalf("save f2 failed: %+v", err)
+	}
+	if err := s.Save(sessionsKind, "f1", 0, testStoreItem{ID: "f1", Value: "session"}); err != nil {
+		t.Fatalf("save in other kind failed: %+v", err)
+	}
+	if err := s.Load(formsKind, "f1", 0, &item); err != nil || item.Rev != 3 {
+		t.Fatalf("load latest: %+v, %+v", item, err)
+	}
+	if err := s.Load(formsKind, "f1", 2, &item); err != nil || item.Rev != 2 {
+		t.Fatalf("load rev 2: %+v, %+v", item, err)
+	}
+	if err := s.Load(formsKind, "f1", 4, &item); err == nil {
+		t.Fatalf("loaded rev 4 that was not saved")
+	}
+	if revs, err := s.Revs(formsKind, "f1"); err != nil || !reflect.DeepEqual(revs, []int{1, 2, 3}) {
+		t.Fatalf("revs: %v, %+v", revs, err)
+	}
+	if ids, err := s.List(formsKind); err != nil || !reflect.DeepEqual(ids, []string{"f1", "f2"}) {
+		t.Fatalf("list: %v, %+v", ids, err)
+	}
+
+	//rev 0 only updates the latest
+	if err := s.Save(sessionsKind, "f1", 0, testStoreItem{ID: "f1", Value: "updated"}); err != nil {
+		t.Fatalf("save rev 0 failed: %+v", err)
+	}
+	if err := s.Load(sessionsKind, "f1", 0, &item); err != nil || item.Value != "updated" {
+		t.Fatalf("load updated: %+v, %+v", item, err)
+	}
+	if revs, err := s.Revs(sessionsKind, "f1"); err != nil || len(revs) != 0 {
+		t.Fatalf("revs of item without revisions: %v, %+v", revs, err)
+	}
+
+	//delete removes all revisions, only of the kind
+	if err := s.Delete(formsKind, "f1"); err != nil {
+		t.Fatalf("delete failed: %+v", err)
+	}
+	if err := s.Load(formsKind, "f1", 0, &item); err == nil {
+		t.Fatalf("loaded deleted item")
+	}
+	if err := s.Load(formsKind, "f1", 1, &item); err == nil {
+		t.Fatalf("loaded revision of deleted item")
+	}
+	if revs, err := s.Revs(formsKind, "f1"); err == nil && len(revs) != 0 {
+		t.Fatalf("revs of deleted item: %v", revs)
+	}
+	if ids, err := s.List(formsKind); err != nil || !reflect.DeepEqual(ids, []string{"f2"}) {
+		t.Fatalf("list after delete: %v, %+v", ids, err)
+	}
+	if err := s.Load(sessionsKind, "f1", 0, &item); err != nil {
+		t.Fatalf("delete removed item of other kind: %+v", err)
+	}
+
+	//ids that are not a single path element are rejected
+	for _, id := range []string{"", "../x", "..", "a/b", "a\\b", "../sessions/f1"} {
+		if err := s.Save(formsKind, id, 1, testStoreItem{}); err == nil {
+			t.Errorf("saved id \"%s\"", id)
+		}
+		if err := s.Load(formsKind, id, 0, &item); err == nil {
+			t.Errorf("loaded id \"%s\"", id)
+		}
+		if _, err := s.Revs(formsKind, id); err == nil {
+			t.Errorf("listed revs of id \"%s\"", id)
+		}
+		if err := s.Delete(formsKind, id); err == nil {
+			t.Errorf("deleted id \"%s\"", id)
+		}
+	}
+	if err := s.Load(sessionsKind, "f1", 0, &item); err != nil || item.Value != "updated" {
+		t.Fatalf("item changed by invalid ids: %+v, %+v", item, err)
+	}
+	if ids, err := s.List(formsKind); err != nil || !reflect.DeepEqual(ids, []string{"f2"}) {
+		t.Fatalf("list after invalid ids: %v, %+v", ids, err)
+	}
+} //testStore()
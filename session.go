@@ -8,10 +8,13 @@ import (
 type Session struct {
 	ID            string                 `json:"id" doc:"Assigned when session is created for this email"`
 	Authenticated bool                   `json:"bool" doc:"Set true after authenticated"`
-	Email         string                 `json:"email" doc:"Email is unique for each session. Only one session per email and created after entered OTP on a device."`
+	Email         string                 `json:"email" doc:"Email is unique for each session. Only one session per email and created after entered OTP on a device. Before that it is the email where the OTP was sent."`
 	TimeCreated   time.Time              `json:"time_created"`
 	TimeUpdated   time.Time              `json:"time_updated"`
+	TimeExpires   time.Time              `json:"time_expires" doc:"Session is deleted after this time and a new login is required. Extended when the session is used, up to a maximum after it was created."`
 	Data          map[string]interface{} `json:"data"`
+	OtpHash       string                 `json:"otp_hash,omitempty" doc:"SHA-256 of the OTP sent to the email, kept by the service and never returned to callers"`
+	OtpExpiry     *time.Time             `json:"otp_expiry,omitempty" doc:"The OTP cannot be used after this time"`
 }
 
 func (s Session) Validate() error {
@@ -22,6 +25,19 @@ type Device struct {
 	ID          string    `json:"id" doc:"UUID generated on first use of device or after browser history was cleared. Stored in browser cookie to identify the device."`
 	TimeCreated time.Time `json:"time_created" doc:"Time when the device ID was generated."`
 	TimeLast    time.Time `json:"time_last" doc:"Time when it was last used to attempt to retrieve a session"`
+	TimeExpires time.Time `json:"time_expires" doc:"Device is deleted after this time, extended when it is used up to a maximum after it was created"`
 	Name        string    `json:"name,omitempty" doc:"User's way to identify the device. User can enter a name like \"MyPhone\""`
 	SessionID   string    `json:"session-id,omitempty" doc:"Session associated with this device"`
 }
+
+// Lockout records when login was blocked for an email or device after too many
+// wrong OTPs, kept for auditing
+type Lockout struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time" doc:"Time of the attempt that caused the lockout"`
+	Until      time.Time `json:"until" doc:"Login is blocked until this time"`
+	Email      string    `json:"email" doc:"Email that was entered"`
+	DeviceID   string    `json:"device_id" doc:"Device on which the OTP was entered"`
+	RemoteAddr string    `json:"remote_addr,omitempty" doc:"IP address of the client"`
+	Reason     string    `json:"reason" doc:"e.g. \"5 wrong OTPs for the email\""`
+}
@@ -4,13 +4,14 @@ import (
 	"context"
 	"fmt"
 	"html/template"
-	"math/rand"
 	"net/url"
 	"time"
 
 	"github.com/go-msvc/errors"
 	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
 	"github.com/go-msvc/humans"
+	"github.com/go-msvc/utils/ms"
 )
 
 // loginEmailHandler is called when login-email-form is posted to send the OTP
@@ -31,42 +32,42 @@ func loginEmailHandler(
 		//todo: show form again with error message...
 		return nil, nil, errors.Errorf("invalid email \"%s\": %+v", emailStr, err)
 	}
-	//generate an OTP and store it internally with an expiry
-	otp := newOtp()
-	otpExpiry := time.Now().Add(time.Minute * 10)
+	//the forms service generates the OTP and keeps its hash in the session,
+	//unless repeated requests must wait longer each time before another is sent
+	deviceID := ctx.Value(CtxDeviceID{}).(string)
+	remoteAddr, _ := ctx.Value(CtxRemoteAddr{}).(string)
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "request_otp",
+		},
+		formsTTL,
+		formsinterface.RequestOtpRequest{
+			Principal:  servicePrincipal(),
+			DeviceID:   deviceID,
+			Email:      emailValue.String(),
+			RemoteAddr: remoteAddr,
+		},
+		formsinterface.RequestOtpResponse{})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to request OTP")
+	}
+	otp := res.(formsinterface.RequestOtpResponse)
+	if !otp.Allowed {
+		return loginEmailTemplate, map[string]interface{}{"error": loginDeniedMessage(otp.Reason, otp.RetryAfter)}, nil
+	}
 
-	log.Errorf("NOT SENDING EMAIL")
 	//send email with OTP
-	// if err := email.Send(
-	// 	email.Message{
-	// 		From:        email.Email{Addr: "jan.semmelink@gmail.com", Name: "Forms"},
-	// 		To:          []email.Email{{Addr: emailValue.String()}},
-	// 		Cc:          []email.Email{},
-	// 		Bcc:         []email.Email{},
-	// 		Subject:     "Login OTP",
-	// 		ContentType: "text/html",
-	// 		Content: `<h1>Forms Login</h1>
-	// 		<p>Enter the following OTP to login to forms.</p>
-	// 		<h2>` + otp + `</h2>`,
-	// 		AttachmentFilenames: []string{},
-	// 	},
-	// ); err != nil {
-	// 	log.Errorf("failed to send email to \"%s\"", emailStr)
-	// 	//return nil, nil, errors.Wrapf(err, "failed to send email to \"%s\"", emailStr)
-	// }
-
-	session.Authenticated = false
-	session.Email = emailValue.String()
-	session.Data["otp"] = otp
-	session.Data["otp_expiry"] = otpExpiry.Format("2006-01-02T15:04:05Z")
-
-	deviceID := ctx.Value(CtxDeviceID{}).(string)
-	log.Debugf("device(%s).session(%s) sent email to %s with OTP:\"%s\"", deviceID, session.ID, emailStr, otp)
+	if err := sendOtp(emailValue.String(), otp.OTP, otp.Expiry); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to send OTP to \"%s\"", emailStr)
+	}
+	log.Debugf("device(%s).session(%s) sent OTP to %s", deviceID, session.ID, emailStr)
 
 	//show OTP form
 	otpFormData := map[string]interface{}{
 		"Email":     emailStr,
-		"OtpExpiry": otpExpiry.Format("2006-01-02 15:04:05"),
+		"OtpExpiry": otp.Expiry.Local().Format("2006-01-02 15:04:05"),
 	}
 	return loginOtpTemplate, otpFormData, nil
 } //loginEmailHandler
@@ -84,49 +85,53 @@ func loginOtpHandler(
 ) {
 	log.Debugf("OTP Handler...")
 	deviceID := ctx.Value(CtxDeviceID{}).(string)
-	if session.Email == "" {
-		log.Errorf("Missing session email: %+v", session)
-		return loginEmailTemplate, map[string]interface{}{"error": "Missing email in session data"}, nil
-	}
-	otpExpiry, err := time.Parse("2006-01-02T15:04:05Z", session.Data["otp_expiry"].(string))
-	if err != nil || otpExpiry.Before(time.Now()) {
-		log.Errorf("OTP Expired:%v err:%v", otpExpiry, err)
-		return loginEmailTemplate, map[string]interface{}{"error": "OTP expired"}, nil
-	}
-	expectedOtp := session.Data["otp"].(string)
-	if expectedOtp == "" {
-		log.Errorf("OTP Expired:%v err:%v", otpExpiry, err)
-		log.Errorf("OTP=\"%s\"", expectedOtp)
-		return loginEmailTemplate, map[string]interface{}{"error": "OTP not yet sent."}, nil
-	}
+	remoteAddr, _ := ctx.Value(CtxRemoteAddr{}).(string)
 
-	//ready for OTP check
+	//ready for OTP check, the session email is where the OTP was sent
+	otpFormData := map[string]interface{}{
+		"Email": session.Email,
+	}
 	enteredOtp := formData.Get("otp")
-	log.Errorf("entered otp=\"%s\"", enteredOtp)
 	if enteredOtp == "" {
-		return loginOtpTemplate, map[string]interface{}{"error": "OTP not yet entered."}, nil
+		otpFormData["error"] = "OTP not yet entered."
+		return loginOtpTemplate, otpFormData, nil
 	}
 
-	//todo: uncomment - commented out so can login with any OTP while testing
-	// if enteredOtp != expectedOtp {
-	// 	log.Errorf("entered otp=\"%s\" != expectedOtp=\"%s\"", enteredOtp, expectedOtp)
-	// 	return loginOtpTemplate, map[string]interface{}{"error": "Wrong OTP. Please try again."}, nil
-	// }
-	log.Errorf("entered CORRECT otp=\"%s\"", enteredOtp)
-
-	//correct OTP - user is now logged in
-	session.Authenticated = true
-
-	//associate the device id with the email address
-	//todo: if user has another authenticated session, switch to that session...
-	//let it be done in back-end, associate device with that session, and email with this session if no session yet, then return the final session to here
-
-	//todo...
-	log.Errorf("Not yet storing device-id:\"%s\" against email:\"%s\"", deviceID, "?") //emailValue.String())
+	//the forms service checks the OTP and throttles wrong attempts
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "verify_otp",
+		},
+		formsTTL,
+		formsinterface.VerifyOtpRequest{
+			Principal:  servicePrincipal(),
+			DeviceID:   deviceID,
+			OTP:        enteredOtp,
+			RemoteAddr: remoteAddr,
+		},
+		formsinterface.VerifyOtpResponse{})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to verify OTP")
+	}
+	verified := res.(formsinterface.VerifyOtpResponse)
+	if !verified.LoggedIn {
+		log.Errorf("device(%s).session(%s) not logged in as %s: %s", deviceID, session.ID, session.Email, verified.Reason)
+		if !verified.Allowed {
+			//expired or locked out - the OTP cannot be used any more
+			return loginEmailTemplate, map[string]interface{}{"error": loginDeniedMessage(verified.Reason, verified.RetryAfter)}, nil
+		}
+		otpFormData["error"] = "Wrong OTP. Please try again."
+		return loginOtpTemplate, otpFormData, nil
+	}
 
-	//update session values to clear the expected OTP
-	session.Data["otp"] = ""
-	session.Data["opt_expiry"] = nil
+	//correct OTP - the device may have joined the existing session of the email
+	*session = verified.Session
+	if session.Data == nil {
+		session.Data = map[string]interface{}{}
+	}
+	log.Debugf("device(%s) logged in as %s", deviceID, session.Email)
 
 	//go to page originally requested or go to user's home
 	log.Debugf("Logged in")
@@ -148,15 +153,17 @@ func logoutHandler(
 	err error,
 ) {
 	//this logs out all devices! It is safest to asume that is what user wants
+	if logout, ok := ctx.Value(CtxLogout{}).(*bool); ok && logout != nil {
+		*logout = true
+	}
 	session.Authenticated = false
 	return homeTemplate, nil, nil
 }
 
-func newOtp() string {
-	otp := ""
-	for i := 0; i < 4; i++ {
-		n := rand.Intn(26)
-		otp += fmt.Sprintf("%c", 'A'+n)
+// loginDeniedMessage explains why the user must enter the email again
+func loginDeniedMessage(reason string, retryAfter time.Time) string {
+	if retryAfter.IsZero() {
+		return fmt.Sprintf("Login failed because %s. Please request a new OTP.", reason)
 	}
-	return otp
-}
+	return fmt.Sprintf("Login blocked because of %s. Please try again after %s.", reason, retryAfter.Local().Format("2006-01-02 15:04:05"))
+} //loginDeniedMessage()
@@ -2,10 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-msvc/errors"
@@ -16,83 +17,181 @@ import (
 
 func showCampaign(ctx context.Context, session *forms.Session, params map[string]string) (*template.Template, interface{}, error) {
 	log.Debugf("showCampaign(%+v)", params)
-	campaign, form, err := loadCampaign(ctx, params["id"])
+	campaign, form, err := loadCampaign(ctx, session, params["id"])
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "failed to load campaign")
 	}
 
+	//answers entered before are kept when the same form is opened again,
+	//but not for another campaign or form revision or after editing a doc
+	if docID, _ := editingDoc(session); docID != "" ||
+		session.Data["campaign_id"] != campaign.ID ||
+		session.Data["form_id"] != form.ID ||
+		fmt.Sprintf("%v", session.Data["form_rev"]) != fmt.Sprintf("%v", form.Rev) {
+		wizardReset(session)
+	}
+
 	//set data in session that will be needed when the form is submitted
 	session.Data["campaign_id"] = campaign.ID
 	session.Data["form_id"] = form.ID
 	session.Data["form_rev"] = form.Rev
 
+	//load form template (todo: use global already loaded template when not in dev)
+	formTemplate := loadTemplates([]string{"form", "page"})
+	return formTemplate, wizardForm(campaign, form, session, nil), nil
+} //showCampaign()
+
+// campaignForm prepares the form to render for the campaign
+func campaignForm(campaign forms.Campaign, form forms.Form) forms.Form {
 	//render markdown in the form to HTML
 	form.Header = renderHeaderHTML(form.Header)
 	for i, s := range form.Sections {
-		s.Header = renderHeaderHTML(s.Header)
-		for itemIndex, item := range s.Items {
-			if item.Header != nil {
-				*item.Header = renderHeaderHTML(*item.Header)
-			}
-			if item.Field != nil {
-				item.Field.Header = renderHeaderHTML(item.Field.Header)
-			}
-			if item.Image != nil {
-				item.Image.Header = renderHeaderHTML(item.Image.Header)
-			}
-			if item.Table != nil {
-				item.Table.Header = renderHeaderHTML(item.Table.Header)
-			}
-			if item.Sub != nil {
-				item.Sub.Header = renderHeaderHTML(item.Sub.Header)
-			}
-			s.Items[itemIndex] = item
-		} //for each item
-		form.Sections[i] = s
-	} //for each section
+		form.Sections[i] = renderSectionHTML(s)
+	}
 
 	//set values needed in the form
 	form.Action = fmt.Sprintf("/campaign/%s", campaign.ID)
 	form.CampaignID = campaign.ID
+	return form
+} //campaignForm()
 
-	//load form template (todo: use global already loaded template when not in dev)
-	formTemplate := loadTemplates([]string{"form", "page"})
-	return formTemplate, form, nil
-} //showCampaign()
+// renderSectionHTML renders markdown in the section and its items, including
+// the items of sub sections
+func renderSectionHTML(s forms.Section) forms.Section {
+	s.Header = renderHeaderHTML(s.Header)
+	for itemIndex, item := range s.Items {
+		if item.Header != nil {
+			*item.Header = renderHeaderHTML(*item.Header)
+		}
+		if item.Field != nil {
+			item.Field.Header = renderHeaderHTML(item.Field.Header)
+		}
+		if item.Image != nil {
+			item.Image.Header = renderHeaderHTML(item.Image.Header)
+		}
+		if item.Table != nil {
+			item.Table.Header = renderHeaderHTML(item.Table.Header)
+			for colIndex, col := range item.Table.Fields {
+				col.Header = renderHeaderHTML(col.Header)
+				item.Table.Fields[colIndex] = col
+			}
+		}
+		if item.Sub != nil {
+			item.Sub.Header = renderHeaderHTML(item.Sub.Header)
+			if item.Sub.Section != nil {
+				subSection := renderSectionHTML(*item.Sub.Section)
+				item.Sub.Section = &subSection
+			}
+		}
+		s.Items[itemIndex] = item
+	} //for each item
+	return s
+} //renderSectionHTML()
 
 func postCampaign(ctx context.Context, session *forms.Session, params map[string]string, formData url.Values) (*template.Template, interface{}, error) {
 	log.Debugf("postCampaign(%+v)", params)
 
 	id := session.Data["campaign_id"].(string)
-	campaign, _, err := loadCampaign(ctx, id)
+	editDocID, _ := editingDoc(session)
+	var campaign forms.Campaign
+	var form forms.Form
+	var err error
+	if editDocID != "" {
+		//edits are allowed after the campaign ended, checked when the doc is updated
+		campaign, form, err = getCampaign(ctx, session, id)
+	} else {
+		campaign, form, err = loadCampaign(ctx, session, id)
+	}
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "failed to load campaign")
 	}
 
-	doc, err := postForm(ctx, session, formData)
+	//keep what was entered on this page, even when going back
+	answers := wizardAnswers(session)
+	page := wizardPage(session, form)
+	keepPageAnswers(form, answers, page, postedData(formData))
+
+	formTemplate := loadTemplates([]string{"form", "page"})
+	nav := formData.Get("nav")
+	switch {
+	case nav == "back":
+		if prev := prevPage(form, answers, page); prev >= 0 {
+			session.Data["page"] = prev
+		}
+		return formTemplate, wizardForm(campaign, form, session, nil), nil
+
+	case strings.HasPrefix(nav, "edit:"):
+		//edit a section from the review page
+		if i, err := strconv.Atoi(strings.TrimPrefix(nav, "edit:")); err == nil && i >= 0 && i < len(form.Sections) {
+			session.Data["page"] = i
+		}
+		return formTemplate, wizardForm(campaign, form, session, nil), nil
+
+	case nav == "save_draft" && editDocID == "":
+		//keep the answers in the service to continue later, also on another device
+		if _, err := saveDraft(ctx, session, form); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to save draft")
+		}
+		return formTemplate, wizardForm(campaign, form, session, nil), nil
+
+	case page < len(form.Sections):
+		//next page after the values on this page are valid
+		if err := form.ValidateSectionData(form.Sections[page].Name, answers); err != nil {
+			fieldErrors, ok := err.(forms.FieldErrors)
+			if !ok {
+				return nil, nil, errors.Wrapf(err, "failed to validate section(%s)", form.Sections[page].Name)
+			}
+			//show the page again with the posted values and the reasons next to the fields
+			log.Debugf("invalid section(%s) data: %v", form.Sections[page].Name, fieldErrors)
+			return formTemplate, wizardForm(campaign, form, session, fieldErrors), nil
+		}
+		session.Data["page"] = nextPage(form, answers, page)
+		return formTemplate, wizardForm(campaign, form, session, nil), nil
+	}
+
+	//submit from the review page
+	doc, err := postForm(ctx, session, form, answers)
+	if dataErr, ok := err.(formDataError); ok {
+		//show the first page with an invalid value
+		log.Debugf("invalid form data: %v", dataErr.Errors)
+		session.Data["page"] = errorPage(form, answers, dataErr.Errors)
+		return formTemplate, wizardForm(campaign, form, session, dataErr.Errors), nil
+	}
 	if err != nil {
 		log.Errorf("failed to post submitted form: %+v", err)
 		return nil, nil, errors.Wrapf(err, "failed to submit the form data")
 	}
+	wizardReset(session)
+	//the service notifies the campaign queue to process the doc
 	log.Debugf("Submitted: %+v", doc)
 
-	//send campaign notification
-	notification := formsinterface.CampaignNotification{
-		CampaingID: campaign.ID, //todo: should come from ctx session data
-		DocID:      doc.ID,
-	}
-	jsonNotification, _ := json.Marshal(notification)
-	if _, err := redisClient.LPush(ctx, campaign.ID, jsonNotification).Result(); err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to send for processing")
-	}
-
 	//show details of submitted documents
-	return campaignSubmittedTemplate, map[string]interface{}{
+	submitted := map[string]interface{}{
 		"CampaignID": campaign.ID,
-	}, nil
+		"Edited":     editDocID != "",
+	}
+	if campaign.EditAllowed(doc, time.Now()) == nil {
+		submitted["EditURL"] = fmt.Sprintf("/campaign/%s/doc/%s/edit", campaign.ID, doc.ID)
+	}
+	return campaignSubmittedTemplate, submitted, nil
 } //postCampaign()
 
-func loadCampaign(ctx context.Context, id string) (forms.Campaign, forms.Form, error) {
+// loadCampaign loads a campaign that is open for submission with its form
+func loadCampaign(ctx context.Context, session *forms.Session, id string) (forms.Campaign, forms.Form, error) {
+	campaign, form, err := getCampaign(ctx, session, id)
+	if err != nil {
+		return forms.Campaign{}, forms.Form{}, err
+	}
+	//the service checks it again when the doc is submitted
+	if err := campaign.Open(time.Now()); err != nil {
+		return forms.Campaign{}, forms.Form{}, err
+	}
+	return campaign, form, nil
+} //loadCampaign()
+
+// getCampaign loads a campaign with its latest form, also when the campaign
+// is not open for submission, e.g. to edit docs submitted before it ended
+func getCampaign(ctx context.Context, session *forms.Session, id string) (forms.Campaign, forms.Form, error) {
 	res, err := msClient.Sync(
 		ctx,
 		ms.Address{
@@ -101,7 +200,8 @@ func loadCampaign(ctx context.Context, id string) (forms.Campaign, forms.Form, e
 		},
 		formsTTL,
 		formsinterface.GetCampaignRequest{
-			ID: id,
+			Principal: principal(session),
+			ID:        id,
 		},
 		formsinterface.GetCampaignResponse{})
 	if err != nil {
@@ -117,19 +217,12 @@ func loadCampaign(ctx context.Context, id string) (forms.Campaign, forms.Form, e
 		},
 		formsTTL,
 		formsinterface.GetFormRequest{
-			ID: campaign.FormID,
+			Principal: principal(session),
+			ID:        campaign.FormID,
 		},
 		formsinterface.GetFormResponse{})
 	if err != nil {
 		return forms.Campaign{}, forms.Form{}, errors.Wrapf(err, "campaign.form.id(%s) not found", id)
 	}
-	form := res.(formsinterface.GetFormResponse).Form
-
-	if campaign.StartTime.After(time.Now()) {
-		return forms.Campaign{}, forms.Form{}, errors.Wrapf(err, "campaign(%s) only starts at %v", campaign.ID, campaign.StartTime)
-	}
-	if campaign.EndTime.Before(time.Now()) {
-		return forms.Campaign{}, forms.Form{}, errors.Wrapf(err, "campaign(%s) ended at %v", campaign.ID, campaign.EndTime)
-	}
-	return campaign, form, nil
-} //loadCampaign()
+	return campaign, res.(formsinterface.GetFormResponse).Form, nil
+} //getCampaign()
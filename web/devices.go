@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"html/template"
+	"net/url"
+	"strings"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/go-msvc/utils/ms"
+)
+
+// Users see the devices logged in to their session at /user/devices where
+// they can name them and revoke lost or stolen devices, which then have to
+// login again.
+
+type DevicesTmplData struct {
+	Devices    []DeviceTmplData
+	MaxNameLen int
+}
+
+type DeviceTmplData struct {
+	ID          string
+	Name        string
+	TimeCreated string
+	TimeLast    string
+	Current     bool //the device showing the page, which cannot revoke itself
+}
+
+func userDevices(ctx context.Context, session *forms.Session, params map[string]string) (*template.Template, interface{}, error) {
+	log.Debugf("userDevices(%+v)", params)
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "list_devices",
+		},
+		formsTTL,
+		formsinterface.ListDevicesRequest{
+			Principal: principal(session),
+		},
+		formsinterface.ListDevicesResponse{})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to list devices")
+	}
+	deviceID := ctx.Value(CtxDeviceID{}).(string)
+	data := DevicesTmplData{
+		Devices:    []DeviceTmplData{},
+		MaxNameLen: formsinterface.MaxDeviceNameLen,
+	}
+	for _, d := range res.(formsinterface.ListDevicesResponse).Devices {
+		data.Devices = append(data.Devices, DeviceTmplData{
+			ID:          d.ID,
+			Name:        d.Name,
+			TimeCreated: d.TimeCreated.Local().Format("2006-01-02 15:04:05"),
+			TimeLast:    d.TimeLast.Local().Format("2006-01-02 15:04:05"),
+			Current:     d.ID == deviceID,
+		})
+	}
+	return userDevicesTemplate, data, nil
+} //userDevices()
+
+// postDevices renames or revokes a device, then shows the devices again
+func postDevices(ctx context.Context, session *forms.Session, params map[string]string, formData url.Values) (*template.Template, interface{}, error) {
+	log.Debugf("postDevices(%+v)", params)
+	deviceID := formData.Get("device_id")
+	switch action := formData.Get("action"); action {
+	case "rename":
+		if _, err := msClient.Sync(
+			ctx,
+			ms.Address{
+				Domain:    formsDomain,
+				Operation: "rename_device",
+			},
+			formsTTL,
+			formsinterface.RenameDeviceRequest{
+				Principal: principal(session),
+				DeviceID:  deviceID,
+				Name:      strings.TrimSpace(formData.Get("name")),
+			},
+			formsinterface.RenameDeviceResponse{}); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to rename device")
+		}
+	case "revoke":
+		//this device logs out instead, because its session is updated after this request
+		if deviceID == ctx.Value(CtxDeviceID{}).(string) {
+			return nil, nil, errors.Errorf("cannot revoke the device you are using, logout instead")
+		}
+		if _, err := msClient.Sync(
+			ctx,
+			ms.Address{
+				Domain:    formsDomain,
+				Operation: "revoke_device",
+			},
+			formsTTL,
+			formsinterface.RevokeDeviceRequest{
+				Principal: principal(session),
+				DeviceID:  deviceID,
+			},
+			formsinterface.RevokeDeviceResponse{}); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to revoke device")
+		}
+	default:
+		return nil, nil, errors.Errorf("unknown action \"%s\"", action)
+	}
+	return nil, nil, ErrorRedirect("/user/devices")
+} //postDevices()
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"html/template"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/go-msvc/utils/ms"
+)
+
+// Answers in the session can be saved as a draft in the service, tied to the
+// email of the user, to continue later with the link to /draft/{id} which
+// also works on another device after login.
+
+// saveDraft saves the answers as a draft and keeps its id in the session
+func saveDraft(ctx context.Context, session *forms.Session, form forms.Form) (forms.Doc, error) {
+	draftID, _ := session.Data["draft_id"].(string)
+	campaignID, _ := session.Data["campaign_id"].(string)
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "save_draft",
+		},
+		formsTTL,
+		formsinterface.SaveDraftRequest{
+			Principal: principal(session),
+			Doc: forms.Doc{
+				ID:         draftID,
+				FormID:     form.ID,
+				FormRev:    form.Rev,
+				CampaignID: campaignID,
+				UserID:     session.Email,
+				Data:       wizardAnswers(session),
+			},
+		},
+		formsinterface.SaveDraftResponse{})
+	if err != nil {
+		return forms.Doc{}, errors.Wrapf(err, "failed to save draft")
+	}
+	draft := res.(formsinterface.SaveDraftResponse).Doc
+	session.Data["draft_id"] = draft.ID
+	log.Debugf("saved draft(%s) until %v", draft.ID, draft.Expires)
+	return draft, nil
+} //saveDraft()
+
+// submitDraft saves the final answers in the draft and submits it
+func submitDraft(ctx context.Context, session *forms.Session, form forms.Form) (forms.Doc, error) {
+	draft, err := saveDraft(ctx, session, form)
+	if err != nil {
+		return forms.Doc{}, err
+	}
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "submit_draft",
+		},
+		formsTTL,
+		formsinterface.SubmitDraftRequest{
+			Principal: principal(session),
+			ID:        draft.ID,
+		},
+		formsinterface.SubmitDraftResponse{})
+	if err != nil {
+		return forms.Doc{}, errors.Wrapf(err, "failed to submit draft")
+	}
+	return res.(formsinterface.SubmitDraftResponse).Doc, nil
+} //submitDraft()
+
+// resumeDraft shows the campaign form with the answers saved in the draft
+func resumeDraft(ctx context.Context, session *forms.Session, params map[string]string) (*template.Template, interface{}, error) {
+	log.Debugf("resumeDraft(%+v)", params)
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "get_draft",
+		},
+		formsTTL,
+		formsinterface.GetDraftRequest{
+			Principal: principal(session),
+			ID:        params["id"],
+		},
+		formsinterface.GetDraftResponse{})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "draft not found")
+	}
+	draft := res.(formsinterface.GetDraftResponse).Doc
+	campaign, form, err := loadCampaign(ctx, session, draft.CampaignID)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load campaign")
+	}
+
+	//continue on the latest form, keeping only answers of items still in it
+	wizardReset(session)
+	answers := wizardAnswers(session)
+	for page := range form.Sections {
+		keepPageAnswers(form, answers, page, draft.Data)
+	}
+	session.Data["campaign_id"] = campaign.ID
+	session.Data["form_id"] = form.ID
+	session.Data["form_rev"] = form.Rev
+	session.Data["draft_id"] = draft.ID
+
+	formTemplate := loadTemplates([]string{"form", "page"})
+	return formTemplate, wizardForm(campaign, form, session, nil), nil
+} //resumeDraft()
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strconv"
+	"time"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/go-msvc/utils/ms"
+)
+
+// A submitted doc can be edited by the user who submitted it with the link to
+// /campaign/{id}/doc/{doc_id}/edit while the campaign allows edits, or when a
+// reviewer returned it with comments that are shown next to the fields. The form
+// is filled in with the latest revision of the doc and when submitted again,
+// the doc is updated with upd_doc, which fails if the doc was changed since
+// it was opened.
+
+// editCampaignDoc shows the campaign form with the answers of a submitted doc
+func editCampaignDoc(ctx context.Context, session *forms.Session, params map[string]string) (*template.Template, interface{}, error) {
+	log.Debugf("editCampaignDoc(%+v)", params)
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "get_doc",
+		},
+		formsTTL,
+		formsinterface.GetDocRequest{
+			Principal: principal(session),
+			ID:        params["doc_id"],
+		},
+		formsinterface.GetDocResponse{})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "doc not found")
+	}
+	doc := res.(formsinterface.GetDocResponse).Doc
+	if doc.UserID != session.Email {
+		return nil, nil, errors.Errorf("doc(%s) belongs to another user", doc.ID)
+	}
+	campaign, form, err := getCampaign(ctx, session, params["id"])
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load campaign")
+	}
+	if err := campaign.EditAllowed(doc, time.Now()); err != nil {
+		return nil, nil, err
+	}
+
+	//edit on the latest form, keeping only answers of items still in it,
+	//starting on the review page to see all the answers
+	wizardReset(session)
+	answers := wizardAnswers(session)
+	inputs := form.InputData(doc.Data)
+	for page := range form.Sections {
+		keepPageAnswers(form, answers, page, inputs)
+	}
+	session.Data["campaign_id"] = campaign.ID
+	session.Data["form_id"] = form.ID
+	session.Data["form_rev"] = form.Rev
+	session.Data["doc_id"] = doc.ID
+	session.Data["doc_rev"] = doc.Rev
+	session.Data["page"] = len(form.Sections)
+	if comments := doc.ReturnedComments(); len(comments) > 0 {
+		//shown next to the fields until the doc is resubmitted
+		jsonComments, _ := json.Marshal(comments)
+		session.Data["comments"] = string(jsonComments)
+	}
+
+	formTemplate := loadTemplates([]string{"form", "page"})
+	return formTemplate, wizardForm(campaign, form, session, nil), nil
+} //editCampaignDoc()
+
+// editingDoc returns the id and rev of the submitted doc being edited, or ""
+// when a new doc is being filled in
+func editingDoc(session *forms.Session) (string, int) {
+	docID, _ := session.Data["doc_id"].(string)
+	if docID == "" {
+		return "", 0
+	}
+	docRev, err := strconv.Atoi(fmt.Sprintf("%v", session.Data["doc_rev"]))
+	if err != nil {
+		return "", 0
+	}
+	return docID, docRev
+} //editingDoc()
+
+// updateDoc submits the edited answers as a new revision of the doc
+func updateDoc(ctx context.Context, session *forms.Session, doc forms.Doc) (forms.Doc, error) {
+	docID, docRev := editingDoc(session)
+	doc.ID = docID
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "upd_doc",
+		},
+		formsTTL,
+		formsinterface.UpdDocRequest{
+			Principal:   principal(session),
+			Doc:         doc,
+			ExpectedRev: docRev,
+		},
+		formsinterface.UpdDocResponse{})
+	if err != nil {
+		return forms.Doc{}, errors.Wrapf(err, "failed to update doc(%s)", docID)
+	}
+	return res.(formsinterface.UpdDocResponse).Doc, nil
+} //updateDoc()
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+)
+
+// Mailer sends emails, e.g. the login OTP.
+//
+// Implementations are registered with config.RegisterConstructor() and the one
+// to use is selected in config.json, e.g. {"mailer":{"smtp":{...}}} or for
+// local testing without a network {"mailer":{"files":{"dir":"./mail"}}}
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Message is an email to send to one address
+type Message struct {
+	To          string
+	Subject     string
+	ContentType string //default "text/plain"
+	Body        string
+}
+
+// mailer is created from config in main()
+var mailer Mailer
+
+func init() {
+	config.MustConstruct("mailer", reflect.TypeOf((*Mailer)(nil)).Elem())
+}
+
+// encode returns the message with headers as sent over SMTP and written to .eml files
+func (msg Message) encode(from string) []byte {
+	contentType := msg.ContentType
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "From: %s\r\n", from)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: %s; charset=\"utf-8\"\r\n", contentType)
+	fmt.Fprintf(buf, "\r\n")
+	buf.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return buf.Bytes()
+} //Message.encode()
+
+// validateHeader rejects values that would inject more headers into the message
+func validateHeader(name string, value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return errors.Errorf("%s may not contain line breaks", name)
+	}
+	return nil
+}
+
+func (msg Message) Validate() error {
+	if msg.To == "" {
+		return errors.Errorf("missing to")
+	}
+	if err := validateHeader("to", msg.To); err != nil {
+		return err
+	}
+	if err := validateHeader("subject", msg.Subject); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+	"github.com/google/uuid"
+)
+
+func init() {
+	config.RegisterConstructor("files", filesMailerConfig{})
+	config.RegisterConstructor("log", logMailerConfig{})
+}
+
+// filesMailerConfig writes each email to a .eml file in the dir instead of
+// sending it, to test locally without a network
+type filesMailerConfig struct {
+	Dir  string `json:"dir" doc:"Directory where .eml files are written"`
+	From string `json:"from,omitempty" doc:"Sender address written in the files"`
+}
+
+func (c filesMailerConfig) Validate() error {
+	if c.Dir == "" {
+		return errors.Errorf("missing dir")
+	}
+	return nil
+}
+
+func (c filesMailerConfig) Create() (Mailer, error) {
+	if err := os.MkdirAll(c.Dir, 0770); err != nil {
+		return nil, errors.Wrapf(err, "failed to create mail dir(%s)", c.Dir)
+	}
+	if c.From == "" {
+		c.From = "forms@localhost"
+	}
+	return filesMailer{config: c}, nil
+}
+
+type filesMailer struct {
+	config filesMailerConfig
+}
+
+func (m filesMailer) Send(msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid message")
+	}
+	fn := filepath.Join(m.config.Dir, time.Now().Format("20060102-150405")+"-"+uuid.New().String()+".eml")
+	if err := os.WriteFile(fn, msg.encode(m.config.From), 0660); err != nil {
+		return errors.Wrapf(err, "failed to write email to %s", fn)
+	}
+	log.Debugf("email to %s written to %s", msg.To, fn)
+	return nil
+} //filesMailer.Send()
+
+// logMailerConfig only logs the emails, which includes the OTP, so it must
+// never be used in production
+type logMailerConfig struct{}
+
+func (c logMailerConfig) Validate() error {
+	return nil
+}
+
+func (c logMailerConfig) Create() (Mailer, error) {
+	return logMailer{}, nil
+}
+
+type logMailer struct{}
+
+func (m logMailer) Send(msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid message")
+	}
+	log.Infof("email to %s (%s):\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+} //logMailer.Send()
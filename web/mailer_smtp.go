@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+)
+
+func init() {
+	config.RegisterConstructor("smtp", smtpMailerConfig{})
+}
+
+// smtpMailerConfig sends emails with an SMTP server, e.g.
+// {"mailer":{"smtp":{"addr":"smtp.example.com:587","username":"...","password":"...","from":"Forms <forms@example.com>"}}}
+type smtpMailerConfig struct {
+	Addr     string `json:"addr" doc:"SMTP server host:port, e.g. \"smtp.example.com:587\""`
+	Username string `json:"username,omitempty" doc:"Username for PLAIN auth, omit when the server does not require auth"`
+	Password string `json:"password,omitempty" doc:"Password for PLAIN auth"`
+	From     string `json:"from" doc:"Sender address, e.g. \"Forms <forms@example.com>\""`
+}
+
+func (c smtpMailerConfig) Validate() error {
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return errors.Errorf("invalid addr:\"%s\", expecting host:port", c.Addr)
+	}
+	if c.From == "" {
+		return errors.Errorf("missing from")
+	}
+	if err := validateHeader("from", c.From); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c smtpMailerConfig) Create() (Mailer, error) {
+	return smtpMailer{config: c}, nil
+}
+
+type smtpMailer struct {
+	config smtpMailerConfig
+}
+
+func (m smtpMailer) Send(msg Message) error {
+	if err := msg.Validate(); err != nil {
+		return errors.Wrapf(err, "invalid message")
+	}
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		host, _, _ := net.SplitHostPort(m.config.Addr)
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, host)
+	}
+	if err := smtp.SendMail(m.config.Addr, auth, envelopeAddr(m.config.From), []string{msg.To}, msg.encode(m.config.From)); err != nil {
+		return errors.Wrapf(err, "failed to send email to %s", msg.To)
+	}
+	return nil
+} //smtpMailer.Send()
+
+// envelopeAddr returns the address part of "Name <addr>" for the SMTP envelope
+func envelopeAddr(from string) string {
+	if i := strings.LastIndex(from, "<"); i >= 0 && strings.HasSuffix(from, ">") {
+		return from[i+1 : len(from)-1]
+	}
+	return from
+}
@@ -8,11 +8,11 @@ import (
 	"os"
 	"time"
 
+	"github.com/go-msvc/config"
 	"github.com/go-msvc/forms"
 	"github.com/go-msvc/logger"
 	"github.com/go-msvc/nats-utils"
 	"github.com/go-msvc/utils/ms"
-	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
 
@@ -22,12 +22,20 @@ import (
 var (
 	log         = logger.New().WithLevel(logger.LevelDebug)
 	msClient    ms.Client
-	redisClient *redis.Client
 	formsDomain = "forms"
 	formsTTL    = time.Second * 1
 )
 
 func main() {
+	config.AddSource("config.json", config.File("./config.json"))
+	if err := config.Load(); err != nil {
+		panic(err)
+	}
+	mailer = config.Get("mailer").(Mailer)
+	loginOtp = config.Get("otp").(otpConfig)
+	webService = config.Get("service").(serviceConfig)
+	limiter = newRateLimiter(config.Get("rate_limit").(rateLimitConfig))
+
 	//preload some templates
 	loadResources()
 
@@ -38,10 +46,14 @@ func main() {
 	r.HandleFunc("/otp", open(page(loginOtpTemplate), loginOtpHandler))
 	r.HandleFunc("/logout", open(logoutHandler, nil))
 	r.HandleFunc("/user", secure(userHomeGetHandler, nil))
-	r.HandleFunc("/user/campaign/{campaign_id}", secure(myCampaign, nil)) //for submission
-	r.HandleFunc("/campaign/{id}", secure(showCampaign, postCampaign))    //for submission
-	r.HandleFunc("/", secure(page(homeTemplate), nil))                    //defaultHandler)
-	http.Handle("/", r)
+	r.HandleFunc("/user/devices", secure(userDevices, postDevices))                          //name and revoke devices
+	r.HandleFunc("/user/campaign/{campaign_id}", secure(myCampaign, postMembers))            //members and queues
+	r.HandleFunc("/campaign/{id}", secure(showCampaign, postCampaign))                       //for submission
+	r.HandleFunc("/draft/{id}", secure(resumeDraft, nil))                                    //continue a saved draft
+	r.HandleFunc("/campaign/{id}/doc/{doc_id}/edit", secure(editCampaignDoc, nil))           //edit a submitted doc
+	r.HandleFunc("/user/campaign/{campaign_id}/queue/{queue}", secure(showQueue, postQueue)) //process submitted docs
+	r.HandleFunc("/", secure(page(homeTemplate), nil))                                       //defaultHandler)
+	http.Handle("/", limiter.handler(r))
 
 	//fileServer serves static files such as style sheets from the ./resources folder
 	//note: templates
@@ -63,11 +75,6 @@ func main() {
 		panic(fmt.Sprintf("failed to create ms client: %+v", err))
 	}
 
-	//redis client
-	redisClient = redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-	})
-
 	//start the web server
 	http.ListenAndServe(":8080", nil)
 }
@@ -85,6 +92,8 @@ var (
 	loginOtpTemplate          *template.Template
 	userHomeTemplate          *template.Template
 	userCampaignTemplate      *template.Template
+	userQueueTemplate         *template.Template
+	userDevicesTemplate       *template.Template
 	formTemplate              *template.Template
 	formSubmittedTemplate     *template.Template
 	campaignSubmittedTemplate *template.Template
@@ -97,10 +106,17 @@ func loadResources() {
 	loginOtpTemplate = loadTemplates([]string{"login-otp-form", "page"})
 	userHomeTemplate = loadTemplates([]string{"user-home", "page"})
 	userCampaignTemplate = loadTemplates([]string{"user-campaign", "page"})
+	userQueueTemplate = loadTemplates([]string{"user-queue", "page"})
+	userDevicesTemplate = loadTemplates([]string{"user-devices", "page"})
 	formTemplate = loadTemplates([]string{"form", "page"})
 	formSubmittedTemplate = loadTemplates([]string{"form-submitted", "page"})
 	campaignSubmittedTemplate = loadTemplates([]string{"campaign-submitted", "page"})
 	errorTemplate = loadTemplates([]string{"error", "page"})
+
+	var err error
+	if otpEmailTemplate, err = template.ParseFiles(loginOtp.Template); err != nil {
+		panic(fmt.Sprintf("failed to load OTP email template %s: %+v", loginOtp.Template, err))
+	}
 }
 
 func loadTemplates(templateNames []string) *template.Template {
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strings"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/go-msvc/utils/ms"
+)
+
+// Owners of a campaign invite other users as members on the campaign page at
+// /user/campaign/{campaign_id} and remove them again. Members see the campaign
+// on their home page and what they may do depends on their role.
+
+// memberRoles that owners can give to invited members
+var memberRoles = []forms.CampaignRole{
+	forms.CampaignRoleViewer,
+	forms.CampaignRoleReviewer,
+	forms.CampaignRoleEditor,
+	forms.CampaignRoleOwner,
+}
+
+// principal identifies the logged in user in requests to the forms service,
+// which gets the user from the session so that requests cannot claim to be
+// another user
+func principal(session *forms.Session) formsinterface.Principal {
+	return formsinterface.Principal{SessionID: session.ID}
+} //principal()
+
+// postMembers invites or removes a member, then shows the campaign again
+func postMembers(ctx context.Context, session *forms.Session, params map[string]string, formData url.Values) (*template.Template, interface{}, error) {
+	log.Debugf("postMembers(%+v)", params)
+	campaignID := params["campaign_id"]
+	userID := strings.TrimSpace(formData.Get("user_id"))
+	switch action := formData.Get("action"); action {
+	case "invite":
+		if _, err := msClient.Sync(
+			ctx,
+			ms.Address{
+				Domain:    formsDomain,
+				Operation: "invite_member",
+			},
+			formsTTL,
+			formsinterface.InviteMemberRequest{
+				Principal:  principal(session),
+				CampaignID: campaignID,
+				Member: forms.CampaignMember{
+					UserID: userID,
+					Role:   forms.CampaignRole(formData.Get("role")),
+				},
+			},
+			formsinterface.InviteMemberResponse{}); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to invite %s", userID)
+		}
+	case "remove":
+		if _, err := msClient.Sync(
+			ctx,
+			ms.Address{
+				Domain:    formsDomain,
+				Operation: "remove_member",
+			},
+			formsTTL,
+			formsinterface.RemoveMemberRequest{
+				Principal:  principal(session),
+				CampaignID: campaignID,
+				UserID:     userID,
+			},
+			formsinterface.RemoveMemberResponse{}); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to remove %s", userID)
+		}
+	default:
+		return nil, nil, errors.Errorf("unknown action \"%s\"", action)
+	}
+	return nil, nil, ErrorRedirect(fmt.Sprintf("/user/campaign/%s", campaignID))
+} //postMembers()
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+)
+
+func init() {
+	config.MustConfigure("otp", otpConfig{
+		Subject:  "Forms Login OTP",
+		Template: "./templates/otp-email.tmpl",
+	})
+}
+
+// otpConfig controls the email that sends the OTP generated by the forms
+// service to login, e.g. {"otp":{"subject":"Forms Login OTP","template":"./templates/otp-email.tmpl"}}
+type otpConfig struct {
+	Subject  string `json:"subject" doc:"Subject of the OTP email"`
+	Template string `json:"template" doc:"HTML template file of the OTP email body, executed with {{.Email}}, {{.OTP}} and {{.Expiry}}"`
+}
+
+func (c otpConfig) Validate() error {
+	if c.Subject == "" {
+		return errors.Errorf("missing subject")
+	}
+	if c.Template == "" {
+		return errors.Errorf("missing template")
+	}
+	return nil
+}
+
+var (
+	// loginOtp and otpEmailTemplate are created from config in main()
+	loginOtp         otpConfig
+	otpEmailTemplate *template.Template
+)
+
+// sendOtp emails the OTP to the user
+func sendOtp(email string, otpValue string, expiry time.Time) error {
+	body := bytes.NewBuffer(nil)
+	if err := otpEmailTemplate.Execute(body, map[string]interface{}{
+		"Email":  email,
+		"OTP":    otpValue,
+		"Expiry": expiry.Format("2006-01-02 15:04:05"),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to render OTP email")
+	}
+	if err := mailer.Send(Message{
+		To:          email,
+		Subject:     loginOtp.Subject,
+		ContentType: "text/html",
+		Body:        body.String(),
+	}); err != nil {
+		return errors.Wrapf(err, "failed to send OTP email")
+	}
+	return nil
+} //sendOtp()
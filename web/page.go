@@ -7,7 +7,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-msvc/errors"
@@ -41,8 +43,10 @@ type pagePostHandler func(
 )
 
 type CtxDeviceID struct{}
+type CtxRemoteAddr struct{}
 type CtxEmail struct{}
 type CtxTargetURL struct{}
+type CtxLogout struct{} //*bool set by logoutHandler to end the session
 
 // data given to the page template
 type TmplData struct {
@@ -205,6 +209,9 @@ func pageHandlerFunc(securePage bool, getHdlr pageGetHandler, postHdlr pagePostH
 			log.Debugf("HAS device: %+v", deviceID)
 		}
 		ctx = context.WithValue(ctx, CtxDeviceID{}, deviceID)
+		ctx = context.WithValue(ctx, CtxRemoteAddr{}, clientAddr(httpReq))
+		logout := false
+		ctx = context.WithValue(ctx, CtxLogout{}, &logout)
 		session, err = getSession(ctx, deviceID)
 		if err != nil {
 			//can't get an existing/new session
@@ -283,7 +290,8 @@ func getSession(ctx context.Context, deviceID string) (*forms.Session, error) {
 		},
 		formsTTL,
 		formsinterface.GetSessionRequest{
-			DeviceID: deviceID,
+			Principal: servicePrincipal(),
+			DeviceID:  deviceID,
 		},
 		formsinterface.GetSessionResponse{})
 	if err != nil {
@@ -311,6 +319,12 @@ func updSession(ctx context.Context, deviceID string, session *forms.Session) er
 	for n, v := range session.Data {
 		log.Debugf("  session[\"%s\"] = (%T)%v", n, v, v)
 	}
+	//only logout when the user asked for it, the session may have been
+	//logged in on another device after it was retrieved
+	logout := false
+	if l, ok := ctx.Value(CtxLogout{}).(*bool); ok && l != nil {
+		logout = *l
+	}
 	_, err := msClient.Sync(
 		ctx,
 		ms.Address{
@@ -319,8 +333,10 @@ func updSession(ctx context.Context, deviceID string, session *forms.Session) er
 		},
 		formsTTL,
 		formsinterface.UpdSessionRequest{
-			DeviceID: deviceID,
-			Session:  *session,
+			Principal: servicePrincipal(),
+			DeviceID:  deviceID,
+			Session:   *session,
+			Logout:    logout,
 		},
 		nil)
 	if err != nil {
@@ -341,11 +357,9 @@ func showPage(ctx context.Context, t *template.Template, data any, httpRes http.
 	}
 }
 
-func postForm(ctx context.Context, session *forms.Session, values url.Values) (forms.Doc, error) {
+func postForm(ctx context.Context, session *forms.Session, form forms.Form, postedData map[string]interface{}) (forms.Doc, error) {
 	//log.Debugf("submitForm: %+v", values)
 
-	//todo: security should include doc token fetched from db, to restrict update
-
 	//get form id and revision
 	formID := session.Data["form_id"].(string)
 	formRevValue, gotRev := session.Data["form_rev"]
@@ -359,19 +373,43 @@ func postForm(ctx context.Context, session *forms.Session, values url.Values) (f
 		return forms.Doc{}, errors.Wrapf(err, "invalid form rev(%v)", formRevValue)
 	}
 	//log.Debugf("submit form.id(%s).rev(%v)", formID, formRev)
-	//get doc id (if editing existing doc)
-	// docID := values["doc_id"]
-	// docRev := values["doc_rev"]
-	//...todo: if defined - do upd_doc instead of add_doc
 
+	if form.ID != formID || form.Rev != int(formRev) {
+		return forms.Doc{}, errors.Errorf("form.id(%s).rev(%d) changed since form(%s).rev(%d) was displayed", form.ID, form.Rev, formID, formRev)
+	}
+	//convert posted strings to typed values, e.g. integer field "42" -> int64(42)
+	//and return formDataError for the caller to show the form again
+	data, err := form.CoerceData(postedData)
+	if err != nil {
+		if fieldErrors, ok := err.(forms.FieldErrors); ok {
+			return forms.Doc{}, formDataError{Errors: fieldErrors, Values: postedData}
+		}
+		return forms.Doc{}, errors.Wrapf(err, "failed to convert form data")
+	}
+	if err := form.ValidateData(data); err != nil {
+		if fieldErrors, ok := err.(forms.FieldErrors); ok {
+			return forms.Doc{}, formDataError{Errors: fieldErrors, Values: postedData}
+		}
+		return forms.Doc{}, errors.Wrapf(err, "failed to validate form data")
+	}
+
+	campaignID, _ := session.Data["campaign_id"].(string)
 	doc := forms.Doc{
-		FormID:  formID,
-		FormRev: int(formRev),
-		//ID:      docID,
-		Data: map[string]interface{}{},
+		FormID:     formID,
+		FormRev:    int(formRev),
+		CampaignID: campaignID,
+		UserID:     session.Email,
+		Data:       data,
 	}
-	for n, v := range values {
-		doc.Data[n] = v
+
+	//an edited doc is updated to a new revision
+	if docID, _ := editingDoc(session); docID != "" {
+		return updateDoc(ctx, session, doc)
+	}
+
+	//a draft is submitted with the final answers
+	if draftID, _ := session.Data["draft_id"].(string); draftID != "" {
+		return submitDraft(ctx, session, form)
 	}
 
 	//use ms client to store the document
@@ -382,7 +420,7 @@ func postForm(ctx context.Context, session *forms.Session, values url.Values) (f
 			Operation: "add_doc",
 		},
 		formsTTL,
-		formsinterface.AddDocRequest{Doc: doc},
+		formsinterface.AddDocRequest{Principal: principal(session), Doc: doc},
 		formsinterface.AddDocResponse{})
 	if err != nil {
 		return forms.Doc{}, errors.Wrapf(err, "failed to create document")
@@ -390,6 +428,117 @@ func postForm(ctx context.Context, session *forms.Session, values url.Values) (f
 	return res.(formsinterface.AddDocResponse).Doc, nil
 }
 
+// postedData converts posted form values to doc data. Inputs are named
+// "<section>__<field>" but doc data is stored by field name, and table
+// columns are named "<section>__<table>[<row>].<field>" which are stored
+// as a list of rows in doc data, e.g. {"table":[{"field":...},...]}.
+// Rows are kept in order of their index, skipping rows removed in the
+// form and rows in which nothing was entered.
+func postedData(values url.Values) map[string]interface{} {
+	rowsData := map[string]interface{}{}
+	for n, v := range values {
+		if i := strings.Index(n, "__"); i >= 0 {
+			n = n[i+2:]
+		}
+		setPostedValue(rowsData, strings.Split(n, "."), []string(v))
+	}
+	return postedRows(rowsData).(map[string]interface{})
+} //postedData()
+
+// postedRowsByIndex holds rows by index until all values were set
+type postedRowsByIndex map[int]map[string]interface{}
+
+func setPostedValue(data map[string]interface{}, path []string, value []string) {
+	name := path[0]
+	open := strings.Index(name, "[")
+	if len(path) == 1 || open < 0 || !strings.HasSuffix(name, "]") {
+		data[strings.Join(path, ".")] = value
+		return
+	}
+	rowIndex, err := strconv.Atoi(name[open+1 : len(name)-1])
+	if err != nil || rowIndex < 0 {
+		data[strings.Join(path, ".")] = value
+		return
+	}
+	name = name[:open]
+	rows, ok := data[name].(postedRowsByIndex)
+	if !ok {
+		rows = postedRowsByIndex{}
+		data[name] = rows
+	}
+	row, ok := rows[rowIndex]
+	if !ok {
+		row = map[string]interface{}{}
+		rows[rowIndex] = row
+	}
+	setPostedValue(row, path[1:], value)
+} //setPostedValue()
+
+// postedRows replaces rows by index with lists of rows in index order
+func postedRows(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for n, e := range v {
+			v[n] = postedRows(e)
+		}
+		return v
+	case postedRowsByIndex:
+		indexes := make([]int, 0, len(v))
+		for i := range v {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		rows := []interface{}{}
+		for _, i := range indexes {
+			if postedEmpty(v[i]) {
+				continue
+			}
+			rows = append(rows, postedRows(v[i]))
+		}
+		return rows
+	}
+	return value
+} //postedRows()
+
+// postedEmpty is true when nothing was entered in a row
+func postedEmpty(value interface{}) bool {
+	switch v := value.(type) {
+	case []string:
+		for _, s := range v {
+			if s != "" {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		for _, e := range v {
+			if !postedEmpty(e) {
+				return false
+			}
+		}
+		return true
+	case postedRowsByIndex:
+		for _, row := range v {
+			if !postedEmpty(row) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+} //postedEmpty()
+
+// formDataError is returned from postForm() when submitted values are rejected,
+// with the values as posted to display the form again for the user to fix them
+type formDataError struct {
+	Errors forms.FieldErrors
+	Values map[string]interface{}
+}
+
+func (e formDataError) Error() string {
+	return e.Errors.Error()
+}
+
 func renderPage(w io.Writer, t *template.Template, data any) error {
 	if err := t.ExecuteTemplate(w, "page", data); err != nil {
 		return errors.Wrapf(err, "failed to exec template")
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms/service/formsinterface"
+)
+
+func init() {
+	config.MustConfigure("service", serviceConfig{})
+}
+
+// serviceConfig is the trusted service principal of the web app in the forms
+// service, needed for the session and login operations that are done before a
+// user is logged in, e.g. {"service":{"user_id":"web@example.com","secret_env":"FORMS_WEB_SECRET"}}
+type serviceConfig struct {
+	UserID    string `json:"user_id" doc:"User ID of the web app listed in the forms service config principals.services"`
+	SecretEnv string `json:"secret_env" doc:"Environment variable with the secret of the user_id, the same as in the forms service"`
+
+	secret string
+}
+
+func (c *serviceConfig) Validate() error {
+	if c.UserID == "" {
+		return errors.Errorf("missing user_id")
+	}
+	if c.SecretEnv == "" {
+		return errors.Errorf("missing secret_env")
+	}
+	c.secret = os.Getenv(c.SecretEnv)
+	if err := formsinterface.ValidateSecret(c.secret); err != nil {
+		return errors.Wrapf(err, "invalid secret in %s", c.SecretEnv)
+	}
+	return nil
+}
+
+// webService is loaded from config in main()
+var webService serviceConfig
+
+// servicePrincipal identifies the web app itself in requests to the forms
+// service, which only accepts session and login operations from trusted services
+func servicePrincipal() formsinterface.Principal {
+	return formsinterface.Principal{UserID: webService.UserID, Secret: webService.secret}
+} //servicePrincipal()
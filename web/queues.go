@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-msvc/errors"
+	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/go-msvc/utils/ms"
+)
+
+// Docs submitted to a campaign with processing queues are listed per queue at
+// /user/campaign/{campaign_id}/queue/{queue} where campaign members can move
+// them to another queue or change their state in the review workflow.
+
+type QueueTmplData struct {
+	CampaignID string
+	Name       string
+	Title      string
+	NrDocs     int
+	CanReview  bool                  //reviewers can move docs and change their state
+	Queues     []forms.CampaignQueue //to move docs to
+	Docs       []QueueDocTmplData
+}
+
+type QueueDocTmplData struct {
+	Doc    forms.Doc
+	States []forms.DocState //next states in the review workflow
+}
+
+// reviewStates are the states that campaign members can set in the workflow,
+// the other states are set when the submitter submits or edits the doc
+var reviewStates = []forms.DocState{
+	forms.DocStateInReview,
+	forms.DocStateReturned,
+	forms.DocStateAccepted,
+	forms.DocStateRejected,
+}
+
+func showQueue(ctx context.Context, session *forms.Session, params map[string]string) (*template.Template, interface{}, error) {
+	log.Debugf("showQueue(%+v)", params)
+	campaign, _, err := getCampaign(ctx, session, params["campaign_id"])
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load campaign")
+	}
+	if err := campaignMember(campaign, session, forms.CampaignRoleViewer); err != nil {
+		return nil, nil, err
+	}
+	queue, ok := campaign.FindQueue(params["queue"])
+	if !ok {
+		return nil, nil, errors.Errorf("campaign(%s) has no queue(%s)", campaign.ID, params["queue"])
+	}
+	listRes, err := listQueue(ctx, session, campaign.ID, queue.Name, formsinterface.MaxPageLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := QueueTmplData{
+		CampaignID: campaign.ID,
+		Name:       queue.Name,
+		Title:      queue.DisplayTitle(),
+		NrDocs:     listRes.Total,
+		CanReview:  campaign.HasRole(session.Email, forms.CampaignRoleReviewer),
+		Docs:       []QueueDocTmplData{},
+	}
+	for _, q := range campaign.Queues {
+		if q.Name != queue.Name {
+			data.Queues = append(data.Queues, q)
+		}
+	}
+	for _, doc := range listRes.Docs {
+		docData := QueueDocTmplData{Doc: doc}
+		for _, s := range reviewStates {
+			if doc.State.CanTransition(s) {
+				docData.States = append(docData.States, s)
+			}
+		}
+		data.Docs = append(data.Docs, docData)
+	}
+	return userQueueTemplate, data, nil
+} //showQueue()
+
+// postQueue moves a doc to another queue or changes its state, then shows the
+// queue again
+func postQueue(ctx context.Context, session *forms.Session, params map[string]string, formData url.Values) (*template.Template, interface{}, error) {
+	log.Debugf("postQueue(%+v)", params)
+	campaign, _, err := getCampaign(ctx, session, params["campaign_id"])
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load campaign")
+	}
+	if err := campaignMember(campaign, session, forms.CampaignRoleReviewer); err != nil {
+		return nil, nil, err
+	}
+	docID := formData.Get("doc_id")
+	docRev, err := strconv.Atoi(formData.Get("doc_rev"))
+	if docID == "" || err != nil {
+		return nil, nil, errors.Errorf("missing doc_id and doc_rev")
+	}
+	comment := strings.TrimSpace(formData.Get("comment"))
+	action := formData.Get("action")
+	switch {
+	case action == "move":
+		if _, err := msClient.Sync(
+			ctx,
+			ms.Address{
+				Domain:    formsDomain,
+				Operation: "move_doc",
+			},
+			formsTTL,
+			formsinterface.MoveDocRequest{
+				Principal:   principal(session),
+				ID:          docID,
+				ExpectedRev: docRev,
+				Queue:       formData.Get("queue"),
+				Comment:     comment,
+			},
+			formsinterface.MoveDocResponse{}); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to move doc(%s)", docID)
+		}
+	case strings.HasPrefix(action, "state:"):
+		req := formsinterface.ReviewDocRequest{
+			Principal:   principal(session),
+			ID:          docID,
+			ExpectedRev: docRev,
+			State:       forms.DocState(strings.TrimPrefix(action, "state:")),
+		}
+		if comment != "" {
+			req.Comments = []forms.DocComment{{Text: comment}}
+		}
+		if _, err := msClient.Sync(
+			ctx,
+			ms.Address{
+				Domain:    formsDomain,
+				Operation: "review_doc",
+			},
+			formsTTL,
+			req,
+			formsinterface.ReviewDocResponse{}); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to change doc(%s) to %s", docID, req.State)
+		}
+	default:
+		return nil, nil, errors.Errorf("unknown action \"%s\"", action)
+	}
+	return nil, nil, ErrorRedirect(fmt.Sprintf("/user/campaign/%s/queue/%s", campaign.ID, params["queue"]))
+} //postQueue()
+
+// campaignMember checks that the user has the role in the campaign
+func campaignMember(campaign forms.Campaign, session *forms.Session, role forms.CampaignRole) error {
+	if !campaign.HasRole(session.Email, role) {
+		return errors.Errorf("not a %s of campaign(%s)", role, campaign.ID)
+	}
+	return nil
+} //campaignMember()
+
+// listQueue gets the docs in a queue, the most recently updated first
+func listQueue(ctx context.Context, session *forms.Session, campaignID string, queue string, limit int) (formsinterface.ListQueueResponse, error) {
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "list_queue",
+		},
+		formsTTL,
+		formsinterface.ListQueueRequest{
+			Principal:  principal(session),
+			CampaignID: campaignID,
+			Queue:      queue,
+			Page: formsinterface.Page{
+				Limit: limit,
+			},
+		},
+		formsinterface.ListQueueResponse{})
+	if err != nil {
+		return formsinterface.ListQueueResponse{}, errors.Wrapf(err, "failed to list queue(%s)", queue)
+	}
+	return res.(formsinterface.ListQueueResponse), nil
+} //listQueue()
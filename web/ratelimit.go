@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-msvc/config"
+	"github.com/go-msvc/errors"
+)
+
+func init() {
+	config.MustConfigure("rate_limit", rateLimitConfig{Rate: 5, Burst: 30})
+}
+
+// rateLimitConfig limits the requests from each IP address with a token bucket
+// that holds burst tokens and refills at rate tokens per second,
+// e.g. {"rate_limit":{"rate":5,"burst":30}}
+type rateLimitConfig struct {
+	Rate         float64 `json:"rate" doc:"Requests per second allowed on average from one IP address"`
+	Burst        int     `json:"burst" doc:"Requests allowed at once from one IP address, e.g. when a page loads"`
+	ForwardedFor bool    `json:"forwarded_for,omitempty" doc:"Set true when behind a trusted proxy to use the last address in X-Forwarded-For"`
+}
+
+func (c rateLimitConfig) Validate() error {
+	if c.Rate <= 0 {
+		return errors.Errorf("rate:%v must be positive", c.Rate)
+	}
+	if c.Burst < 1 {
+		return errors.Errorf("burst:%d must be positive", c.Burst)
+	}
+	return nil
+}
+
+type rateLimiter struct {
+	mutex     sync.Mutex
+	config    rateLimitConfig
+	buckets   map[string]*tokenBucket //by IP address
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter is created from config in main()
+var limiter *rateLimiter
+
+func newRateLimiter(c rateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		config:    c,
+		buckets:   map[string]*tokenBucket{},
+		lastSweep: time.Now(),
+	}
+} //newRateLimiter()
+
+// allow takes a token from the bucket of the address, else returns how long to
+// wait for the next token
+func (l *rateLimiter) allow(addr string, now time.Time) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if now.Sub(l.lastSweep) > time.Minute {
+		//forget buckets that refilled completely
+		for a, b := range l.buckets {
+			if l.refill(b, now) >= float64(l.config.Burst) {
+				delete(l.buckets, a)
+			}
+		}
+		l.lastSweep = now
+	}
+	b, ok := l.buckets[addr]
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[addr] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.config.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+} //rateLimiter.allow()
+
+// refill returns the tokens in the bucket at the time
+func (l *rateLimiter) refill(b *tokenBucket, now time.Time) float64 {
+	return math.Min(float64(l.config.Burst), b.tokens+now.Sub(b.last).Seconds()*l.config.Rate)
+} //rateLimiter.refill()
+
+// handler responds with 429 Too Many Requests when the client exceeds its limit
+func (l *rateLimiter) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(httpRes http.ResponseWriter, httpReq *http.Request) {
+		addr := clientAddr(httpReq)
+		if ok, wait := l.allow(addr, time.Now()); !ok {
+			log.Errorf("rate limited %s %s %s", addr, httpReq.Method, httpReq.URL.Path)
+			httpRes.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+			http.Error(httpRes, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(httpRes, httpReq)
+	})
+} //rateLimiter.handler()
+
+// clientAddr returns the IP address of the client
+func clientAddr(httpReq *http.Request) string {
+	if limiter != nil && limiter.config.ForwardedFor {
+		//the last address was added by the proxy, those before it could be spoofed by the client
+		if forwarded := httpReq.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			addrs := strings.Split(forwarded[len(forwarded)-1], ",")
+			return strings.TrimSpace(addrs[len(addrs)-1])
+		}
+	}
+	if host, _, err := net.SplitHostPort(httpReq.RemoteAddr); err == nil {
+		return host
+	}
+	return httpReq.RemoteAddr
+} //clientAddr()
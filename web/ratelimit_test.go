@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := newRateLimiter(rateLimitConfig{Rate: 2, Burst: 3})
+	t0 := time.Now()
+	tests := []struct {
+		name    string
+		addr    string
+		at      time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{"burst 1", "10.0.0.1", 0, true, 0},
+		{"burst 2", "10.0.0.1", 0, true, 0},
+		{"burst 3", "10.0.0.1", 0, true, 0},
+		{"bucket empty", "10.0.0.1", 0, false, 500 * time.Millisecond},
+		{"other address", "10.0.0.2", 0, true, 0},
+		{"half a token", "10.0.0.1", 250 * time.Millisecond, false, 250 * time.Millisecond},
+		{"refilled one token", "10.0.0.1", 500 * time.Millisecond, true, 0},
+		{"empty again", "10.0.0.1", 500 * time.Millisecond, false, 500 * time.Millisecond},
+		{"refill is limited to burst", "10.0.0.1", time.Hour, true, 0},
+		{"burst 2 after refill", "10.0.0.1", time.Hour, true, 0},
+		{"burst 3 after refill", "10.0.0.1", time.Hour, true, 0},
+		{"empty after burst", "10.0.0.1", time.Hour, false, 500 * time.Millisecond},
+	}
+	for _, test := range tests {
+		allowed, wait := l.allow(test.addr, t0.Add(test.at))
+		if allowed != test.allowed || wait != test.wait {
+			t.Fatalf("%s: allowed:%v wait:%s, expected %v and %s", test.name, allowed, wait, test.allowed, test.wait)
+		}
+	}
+} //TestRateLimiterAllow()
+
+func TestRateLimiterSweep(t *testing.T) {
+	l := newRateLimiter(rateLimitConfig{Rate: 1, Burst: 100})
+	t0 := l.lastSweep
+	l.allow("10.0.0.1", t0)
+	for i := 0; i < 100; i++ {
+		l.allow("10.0.0.2", t0)
+	}
+	//10.0.0.1 refilled completely after a minute, 10.0.0.2 after 100s
+	l.allow("10.0.0.3", t0.Add(time.Minute+time.Second))
+	if _, ok := l.buckets["10.0.0.1"]; ok || len(l.buckets) != 2 {
+		t.Fatalf("expected 10.0.0.1 to be forgotten: %v", l.buckets)
+	}
+	if allowed, _ := l.allow("10.0.0.2", t0.Add(time.Minute+time.Second)); !allowed {
+		t.Fatalf("10.0.0.2 not allowed after it refilled")
+	}
+} //TestRateLimiterSweep()
+
+func TestRateLimiterHandler(t *testing.T) {
+	l := newRateLimiter(rateLimitConfig{Rate: 0.1, Burst: 2})
+	h := l.handler(http.HandlerFunc(func(httpRes http.ResponseWriter, httpReq *http.Request) {
+		httpRes.WriteHeader(http.StatusOK)
+	}))
+	for i, expected := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		httpReq := httptest.NewRequest(http.MethodGet, "/", nil)
+		httpReq.RemoteAddr = "10.0.0.1:1234"
+		httpRes := httptest.NewRecorder()
+		h.ServeHTTP(httpRes, httpReq)
+		if httpRes.Code != expected {
+			t.Fatalf("request %d: status %d, expected %d", i+1, httpRes.Code, expected)
+		}
+		if expected == http.StatusTooManyRequests && httpRes.Header().Get("Retry-After") != "10" {
+			t.Fatalf("Retry-After: \"%s\", expected \"10\"", httpRes.Header().Get("Retry-After"))
+		}
+	}
+} //TestRateLimiterHandler()
+
+func TestClientAddr(t *testing.T) {
+	defer func(l *rateLimiter) { limiter = l }(limiter)
+	tests := []struct {
+		name         string
+		forwardedFor bool
+		remoteAddr   string
+		headers      []string
+		expected     string
+	}{
+		{"remote addr", false, "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"ipv6", false, "[::1]:1234", nil, "::1"},
+		{"forwarded for ignored", false, "10.0.0.1:1234", []string{"1.2.3.4"}, "10.0.0.1"},
+		{"forwarded for", true, "10.0.0.1:1234", []string{"1.2.3.4"}, "1.2.3.4"},
+		{"last forwarded address", true, "10.0.0.1:1234", []string{"6.6.6.6, 1.2.3.4"}, "1.2.3.4"},
+		{"last forwarded header", true, "10.0.0.1:1234", []string{"6.6.6.6", "1.2.3.4"}, "1.2.3.4"},
+		{"no forwarded for", true, "10.0.0.1:1234", nil, "10.0.0.1"},
+	}
+	for _, test := range tests {
+		limiter = newRateLimiter(rateLimitConfig{Rate: 1, Burst: 1, ForwardedFor: test.forwardedFor})
+		httpReq := httptest.NewRequest(http.MethodGet, "/", nil)
+		httpReq.RemoteAddr = test.remoteAddr
+		for _, h := range test.headers {
+			httpReq.Header.Add("X-Forwarded-For", h)
+		}
+		if addr := clientAddr(httpReq); addr != test.expected {
+			t.Errorf("%s: got \"%s\", expected \"%s\"", test.name, addr, test.expected)
+		}
+	}
+} //TestClientAddr()
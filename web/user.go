@@ -7,6 +7,8 @@ import (
 
 	"github.com/go-msvc/errors"
 	"github.com/go-msvc/forms"
+	"github.com/go-msvc/forms/service/formsinterface"
+	"github.com/go-msvc/utils/ms"
 )
 
 func userHomeGetHandler(
@@ -20,24 +22,29 @@ func userHomeGetHandler(
 ) {
 	log.Debugf("Showing User's Home (params:%+v)", params)
 
-	//todo: replace this test data
-	pageData := UserHomeTmplData{
-		Campaigns: []CampaignTmplData{
-			{
-				ID:                 "8df7997b-4a90-4784-a496-c0ec453c10f2",
-				Title:              "C1",
-				TimeCreated:        time.Now().Add(-time.Hour * 24),
-				LastSubmissionTime: time.Now().Add(time.Hour),
-				NrSubmissions:      10,
-			},
-			{
-				ID:                 "8df7997b-4a90-4784-a496-c0ec453c10f2", //same as above
-				Title:              "C2",
-				TimeCreated:        time.Now().Add(-time.Hour * 224),
-				LastSubmissionTime: time.Now().Add(time.Hour * 3),
-				NrSubmissions:      20,
-			},
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "find_campaigns",
+		},
+		formsTTL,
+		formsinterface.FindCampaignRequest{
+			Principal: principal(session),
 		},
+		formsinterface.FindCampaignResponse{})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to find campaigns")
+	}
+	pageData := UserHomeTmplData{
+		Campaigns: []CampaignTmplData{},
+	}
+	for _, c := range res.(formsinterface.FindCampaignResponse).Campaigns {
+		campaignData, err := campaignTmplData(ctx, session, c)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to get campaign(%s) details", c.ID)
+		}
+		pageData.Campaigns = append(pageData.Campaigns, campaignData)
 	}
 	return userHomeTemplate, pageData, nil
 } //userHomeGetHandler()
@@ -52,6 +59,11 @@ type CampaignTmplData struct {
 	TimeCreated        time.Time
 	NrSubmissions      int
 	LastSubmissionTime time.Time
+	Role               forms.CampaignRole
+	Queues             []QueueTmplData
+	Members            []forms.CampaignMember
+	CanManage          bool                 //owners can invite and remove members
+	Roles              []forms.CampaignRole //to invite members with
 }
 
 func myCampaign(
@@ -65,18 +77,87 @@ func myCampaign(
 ) {
 	log.Debugf("Campaign Details (params:%+v)", params)
 
-	c, f, err := loadCampaign(ctx, params["campaign_id"])
+	c, _, err := getCampaign(ctx, session, params["campaign_id"])
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "campaign not loaded")
 	}
+	if err := campaignMember(c, session, forms.CampaignRoleViewer); err != nil {
+		return nil, nil, err
+	}
 
-	//todo: replace this test data
-	pageData := CampaignTmplData{
-		ID:                 c.ID,
-		Title:              f.Title,
-		TimeCreated:        c.CreateTime,
-		LastSubmissionTime: time.Now().Add(time.Hour), //todo
-		NrSubmissions:      10,                        //todo
+	pageData, err := campaignTmplData(ctx, session, c)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get campaign details")
+	}
+	//nr of docs in each queue to process
+	for _, q := range c.Queues {
+		listRes, err := listQueue(ctx, session, c.ID, q.Name, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		pageData.Queues = append(pageData.Queues, QueueTmplData{
+			CampaignID: c.ID,
+			Name:       q.Name,
+			Title:      q.DisplayTitle(),
+			NrDocs:     listRes.Total,
+		})
+	}
+	pageData.Members = c.Members
+	if c.HasRole(session.Email, forms.CampaignRoleOwner) {
+		pageData.CanManage = true
+		pageData.Roles = memberRoles
 	}
 	return userCampaignTemplate, pageData, nil
 } //myCampaign()
+
+// campaignTmplData gets the form title and summary of docs submitted to the campaign
+func campaignTmplData(ctx context.Context, session *forms.Session, c forms.Campaign) (CampaignTmplData, error) {
+	data := CampaignTmplData{
+		ID:          c.ID,
+		TimeCreated: c.CreateTime,
+		Role:        c.Role(session.Email),
+	}
+	res, err := msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "get_form",
+		},
+		formsTTL,
+		formsinterface.GetFormRequest{
+			Principal: principal(session),
+			ID:        c.FormID,
+		},
+		formsinterface.GetFormResponse{})
+	if err != nil {
+		return CampaignTmplData{}, errors.Wrapf(err, "form.id(%s) not found", c.FormID)
+	}
+	data.Title = res.(formsinterface.GetFormResponse).Form.Title
+
+	//only need the last submitted doc and the total
+	res, err = msClient.Sync(
+		ctx,
+		ms.Address{
+			Domain:    formsDomain,
+			Operation: "find_docs",
+		},
+		formsTTL,
+		formsinterface.FindDocRequest{
+			Principal:  principal(session),
+			CampaignID: c.ID,
+			Page: formsinterface.Page{
+				Sort:  "-created",
+				Limit: 1,
+			},
+		},
+		formsinterface.FindDocResponse{})
+	if err != nil {
+		return CampaignTmplData{}, errors.Wrapf(err, "failed to find campaign docs")
+	}
+	findDocsRes := res.(formsinterface.FindDocResponse)
+	data.NrSubmissions = findDocsRes.Total
+	if len(findDocsRes.Docs) > 0 {
+		data.LastSubmissionTime = findDocsRes.Docs[0].Timestamp
+	}
+	return data, nil
+} //campaignTmplData()
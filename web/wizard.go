@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/go-msvc/forms"
+)
+
+// A campaign form is filled in one section per page. Each page is posted and
+// validated on its own and the answers are kept in the internal session data
+// until all pages were reviewed and the doc is submitted. Pages are numbered
+// by section index with len(form.Sections) for the review page, and sections
+// hidden by their show_if condition are skipped.
+
+// wizardAnswers returns the answers entered so far on all pages of the form
+func wizardAnswers(session *forms.Session) map[string]interface{} {
+	answers, ok := session.Data["answers"].(map[string]interface{})
+	if !ok {
+		answers = map[string]interface{}{}
+		session.Data["answers"] = answers
+	}
+	return answers
+} //wizardAnswers()
+
+// wizardPage returns the page to display, skipping hidden sections
+func wizardPage(session *forms.Session, form forms.Form) int {
+	page, err := strconv.Atoi(fmt.Sprintf("%v", session.Data["page"]))
+	if err != nil || page < 0 || page > len(form.Sections) {
+		page = 0
+	}
+	if page < len(form.Sections) && !form.SectionVisible(form.Sections[page].Name, wizardAnswers(session)) {
+		page = nextPage(form, wizardAnswers(session), page)
+	}
+	return page
+} //wizardPage()
+
+// wizardReset discards the answers, e.g. when another form is opened or the
+// doc was submitted. Values are cleared rather than deleted because session
+// data is merged into the existing session by upd_session.
+func wizardReset(session *forms.Session) {
+	session.Data["answers"] = nil
+	session.Data["page"] = nil
+	session.Data["draft_id"] = nil
+	session.Data["doc_id"] = nil
+	session.Data["doc_rev"] = nil
+	session.Data["comments"] = nil
+} //wizardReset()
+
+// wizardComments returns the reviewer comments kept in the session while a
+// returned doc is edited
+func wizardComments(session *forms.Session) []forms.DocComment {
+	jsonComments, _ := session.Data["comments"].(string)
+	if jsonComments == "" {
+		return nil
+	}
+	var comments []forms.DocComment
+	if err := json.Unmarshal([]byte(jsonComments), &comments); err != nil {
+		log.Errorf("discard invalid comments in session: %+v", err)
+		return nil
+	}
+	return comments
+} //wizardComments()
+
+// nextPage returns the page of the next visible section after page, or the
+// review page after the last section
+func nextPage(form forms.Form, answers map[string]interface{}, page int) int {
+	for i := page + 1; i < len(form.Sections); i++ {
+		if form.SectionVisible(form.Sections[i].Name, answers) {
+			return i
+		}
+	}
+	return len(form.Sections)
+} //nextPage()
+
+// prevPage returns the page of the previous visible section before page, or
+// -1 when there is none
+func prevPage(form forms.Form, answers map[string]interface{}, page int) int {
+	for i := page - 1; i >= 0; i-- {
+		if form.SectionVisible(form.Sections[i].Name, answers) {
+			return i
+		}
+	}
+	return -1
+} //prevPage()
+
+// errorPage returns the page of the first visible section with a field error
+func errorPage(form forms.Form, answers map[string]interface{}, fieldErrors forms.FieldErrors) int {
+	for i, s := range form.Sections {
+		if !form.SectionVisible(s.Name, answers) {
+			continue
+		}
+		names := map[string]bool{}
+		for _, n := range s.ItemNames() {
+			names[n] = true
+		}
+		for path := range fieldErrors {
+			if names[forms.DataPathName(path)] {
+				return i
+			}
+		}
+	}
+	return len(form.Sections)
+} //errorPage()
+
+// keepPageAnswers replaces the answers of the section on the page with the
+// values that were posted, so they are not lost when the user goes back
+func keepPageAnswers(form forms.Form, answers map[string]interface{}, page int, posted map[string]interface{}) {
+	if page >= len(form.Sections) {
+		return //nothing can be changed on the review page
+	}
+	for _, n := range form.Sections[page].ItemNames() {
+		delete(answers, n)
+		if v, ok := posted[n]; ok {
+			answers[n] = v
+		}
+	}
+} //keepPageAnswers()
+
+// wizardForm prepares the form to render the current page with the answers
+// entered so far and optional errors of the values posted on this page
+func wizardForm(campaign forms.Campaign, form forms.Form, session *forms.Session, fieldErrors forms.FieldErrors) forms.Form {
+	answers := wizardAnswers(session)
+	page := wizardPage(session, form)
+	session.Data["page"] = page
+
+	formPage := &forms.FormPage{
+		Section: page,
+		Review:  page == len(form.Sections),
+		Back:    prevPage(form, answers, page) >= 0,
+	}
+	for i, s := range form.Sections {
+		visible := form.SectionVisible(s.Name, answers)
+		formPage.Steps = append(formPage.Steps, forms.FormStep{
+			Title:   s.Title,
+			Visible: visible,
+			Current: i == page,
+		})
+		if visible {
+			formPage.Count++
+			if i <= page {
+				formPage.Number++
+			}
+		}
+	}
+	formPage.Count++ //review page
+	if formPage.Review {
+		formPage.Number = formPage.Count
+	}
+
+	//values entered on other pages are needed by conditions and computed
+	//fields on this page, values of this page come from its inputs
+	values, err := form.CoerceData(answers)
+	if err != nil {
+		values = answers
+	}
+	otherValues := map[string]interface{}{}
+	for n, v := range values {
+		otherValues[n] = v
+	}
+	if !formPage.Review {
+		for _, n := range form.Sections[page].ItemNames() {
+			delete(otherValues, n)
+		}
+	}
+	jsonValues, _ := json.Marshal(otherValues)
+	formPage.Values = string(jsonValues)
+
+	if docID, _ := editingDoc(session); docID != "" {
+		formPage.Editing = true
+	}
+	if draftID, _ := session.Data["draft_id"].(string); draftID != "" {
+		formPage.DraftURL = fmt.Sprintf("/draft/%s", draftID)
+	}
+
+	form = campaignForm(campaign, form)
+	form.Page = formPage
+	form.Values = answers
+	form.Errors = fieldErrors
+	form.Comments = forms.CommentsByPath(wizardComments(session))
+	return form
+} //wizardForm()